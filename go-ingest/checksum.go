@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path"
+)
+
+// checksumSidecars is set from WRITE_CHECKSUM=sha256 in main. When true,
+// every Parquet file written gets a <name>.sha256 sidecar.
+var checksumSidecars bool
+
+// writeChecksum stores <name>.sha256 next to name in sha256sum format
+// ("<hex>  <file>"), so `sha256sum -c` can verify a copied file. It is a
+// no-op unless checksumSidecars is set.
+func writeChecksum(sink Sink, name string, data []byte) error {
+	if !checksumSidecars {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), path.Base(name))
+	if err := sink.Write(name+".sha256", []byte(line)); err != nil {
+		return fmt.Errorf("write %s.sha256: %w", name, err)
+	}
+	return nil
+}
+
+// parseChecksumMode validates WRITE_CHECKSUM; only sha256 is supported.
+func parseChecksumMode(v string) bool {
+	switch v {
+	case "", "off", "false":
+		return false
+	case "sha256":
+		return true
+	default:
+		log.Printf("WARN  invalid WRITE_CHECKSUM %q (want sha256; sidecars disabled)", v)
+		return false
+	}
+}
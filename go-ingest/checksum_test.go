@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestWriteChecksum(t *testing.T) {
+	sink := testSink(t.TempDir())
+	data := []byte("PAR1 station data PAR1")
+
+	if err := writeChecksum(sink, "SANF1_latest.parquet", data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.Read("SANF1_latest.parquet.sha256"); err == nil {
+		t.Fatal("sidecar written with WRITE_CHECKSUM off")
+	}
+
+	old := checksumSidecars
+	checksumSidecars = true
+	t.Cleanup(func() { checksumSidecars = old })
+	if err := writeChecksum(sink, "2024/SANF1_latest.parquet", data); err != nil {
+		t.Fatal(err)
+	}
+	b, err := sink.Read("2024/SANF1_latest.parquet.sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	// sha256sum -c format, naming the file relative to the sidecar.
+	if want := hex.EncodeToString(sum[:]) + "  SANF1_latest.parquet\n"; string(b) != want {
+		t.Errorf("sidecar = %q, want %q", b, want)
+	}
+}
+
+func TestParseChecksumMode(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "off": false, "sha256": true, "md5": false} {
+		if got := parseChecksumMode(v); got != want {
+			t.Errorf("parseChecksumMode(%q) = %t, want %t", v, got, want)
+		}
+	}
+}
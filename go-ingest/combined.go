@@ -0,0 +1,30 @@
+package main
+
+import (
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// combinedRows holds the latest rows of every station fetched by this
+// process, so that a cycle refreshing only the stations due
+// (STATION_REFRESH) still writes a combined file covering all of them.
+var combinedRows = make(map[string][]metrow.MetRow)
+
+// writeCombined stores fresh (rows by station) in combinedRows and writes
+// them all to the single COMBINED_OUTPUT file, sorted by time then station.
+// It is only called from runOnce, which never runs concurrently. Like
+// writeStationFile it reports whether the file was written.
+func writeCombined(cfg config, sink Sink, now time.Time, fresh map[string][]metrow.MetRow) (metrow.ManifestEntry, bool, error) {
+	for s, rows := range fresh {
+		combinedRows[s] = rows
+	}
+	if len(combinedRows) == 0 {
+		return metrow.ManifestEntry{}, false, errNoRows
+	}
+	var all []metrow.MetRow
+	for _, rows := range combinedRows {
+		all = append(all, rows...)
+	}
+	return writeStationFile(cfg, sink, metrow.CombinedStation, cfg.Filename.ExpandCombined(now), all, nil)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestCombinedOutput(t *testing.T) {
+	old := combinedRows
+	combinedRows = make(map[string][]metrow.MetRow)
+	t.Cleanup(func() { combinedRows = old })
+	resetPublished(t)
+
+	failSMKF1 := false
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if failSMKF1 && strings.Contains(r.URL.Path, "SMKF1") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 08 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+			"2024 06 10 07 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8  26.0  20.0   MM   MM    MM\n"))
+	})
+	cfg := config{Stations: []string{"SMKF1", "SANF1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1, Combined: true}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	sink := testSink(cfg.DataDir)
+
+	check := func() {
+		t.Helper()
+		rows := readStored(t, sink, "all_latest.parquet")
+		var got []string
+		for _, r := range rows {
+			got = append(got, r.StationID)
+		}
+		if want := "SANF1 SMKF1 SANF1 SMKF1"; strings.Join(got, " ") != want {
+			t.Errorf("row stations = %q, want %q", got, want)
+		}
+		if rows[0].Time != rows[1].Time || rows[2].Time <= rows[0].Time {
+			t.Errorf("rows not ordered by time then station: %+v", rows)
+		}
+		b, err := os.ReadFile(filepath.Join(cfg.DataDir, metrow.ManifestName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m metrow.Manifest
+		if err := json.Unmarshal(b, &m); err != nil || len(m.Files) != 1 || m.Files[0].Rows != 4 {
+			t.Errorf("manifest = %s (err %v), want one entry of 4 rows", b, err)
+		}
+	}
+	runOnce(context.Background(), cfg, sink)
+	check()
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, "SANF1_latest.parquet")); !os.IsNotExist(err) {
+		t.Errorf("per-station file written alongside the combined one: %v", err)
+	}
+
+	// A station failing this cycle keeps its last rows in the file.
+	failSMKF1 = true
+	runOnce(context.Background(), cfg, sink)
+	check()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// csvName returns the CSV sidecar name for a Parquet file name.
+func csvName(parquetName string) string {
+	return strings.TrimSuffix(parquetName, ".parquet") + ".csv"
+}
+
+// csvCell formats r's value for c; missing values are empty.
+func csvCell(c metrow.Column, r *metrow.MetRow) string {
+	switch {
+	case c.IsNull(r):
+		return ""
+	case c.I32 != nil:
+		return strconv.FormatInt(int64(*c.I32(r)), 10)
+	case c.U32 != nil:
+		return strconv.FormatUint(uint64(c.U32(r)), 10)
+	case c.TS != nil:
+		// Zone-less local time: RFC 3339 without the offset.
+		return time.Unix(*c.TS(r), 0).UTC().Format("2006-01-02T15:04:05")
+	case c.Str != nil:
+		return *c.Str(r)
+	default:
+		return strconv.FormatFloat(*c.F64(r), 'f', -1, 64)
+	}
+}
+
+// encodeCSV renders rows as CSV with a header row: station_id, time
+// (RFC 3339 UTC), then cols.
+func encodeCSV(cols []metrow.Column, rows []metrow.MetRow) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"station_id", "time"}
+	for _, c := range cols {
+		header = append(header, c.Name)
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	rec := make([]string, len(header))
+	for i := range rows {
+		r := &rows[i]
+		rec[0] = r.StationID
+		rec[1] = time.Unix(r.Time, 0).UTC().Format(time.RFC3339)
+		for j, c := range cols {
+			rec[j+2] = csvCell(c, r)
+		}
+		if err := w.Write(rec); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeCSV stores the CSV sidecar name next to the Parquet file. Failures
+// are logged; the Parquet file is already written.
+func writeCSV(sink Sink, name string, cols []metrow.Column, rows []metrow.MetRow) {
+	b, err := encodeCSV(cols, rows)
+	if err == nil {
+		err = sink.Write(name, b)
+	}
+	if err != nil {
+		log.Printf("ERROR write %s: %v", name, err)
+		return
+	}
+	log.Printf("WROTE %s/%s (%d rows)", sink, name, len(rows))
+}
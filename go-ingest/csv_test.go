@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestEncodeCSV(t *testing.T) {
+	cols, err := metrow.SelectColumns("wdir_deg,wspd_ms,qc_flags,wdir_card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, card, wspd := int32(120), "ESE", 5.25
+	rows := []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718000000, WDIRDeg: &dir, WSPDmS: &wspd, QCFlags: 3, WDIRCardinal: &card},
+		{StationID: "SANF1", Time: 1718003600},
+	}
+	b, err := encodeCSV(cols, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "station_id,time,wdir_deg,wspd_ms,qc_flags,wdir_card\n" +
+		"SANF1,2024-06-10T06:13:20Z,120,5.25,3,ESE\n" +
+		"SANF1,2024-06-10T07:13:20Z,,,0,\n"
+	if string(b) != want {
+		t.Errorf("CSV:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestCSVSidecarName(t *testing.T) {
+	if got := csvName("SANF1_latest.parquet"); got != "SANF1_latest.csv" {
+		t.Errorf("csvName = %q", got)
+	}
+	if got := contentTypeOf("SANF1_latest.csv"); !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("content type = %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// processDart is processStation for MODE=dart: it fetches <station>.dart,
+// parses the water-column heights, and writes them oldest first to the
+// station's DART_FILENAME_TEMPLATE file. The file is always rewritten, so
+// it always reports true with a nil error.
+func processDart(ctx context.Context, cfg config, sink Sink, s string) (metrow.ManifestEntry, bool, error) {
+	b, src, err := fetchBody(ctx, cfg.Clock, s, "dart")
+	if err != nil {
+		if errors.Is(err, ErrStationNotFound) {
+			log.Printf("WARN  %s: no DART data at NDBC (check STATIONS): %v", s, err)
+		} else {
+			log.Printf("WARN  %s: %v", s, err)
+		}
+		return metrow.ManifestEntry{}, false, err
+	}
+	var units map[string]string
+	var nulled map[string]int
+	rows, err := ndbc.ParseDart(b, ndbc.WithStation(s), ndbc.WithMaxRows(cfg.MaxRows),
+		ndbc.WithYearPivot(cfg.YearPivot), ndbc.WithFormat(cfg.Format), ndbc.WithUnits(&units),
+		ndbc.WithSentinelCounts(&nulled))
+	if err != nil {
+		log.Printf("ERROR %s: parse dart: %v", s, err)
+		return metrow.ManifestEntry{}, false, fmt.Errorf("%s: parse dart: %w", s, err)
+	}
+	sentinels.add(s, nulled)
+	if len(rows) == 0 {
+		log.Printf("INFO  %s: no rows parsed", s)
+		return metrow.ManifestEntry{}, false, errNoRows
+	}
+	events := 0
+	for _, r := range rows {
+		if r.Event {
+			events++
+		}
+	}
+	if events > 0 {
+		log.Printf("INFO  %s: %d event-mode readings", s, events)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Time < rows[j].Time })
+	meta := fileMeta(units, src)
+	meta[metrow.SortedKey] = "time"
+	meta[metrow.ToolVersionKey] = metrow.Version
+	meta[metrow.BatchIDKey] = cfg.BatchID
+	var buf bytes.Buffer
+	if err := ndbc.WriteDartParquet(&buf, rows, meta); err != nil {
+		log.Printf("ERROR %s: encode parquet: %v", s, err)
+		return metrow.ManifestEntry{}, false, fmt.Errorf("%s: encode parquet: %w", s, err)
+	}
+	out := cfg.DartFilename.Expand(s, cfg.Clock.Now())
+	err = sink.Write(out, buf.Bytes())
+	if err == nil {
+		err = writeChecksum(sink, out, buf.Bytes())
+	}
+	if err != nil {
+		log.Printf("ERROR %s: write parquet: %v", s, err)
+		return metrow.ManifestEntry{}, false, fmt.Errorf("%s: write parquet: %w", s, err)
+	}
+	log.Printf("WROTE %s/%s (%d rows)", sink, out, len(rows))
+	return metrow.ManifestEntry{
+		Station: strings.ToUpper(s),
+		Path:    out,
+		Rows:    len(rows),
+		Newest:  time.Unix(rows[len(rows)-1].Time, 0).UTC(),
+	}, true, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+func TestDartModeWritesDartFile(t *testing.T) {
+	var paths []string
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("#YY  MM DD hh mm ss T   HEIGHT\n" +
+			"#yr  mo dy hr mn  s -      m\n" +
+			"2024 05 01 12 15 00 1 5812.345\n" +
+			"2024 05 01 12 00 00 1 5812.301\n"))
+	})
+	t.Setenv("MODE", "dart")
+	t.Setenv("STATIONS", "21413")
+	cfg := loadConfig()
+	cfg.DataDir = t.TempDir()
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+
+	if len(paths) != 1 || !strings.HasSuffix(paths[0], "/21413.dart") {
+		t.Errorf("fetched %v, want 21413.dart", paths)
+	}
+	f, err := os.Open(filepath.Join(cfg.DataDir, "21413_dart.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := ndbc.ReadDartParquet(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Time >= rows[1].Time || rows[0].StationID != "21413" {
+		t.Errorf("rows = %+v, want 2 oldest first", rows)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, metrow.ManifestName)); err != nil {
+		t.Errorf("manifest: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// Fetch failure classes. fetchStation wraps one of these so callers can
+// tell them apart with errors.Is.
+var (
+	// ErrStationNotFound means NDBC has no realtime file for the station (404).
+	ErrStationNotFound = errors.New("station not found")
+	// ErrTransient covers failures worth retrying: network errors,
+	// timeouts, 429 and 5xx responses.
+	ErrTransient = errors.New("transient fetch failure")
+	// ErrBadContent means NDBC answered 200 with something that is not a
+	// realtime2 text file, typically an HTML error page.
+	ErrBadContent = errors.New("unexpected content")
+	// ErrBodyTooLarge means the response exceeded MAX_BODY_BYTES. It is
+	// also an ErrBadContent.
+	ErrBodyTooLarge = errors.New("response body too large")
+)
+
+// ndbcRoot is the NDBC data directory the realtime files are fetched from.
+var ndbcRoot = "https://www.ndbc.noaa.gov/data"
+
+// DATA_WINDOW values: how far back the fetched realtime files go.
+const (
+	window45Day = "45day" // realtime2/<STATION>.<ext>
+	window5Day  = "5day"  // 5day2/<STATION>_5day.<ext>
+)
+
+// dataWindow is the realtime window fetched (DATA_WINDOW).
+var dataWindow = window45Day
+
+// stationURL returns the URL of station's realtime file with extension ext
+// in window, under ndbcRoot.
+func stationURL(window, station, ext string) string {
+	station = strings.ToUpper(station)
+	if window == window5Day {
+		return fmt.Sprintf("%s/5day2/%s_5day.%s", ndbcRoot, station, ext)
+	}
+	return fmt.Sprintf("%s/realtime2/%s.%s", ndbcRoot, station, ext)
+}
+
+// defaultMaxBodyBytes is far above any realtime2 file (45 days of 10-minute
+// rows is well under 1 MB).
+const defaultMaxBodyBytes = 8 << 20
+
+// maxBodyBytes caps how much of a response is read (MAX_BODY_BYTES), so a
+// misbehaving upstream cannot exhaust memory.
+var maxBodyBytes int64 = defaultMaxBodyBytes
+
+// Retry policy for transient failures.
+const (
+	maxFetchRetries   = 2
+	retryBackoff      = 2 * time.Second
+	defaultRetryAfter = 30 * time.Second
+	maxRetryAfter     = 5 * time.Minute
+)
+
+// retryAfter parses a Retry-After header given either as delay seconds or
+// as an HTTP date. Missing or malformed values yield defaultRetryAfter; the
+// result is capped at maxRetryAfter.
+func retryAfter(v string, now time.Time) time.Duration {
+	d := defaultRetryAfter
+	if v = strings.TrimSpace(v); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			d = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			d = max(t.Sub(now), 0)
+		}
+	}
+	return min(d, maxRetryAfter)
+}
+
+// looksLikeHTML reports whether a response is an HTML page rather than
+// NDBC text.
+func looksLikeHTML(contentType string, body []byte) bool {
+	if strings.HasPrefix(contentType, "text/html") {
+		return true
+	}
+	head := bytes.ToLower(bytes.TrimSpace(body[:min(len(body), 512)]))
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
+
+// Connection pool defaults for NDBC (MAX_IDLE_CONNS, MAX_CONNS_PER_HOST).
+// Every fetch goes to one host, so its idle connections may use the whole
+// pool rather than net/http's default of two per host.
+const (
+	defaultMaxIdleConns    = 16
+	defaultMaxConnsPerHost = 16
+)
+
+// ndbcClient makes the NDBC requests; main replaces it with one built by
+// newNDBCClient.
+var ndbcClient = http.DefaultClient
+
+// newNDBCClient returns a client keeping up to maxIdle idle connections,
+// all of them available to a single host, and opening at most perHost
+// connections to a host (0 means no limit).
+func newNDBCClient(maxIdle, perHost int) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = maxIdle
+	t.MaxIdleConnsPerHost = maxIdle
+	t.MaxConnsPerHost = perHost
+	return &http.Client{Transport: t}
+}
+
+// fetchOnce makes one request. For retryable failures it also returns how
+// long to wait before the next attempt (0 = use the default backoff).
+func fetchOnce(ctx context.Context, clk clock.Clock, station, u string) ([]byte, time.Duration, error) {
+	if err := fetchLimiter.Wait(ctx); err != nil {
+		return nil, 0, fmt.Errorf("fetch %s: rate limit wait: %w", station, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := ndbcClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, fmt.Errorf("fetch %s: %w", station, err)
+		}
+		return nil, 0, fmt.Errorf("fetch %s: %w: %w", station, ErrTransient, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, 0, fmt.Errorf("fetch %s: HTTP 404: %w", station, ErrStationNotFound)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait := retryAfter(resp.Header.Get("Retry-After"), clk.Now())
+		return nil, wait, fmt.Errorf("fetch %s: HTTP 429: %w", station, ErrTransient)
+	case resp.StatusCode >= 500:
+		var wait time.Duration
+		if v := resp.Header.Get("Retry-After"); v != "" {
+			wait = retryAfter(v, clk.Now())
+		}
+		return nil, wait, fmt.Errorf("fetch %s: HTTP %d: %w", station, resp.StatusCode, ErrTransient)
+	case resp.StatusCode != http.StatusOK:
+		return nil, 0, fmt.Errorf("fetch %s: HTTP %d", station, resp.StatusCode)
+	}
+	// Read one byte past the limit to tell "exactly at" from "over".
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil {
+		return nil, 0, fmt.Errorf("fetch %s: read body: %w: %w", station, ErrTransient, err)
+	}
+	if int64(len(b)) > maxBodyBytes {
+		return nil, 0, fmt.Errorf("fetch %s: more than %d bytes: %w: %w", station, maxBodyBytes, ErrBadContent, ErrBodyTooLarge)
+	}
+	if looksLikeHTML(resp.Header.Get("Content-Type"), b) {
+		return nil, 0, fmt.Errorf("fetch %s: got HTML page: %w", station, ErrBadContent)
+	}
+	return b, 0, nil
+}
+
+// provenance records where and when a file's data was fetched.
+type provenance struct {
+	URL     string
+	Fetched time.Time
+}
+
+// fileMeta returns the Parquet key/value metadata for data fetched from
+// src: the NDBC units line (when known) and the provenance keys.
+func fileMeta(units map[string]string, src provenance) map[string]string {
+	meta := map[string]string{
+		metrow.SourceURLKey: src.URL,
+		metrow.FetchedAtKey: src.Fetched.UTC().Format(time.RFC3339),
+	}
+	if units != nil {
+		if b, err := json.Marshal(units); err == nil {
+			meta[metrow.UnitsKey] = string(b)
+		}
+	}
+	return meta
+}
+
+// fetchBody downloads station's realtime file with extension ext in
+// dataWindow (see stationURL), retrying ErrTransient failures up to
+// maxFetchRetries times. The wait honors the server's Retry-After, else
+// backs off exponentially from retryBackoff; cancelling ctx ends it early.
+func fetchBody(ctx context.Context, clk clock.Clock, station, ext string) ([]byte, provenance, error) {
+	u := stationURL(dataWindow, station, ext)
+	for attempt := 0; ; attempt++ {
+		b, wait, err := fetchOnce(ctx, clk, station, u)
+		if err == nil || !errors.Is(err, ErrTransient) || attempt >= maxFetchRetries {
+			return b, provenance{URL: u, Fetched: clk.Now()}, err
+		}
+		if wait == 0 {
+			wait = retryBackoff << attempt
+		}
+		log.Printf("WARN  %s: %v, retrying in %s", station, err, wait)
+		select {
+		case <-ctx.Done():
+			return nil, provenance{}, fmt.Errorf("fetch %s: waiting to retry: %w", station, ctx.Err())
+		case <-clk.After(wait):
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+)
+
+func TestFetchErrorClasses(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.HandlerFunc
+		want error
+	}{
+		{"404", http.NotFound, ErrStationNotFound},
+		{"503", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}, ErrTransient},
+		{"429", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}, ErrTransient},
+		{"html", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>maintenance</body></html>"))
+		}, ErrBadContent},
+		{"html sniffed", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("\n<!DOCTYPE html><html></html>"))
+		}, ErrBadContent},
+	}
+	for _, tt := range tests {
+		stubNDBC(t, tt.h)
+		_, _, err := fetchOnce(context.Background(), clock.Real{}, "SANF1", ndbcRoot+"/realtime2/SANF1.txt")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestFetchPermanentFailuresNotRetried(t *testing.T) {
+	for name, h := range map[string]http.HandlerFunc{
+		"404": http.NotFound,
+		"html": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		},
+	} {
+		var calls atomic.Int32
+		stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			h(w, r)
+		})
+		if _, _, err := fetchBody(context.Background(), clock.Real{}, "SANF1", "txt"); err == nil || calls.Load() != 1 {
+			t.Errorf("%s: err %v after %d calls, want 1", name, err, calls.Load())
+		}
+	}
+}
+
+func TestFetchBodyTooLarge(t *testing.T) {
+	old := maxBodyBytes
+	maxBodyBytes = 64
+	t.Cleanup(func() { maxBodyBytes = old })
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("2024 05 01 12 00 120 5.0\n", 10)))
+	})
+	_, _, err := fetchBody(context.Background(), clock.Real{}, "SANF1", "txt")
+	if !errors.Is(err, ErrBodyTooLarge) || !errors.Is(err, ErrBadContent) {
+		t.Errorf("err = %v, want ErrBodyTooLarge", err)
+	}
+
+	// A body of exactly the limit is fine.
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 64)))
+	})
+	if b, _, err := fetchBody(context.Background(), clock.Real{}, "SANF1", "txt"); err != nil || len(b) != 64 {
+		t.Errorf("at the limit: %d bytes, err %v", len(b), err)
+	}
+}
+
+func TestFetchRetriesAfter429(t *testing.T) {
+	var calls atomic.Int32
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(stdmetHeader))
+	})
+	b, _, err := fetchBody(context.Background(), clock.Real{}, "SANF1", "txt")
+	if err != nil || string(b) != stdmetHeader || calls.Load() != 2 {
+		t.Errorf("err %v after %d calls", err, calls.Load())
+	}
+
+	// Retries are bounded; the last 429 is returned as an error.
+	calls.Store(0)
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	if _, _, err := fetchBody(context.Background(), clock.Real{}, "SANF1", "txt"); err == nil || calls.Load() != maxFetchRetries+1 {
+		t.Errorf("err %v after %d calls, want failure after %d", err, calls.Load(), maxFetchRetries+1)
+	}
+}
+
+func TestFetchRetryWaitCancelled(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	// The fake clock never advances, so only the cancellation ends the wait.
+	clk := clock.NewFake(time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := fetchBody(ctx, clk, "SANF1", "txt")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestFetchRetryWaitsOnClock(t *testing.T) {
+	var calls atomic.Int32
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(stdmetHeader))
+	})
+	clk := clock.NewFake(time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC))
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := fetchBody(context.Background(), clk, "SANF1", "txt")
+		done <- err
+	}()
+	for clk.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(59 * time.Second)
+	if calls.Load() != 1 {
+		t.Fatalf("retried after 59s of a 60s Retry-After")
+	}
+	clk.Advance(time.Second)
+	if err := <-done; err != nil || calls.Load() != 2 {
+		t.Errorf("err %v after %d calls, want success after 2", err, calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v    string
+		want time.Duration
+	}{
+		{"", defaultRetryAfter},
+		{"12", 12 * time.Second},
+		{"soon", defaultRetryAfter},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"86400", maxRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.v, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestNDBCClientConnectionLimits(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		active, peak int
+		remotes      = map[string]bool{}
+	)
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		remotes[r.RemoteAddr] = true
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		w.Write([]byte(stdmetHeader))
+	})
+	old := ndbcClient
+	ndbcClient = newNDBCClient(4, 1)
+	t.Cleanup(func() { ndbcClient = old })
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := fetchBody(context.Background(), clock.Real{}, "SANF1", "txt"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// MAX_CONNS_PER_HOST=1 queues the requests on one reused connection.
+	if peak != 1 || len(remotes) != 1 {
+		t.Errorf("%d concurrent requests over %d connections, want 1 and 1", peak, len(remotes))
+	}
+}
+
+func TestDataWindowPaths(t *testing.T) {
+	for _, tt := range []struct{ window, want string }{
+		{window45Day, "/realtime2/SANF1.txt"},
+		{window5Day, "/5day2/SANF1_5day.txt"},
+	} {
+		var path string
+		stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.Write([]byte("#YY  MM DD hh mm WSPD\n"))
+		})
+		old := dataWindow
+		dataWindow = tt.window
+		_, _, err := fetchBody(context.Background(), clock.Real{}, "sanf1", "txt")
+		dataWindow = old
+		if err != nil || path != tt.want {
+			t.Errorf("DATA_WINDOW=%s: fetched %q (err %v), want %q", tt.window, path, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// fetchStatus holds every station's metrow.FetchStatus. It is loaded from
+// the sink on the first cycle so it survives restarts, and is only touched
+// by runOnce.
+var fetchStatus map[string]metrow.FetchStatus
+
+// loadFetchStatus reads metrow.FetchStatusName from sink once. A missing or
+// unreadable file starts an empty record.
+func loadFetchStatus(sink Sink) {
+	if fetchStatus != nil {
+		return
+	}
+	fetchStatus = make(map[string]metrow.FetchStatus)
+	b, err := sink.Read(metrow.FetchStatusName)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(b, &fetchStatus); err != nil {
+		log.Printf("WARN  %s: %v; starting afresh", metrow.FetchStatusName, err)
+		fetchStatus = make(map[string]metrow.FetchStatus)
+	}
+}
+
+// recordFetch notes an attempt at station at time at. errNoRows counts as
+// a success: the fetch worked, there was just nothing new to write. An
+// all-null placeholder (errAllNull) does not.
+func recordFetch(station string, at time.Time, err error) {
+	s := fetchStatus[station]
+	s.LastAttempt = at
+	s.LastError = ""
+	if err == nil || errors.Is(err, errNoRows) && !errors.Is(err, errAllNull) {
+		s.LastSuccess = &at
+	} else {
+		s.LastError = err.Error()
+	}
+	fetchStatus[station] = s
+}
+
+// writeFetchStatus stores fetchStatus as metrow.FetchStatusName through sink.
+func writeFetchStatus(sink Sink) error {
+	b, err := json.MarshalIndent(fetchStatus, "", "  ")
+	if err != nil {
+		return err
+	}
+	return sink.Write(metrow.FetchStatusName, append(b, '\n'))
+}
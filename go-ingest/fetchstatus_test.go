@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestFetchStatusAcrossCycles(t *testing.T) {
+	var failing atomic.Bool
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"))
+	})
+	first := time.Date(2024, 6, 10, 8, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(first)
+	cfg := config{Stations: []string{"SANF1"}, DataDir: t.TempDir(), Clock: fake, MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	read := func() map[string]metrow.FetchStatus {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(cfg.DataDir, metrow.FetchStatusName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out map[string]metrow.FetchStatus
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	fetchStatus = nil
+	t.Cleanup(func() { fetchStatus = nil })
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+	s := read()["SANF1"]
+	if !s.LastAttempt.Equal(first) || s.LastSuccess == nil || !s.LastSuccess.Equal(first) || s.Stale() {
+		t.Fatalf("after success: %+v", s)
+	}
+
+	// A restart reloads the record; the failed attempt keeps the success.
+	fetchStatus = nil
+	failing.Store(true)
+	second := first.Add(time.Hour)
+	fake.Set(second)
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+	s = read()["SANF1"]
+	if !s.LastAttempt.Equal(second) || s.LastSuccess == nil || !s.LastSuccess.Equal(first) || s.LastError == "" || !s.Stale() {
+		t.Errorf("after failure: %+v", s)
+	}
+}
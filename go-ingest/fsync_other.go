@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// syncDir is a no-op where directories cannot be opened for fsync; the
+// rename is as durable as the platform makes it.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import "os"
+
+// syncDir fsyncs directory dir, making a rename inside it durable.
+func syncDir(dir string) error {
+	d, err := openSync(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
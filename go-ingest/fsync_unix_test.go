@@ -0,0 +1,56 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// recordingFile notes each Sync on the file it wraps.
+type recordingFile struct {
+	*os.File
+	synced *[]string
+}
+
+func (f recordingFile) Sync() error {
+	*f.synced = append(*f.synced, f.Name())
+	return f.File.Sync()
+}
+
+func TestDurableWriteSyncsFileAndDir(t *testing.T) {
+	var synced []string
+	old := openSync
+	openSync = func(name string, flag int, perm os.FileMode) (syncFile, error) {
+		f, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return recordingFile{f, &synced}, nil
+	}
+	t.Cleanup(func() { openSync = old })
+
+	dir := t.TempDir()
+	s := testSink(dir)
+	if err := s.Write("sub/SANF1_latest.parquet", []byte("PAR1")); err != nil {
+		t.Fatal(err)
+	}
+	if len(synced) != 0 {
+		t.Errorf("synced %q without DURABLE_WRITE", synced)
+	}
+
+	s.durable = true
+	if err := s.Write("sub/SANF1_latest.parquet", []byte("PAR2")); err != nil {
+		t.Fatal(err)
+	}
+	// The data reaches disk before the rename, the rename after it.
+	p := filepath.Join(dir, "sub", "SANF1_latest.parquet")
+	if want := []string{p + ".tmp", filepath.Dir(p)}; !slices.Equal(synced, want) {
+		t.Errorf("synced %q, want %q", synced, want)
+	}
+	if got, err := os.ReadFile(p); err != nil || string(got) != "PAR2" {
+		t.Errorf("read back %q, %v", got, err)
+	}
+}
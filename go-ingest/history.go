@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// mergeHistory combines freshly fetched rows with those already stored in
+// the station file name, so the file accumulates history across cycles.
+// A fetched row replaces a stored one with the same station and timestamp.
+// Rows more than retention older than their station's newest observation
+// are then dropped, which bounds the file size. A missing or unreadable
+// stored file just means there is no history yet.
+func mergeHistory(sink Sink, name string, rows []metrow.MetRow, retention time.Duration) []metrow.MetRow {
+	merged := rows
+	if b, err := sink.Read(name); err == nil {
+		old, err := ndbc.ReadParquet(bytes.NewReader(b))
+		if err != nil {
+			log.Printf("WARN  %s: ignoring unreadable history: %v", name, err)
+		} else {
+			fresh := make(map[rowKey]bool, len(rows))
+			for _, r := range rows {
+				fresh[rowKey{r.StationID, r.Time}] = true
+			}
+			merged = make([]metrow.MetRow, 0, len(old)+len(rows))
+			for _, r := range old {
+				if !fresh[rowKey{r.StationID, r.Time}] {
+					merged = append(merged, r)
+				}
+			}
+			merged = append(merged, rows...)
+		}
+	}
+	return pruneOlderThan(merged, retention)
+}
+
+// rowKey identifies an observation; a combined file (COMBINED_OUTPUT)
+// holds several stations with the same timestamps.
+type rowKey struct {
+	station string
+	time    int64
+}
+
+// pruneOlderThan drops rows observed more than window before the newest
+// row of the same station.
+func pruneOlderThan(rows []metrow.MetRow, window time.Duration) []metrow.MetRow {
+	newest := make(map[string]int64)
+	for _, r := range rows {
+		if t, ok := newest[r.StationID]; !ok || r.Time > t {
+			newest[r.StationID] = r.Time
+		}
+	}
+	kept := rows[:0]
+	for _, r := range rows {
+		if r.Time >= newest[r.StationID]-int64(window/time.Second) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// hourly returns one row of station per hour from hour from to hour to
+// (inclusive) after 2024-06-10T00:00Z, with WSPD set to v.
+func hourly(station string, from, to int, v float64) []metrow.MetRow {
+	base := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC).Unix()
+	var rows []metrow.MetRow
+	for h := from; h <= to; h++ {
+		w := v
+		rows = append(rows, metrow.MetRow{StationID: station, Time: base + int64(h)*3600, WSPDmS: &w})
+	}
+	return rows
+}
+
+// readStored returns the rows of the parquet file name in sink.
+func readStored(t *testing.T, sink Sink, name string) []metrow.MetRow {
+	t.Helper()
+	b, err := sink.Read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ndbc.ReadParquet(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestMergeHistoryRetention(t *testing.T) {
+	sink := testSink(t.TempDir())
+	const name = "RETA1_latest.parquet"
+	if _, err := writeParquet(sink, name, loadConfig().Columns, hourly("RETA1", 0, 90, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readStored(t, sink, name)); n != 91 {
+		t.Fatalf("stored %d rows, want 91", n)
+	}
+
+	// The fetch overlaps the stored hours 80-90 and runs on to hour 100.
+	rows := mergeHistory(sink, name, hourly("RETA1", 80, 100, 2), 48*time.Hour)
+	if len(rows) != 49 {
+		t.Fatalf("%d rows, want 49 (hours 52-100)", len(rows))
+	}
+	newest := hourly("RETA1", 100, 100, 0)[0].Time
+	oldest := newest
+	for _, r := range rows {
+		oldest = min(oldest, r.Time)
+		fetched := r.Time >= newest-20*3600
+		if fetched && *r.WSPDmS != 2 || !fetched && *r.WSPDmS != 1 {
+			t.Errorf("hour %d: WSPD %g", (r.Time-newest)/3600+100, *r.WSPDmS)
+		}
+	}
+	if oldest != newest-48*3600 {
+		t.Errorf("oldest row %d h before the newest, want 48", (newest-oldest)/3600)
+	}
+}
+
+func TestMergeHistoryWithoutStoredFile(t *testing.T) {
+	sink := testSink(t.TempDir())
+	rows := mergeHistory(sink, "RETB1_latest.parquet", hourly("RETB1", 0, 9, 1), 48*time.Hour)
+	if len(rows) != 10 {
+		t.Errorf("%d rows, want the 10 fetched", len(rows))
+	}
+}
+
+func TestMergeHistoryIgnoresUnreadableFile(t *testing.T) {
+	sink := testSink(t.TempDir())
+	const name = "RETC1_latest.parquet"
+	if err := sink.Write(name, []byte("not parquet")); err != nil {
+		t.Fatal(err)
+	}
+	rows := mergeHistory(sink, name, hourly("RETC1", 0, 9, 1), 48*time.Hour)
+	if len(rows) != 10 {
+		t.Errorf("%d rows, want the 10 fetched", len(rows))
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// Lock is a no-op where flock is unavailable; concurrent writers to one
+// DATA_DIR are not serialized there.
+func (s localSink) Lock(name string) (func(), error) {
+	return func() {}, nil
+}
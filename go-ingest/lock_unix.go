@@ -0,0 +1,42 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Lock takes an exclusive flock on name's ".lock" file, blocking until any
+// other writer (another cycle or go-ingest instance on the same DATA_DIR)
+// releases it. The lock file is left in place: removing it would let a
+// waiter lock an unlinked inode while a newcomer locks a fresh one.
+func (s localSink) Lock(name string) (func(), error) {
+	p := filepath.Join(s.dir, name) + ".lock"
+	if err := s.mkdirAll(filepath.Dir(p)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR, s.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, err
+		}
+		log.Printf("INFO  %s: waiting for another writer to finish", name)
+		if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return func() {
+		syscall.Flock(fd, syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockExcludesSecondWriter(t *testing.T) {
+	sink := testSink(t.TempDir())
+	unlock, err := lockFile(sink, "sub/A_latest.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan func())
+	go func() {
+		u, err := lockFile(sink, "sub/A_latest.parquet")
+		if err != nil {
+			t.Error(err)
+			u = func() {}
+		}
+		locked <- u
+	}()
+	select {
+	case <-locked:
+		t.Fatal("second Lock returned while the first was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case u := <-locked:
+		u()
+	case <-time.After(5 * time.Second):
+		t.Fatal("second Lock still blocked after unlock")
+	}
+
+	// Other files are not serialized against each other.
+	u1, err := lockFile(sink, "A_latest.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u1()
+	u2, err := lockFile(sink, "B_latest.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u2()
+}
@@ -1,266 +1,860 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
-	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	parquet "github.com/parquet-go/parquet-go"
+	"github.com/google/uuid"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
 )
 
-// MetRow is the schema for each observation row written to Parquet.
-type MetRow struct {
-	StationID string   `parquet:"station_id"`
-	Time      int64    `parquet:"time"`
-	WDIRDeg   *int32   `parquet:"wdir_deg"`
-	WSPDmS    *float64 `parquet:"wspd_ms"`
-	GUSTmS    *float64 `parquet:"gust_ms"`
-	PREShPa   *float64 `parquet:"pres_hpa"`
-	ATMPC     *float64 `parquet:"atmp_c"`
-	WTMPC     *float64 `parquet:"wtmp_c"`
-	DEWPC     *float64 `parquet:"dewp_c"`
-}
+// fetchLimiter gates every request to NDBC; nil means unlimited.
+var fetchLimiter *limiter
 
-const ndbcBase = "https://www.ndbc.noaa.gov/data/realtime2"
+// measurementFields is the number of measurement fields nullFields counts.
+const measurementFields = 7
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// nullFields counts the nil measurement fields in r (derived columns excluded).
+func nullFields(r metrow.MetRow) int {
+	n := 0
+	if r.WDIRDeg == nil {
+		n++
+	}
+	for _, p := range []*float64{r.WSPDmS, r.GUSTmS, r.PREShPa, r.ATMPC, r.WTMPC, r.DEWPC} {
+		if p == nil {
+			n++
+		}
 	}
-	return def
+	return n
 }
 
-// atoiP parses an integer, returning nil for sentinel values (99, 999, 9999).
-func atoiP(s string) *int32 {
-	if s == "" {
-		return nil
+// allNull reports whether rows is non-empty and every measurement of every
+// row is missing: the placeholder file NDBC serves for an offline station.
+func allNull(rows []metrow.MetRow) bool {
+	for _, r := range rows {
+		if nullFields(r) < measurementFields {
+			return false
+		}
 	}
-	v, err := strconv.ParseInt(s, 10, 64)
-	if err != nil || v == 99 || v == 999 || v == 9999 {
-		return nil
+	return len(rows) > 0
+}
+
+// dropSparse removes rows with more than maxNull nil measurement fields.
+func dropSparse(rows []metrow.MetRow, maxNull int) []metrow.MetRow {
+	out := rows[:0]
+	for _, r := range rows {
+		if nullFields(r) <= maxNull {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// config holds the ingest settings read from the environment at startup.
+type config struct {
+	Stations       []string
+	DataDir        string
+	RefreshMinutes int
+	// CycleTimeout bounds a whole runOnce cycle; zero means no deadline.
+	CycleTimeout time.Duration
+	QCChecks     bool
+	// MaxNullFields drops rows with more nil measurements than this; -1 disables.
+	MaxNullFields int
+	YearPivot     int
+	// Dedup picks which row of a repeated timestamp survives (DEDUP_KEEP).
+	Dedup ndbc.DedupMode
+	// Direction handles wind directions outside 0–360 (WDIR_MODE).
+	Direction ndbc.DirectionMode
+	// TimeISO adds the time_iso string column (TIME_ISO).
+	TimeISO bool
+	// TruncateHour aligns row times to the top of the hour, keeping the
+	// latest reading per hour (TRUNCATE_TO_HOUR).
+	TruncateHour bool
+	// BatchID is the UUID of the running cycle, stamped into every file it
+	// writes; runOnce sets it.
+	BatchID string
+	// SkipAllNull leaves a station's file alone when every fetched
+	// measurement is missing (SKIP_ALL_NULL).
+	SkipAllNull bool
+	// Format selects whitespace or fixed-width field splitting (FORMAT).
+	Format ndbc.Format
+	// Combined writes every station into one file named by
+	// Filename.ExpandCombined instead of one file each (COMBINED_OUTPUT).
+	Combined bool
+	// Columns is the COLUMNS allow-list of measurement columns to write.
+	Columns []metrow.Column
+	// RequestsPerSecond caps NDBC fetches; 0 means unlimited.
+	RequestsPerSecond float64
+	// Filename names each station's output file (FILENAME_TEMPLATE).
+	Filename metrow.FilenameTemplate
+	// MaxRows caps rows kept per station and cycle (newest first).
+	MaxRows int
+	// Concurrency is how many stations are processed at once.
+	Concurrency int
+	// Longitudes maps station → longitude for solar_time; stations
+	// without an entry get a null solar_time.
+	Longitudes map[string]float64
+	// StationRefresh overrides RefreshMinutes per station (STATION_REFRESH).
+	StationRefresh map[string]time.Duration
+	// Jitter randomly stretches or shrinks each sleep by up to this
+	// fraction (REFRESH_JITTER); zero disables it.
+	Jitter float64
+	// Retention turns on history: new rows are merged into the stored file
+	// and rows older than this before the newest are dropped
+	// (RETENTION_HOURS); zero overwrites the file every cycle.
+	Retention time.Duration
+	// WriteCSV also writes a CSV copy of each station file (WRITE_CSV).
+	WriteCSV bool
+	// Clock supplies the current time; clock.Real outside tests.
+	Clock clock.Clock
+	// FailFast aborts the cycle on the first station failure (FAIL_FAST).
+	FailFast bool
+	// WebhookURL is POSTed a summary after every completed cycle that
+	// wrote at least one file (WEBHOOK_URL); empty disables it.
+	WebhookURL string
+	// Mode is the NDBC product to ingest: "stdmet" or "dart" (MODE).
+	Mode string
+	// DartFilename names DART output files (DART_FILENAME_TEMPLATE).
+	DartFilename metrow.FilenameTemplate
+}
+
+// parseFraction parses a fraction written as a decimal ("0.1") or a
+// percentage ("10%").
+func parseFraction(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	if p, ok := strings.CutSuffix(v, "%"); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		return f / 100, err
 	}
-	x := int32(v)
-	return &x
+	return strconv.ParseFloat(v, 64)
 }
 
-// atofP parses a float, returning nil for sentinel values.
-func atofP(s string) *float64 {
-	if s == "" {
-		return nil
+// excludeStations removes the comma-separated denylist from stations
+// (case-insensitive) and logs what it removed.
+func excludeStations(stations []string, denylist string) []string {
+	deny := make(map[string]bool)
+	for _, d := range strings.Split(denylist, ",") {
+		if d = strings.ToUpper(strings.TrimSpace(d)); d != "" {
+			deny[d] = true
+		}
 	}
-	v, err := strconv.ParseFloat(s, 64)
-	if err != nil || v == 99 || v == 999 || v == 9999 {
-		return nil
+	if len(deny) == 0 {
+		return stations
 	}
-	return &v
+	var kept, excluded []string
+	for _, s := range stations {
+		if deny[strings.ToUpper(strings.TrimSpace(s))] {
+			excluded = append(excluded, strings.TrimSpace(s))
+			continue
+		}
+		kept = append(kept, s)
+	}
+	if len(excluded) > 0 {
+		log.Printf("INFO  excluding stations (STATIONS_EXCLUDE): %s", strings.Join(excluded, ","))
+	}
+	return kept
 }
 
-func get(cols []string, idx map[string]int, key string) string {
-	if i, ok := idx[strings.ToUpper(key)]; ok && i >= 0 && i < len(cols) {
-		return cols[i]
+// hasColumn reports whether cols includes the column called name.
+func hasColumn(cols []metrow.Column, name string) bool {
+	for _, c := range cols {
+		if c.Name == name {
+			return true
+		}
 	}
-	return ""
+	return false
 }
 
-// parseNdbcStdMet parses NDBC standard meteorological text data.
-// It dynamically finds the header line and maps columns by name.
-func parseNdbcStdMet(station string, body []byte, maxRows int) ([]MetRow, error) {
-	r := bufio.NewReader(bytes.NewReader(body))
-	var header []string
-	var data [][]string
+// withColumn adds the opt-in column name to cols unless already selected.
+func withColumn(cols []metrow.Column, name string) []metrow.Column {
+	if hasColumn(cols, name) {
+		return cols
+	}
+	if c, ok := metrow.LookupColumn(name); ok {
+		cols = append(cols, c)
+	}
+	return cols
+}
 
-	for {
-		lineBytes, _, err := r.ReadLine()
-		if errors.Is(err, io.EOF) {
-			break
-		}
+func loadConfig() config {
+	mins, _ := strconv.Atoi(metrow.Getenv("REFRESH_MINUTES", "60"))
+	qc, _ := strconv.ParseBool(metrow.Getenv("QC_CHECKS", "false"))
+	wc, _ := strconv.ParseBool(metrow.Getenv("WIND_CHILL", "false"))
+
+	// Default the cycle deadline to the refresh interval so cycles never overlap.
+	timeout := time.Duration(mins) * time.Minute
+	if v := metrow.Getenv("CYCLE_TIMEOUT", ""); v != "" {
+		d, err := time.ParseDuration(v)
 		if err != nil {
-			return nil, err
+			log.Printf("WARN  invalid CYCLE_TIMEOUT %q: %v (using %s)", v, err, timeout)
+		} else {
+			timeout = d
 		}
-		line := string(lineBytes)
+	}
+	if timeout < 0 {
+		timeout = 0
+	}
 
-		if strings.HasPrefix(line, "#") {
-			trim := strings.TrimSpace(strings.TrimPrefix(line, "#"))
-			// First comment line starting with YY or YYYY is the header.
-			if strings.HasPrefix(trim, "YY") || strings.HasPrefix(trim, "YYYY") {
-				header = strings.Fields(trim)
-			}
-			// Second comment line (units) is skipped automatically.
-			continue
-		}
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		cols := strings.Fields(line)
-		if len(cols) >= 5 {
-			data = append(data, cols)
+	maxNull := -1
+	if v := metrow.Getenv("MAX_NULL_FIELDS", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("WARN  invalid MAX_NULL_FIELDS %q (null filtering disabled)", v)
+		} else {
+			maxNull = n
 		}
 	}
 
-	// Fallback header if not found in file.
-	if header == nil {
-		header = []string{
-			"YYYY", "MM", "DD", "hh", "mm",
-			"WDIR", "WSPD", "GST", "WVHT", "DPD",
-			"APD", "MWD", "PRES", "PTDY", "ATMP",
-			"WTMP", "DEWP", "VIS", "TIDE",
-		}
+	pivot, err := strconv.Atoi(metrow.Getenv("YEAR_PIVOT", strconv.Itoa(ndbc.DefaultYearPivot)))
+	if err != nil || pivot < 0 || pivot > 99 {
+		log.Printf("WARN  invalid YEAR_PIVOT (using %d)", ndbc.DefaultYearPivot)
+		pivot = ndbc.DefaultYearPivot
 	}
 
-	idx := make(map[string]int, len(header))
-	for i, h := range header {
-		idx[strings.ToUpper(h)] = i
+	dedup := ndbc.DedupFirst
+	switch v := metrow.Getenv("DEDUP_KEEP", "first"); v {
+	case "first":
+	case "last":
+		dedup = ndbc.DedupLast
+	case "all":
+		dedup = ndbc.DedupOff
+	default:
+		log.Printf("WARN  invalid DEDUP_KEEP %q (keeping first)", v)
 	}
 
-	if maxRows > 0 && len(data) > maxRows {
-		data = data[:maxRows]
+	cols, err := metrow.SelectColumns(metrow.Getenv("COLUMNS", ""))
+	if err != nil {
+		log.Fatalf("ERROR invalid COLUMNS: %v", err)
+	}
+	// qc_flags is only meaningful when the checks run; all zeros would
+	// read as "passed".
+	switch {
+	case qc:
+		cols = withColumn(cols, "qc_flags")
+	case hasColumn(cols, "qc_flags"):
+		log.Printf("WARN  COLUMNS names qc_flags but QC_CHECKS is off; not writing it")
+		cols = slices.DeleteFunc(cols, func(c metrow.Column) bool { return c.Name == "qc_flags" })
+	}
+	if wc {
+		cols = withColumn(cols, "windchill_c")
+	}
+	timeISO, _ := strconv.ParseBool(metrow.Getenv("TIME_ISO", "false"))
+	if timeISO {
+		cols = withColumn(cols, "time_iso")
+	}
+	truncHour, _ := strconv.ParseBool(metrow.Getenv("TRUNCATE_TO_HOUR", "false"))
+	skipAllNull, _ := strconv.ParseBool(metrow.Getenv("SKIP_ALL_NULL", "false"))
+	if cols, err = metrow.WithPrecision(cols, metrow.Getenv("PRECISION", "")); err != nil {
+		log.Printf("WARN  invalid PRECISION: %v (using float64)", err)
 	}
 
-	out := make([]MetRow, 0, len(data))
-	for _, cols := range data {
-		// Determine year column name (YYYY or YY).
-		yy := get(cols, idx, "YYYY")
-		if yy == "" {
-			yy = get(cols, idx, "YY")
-		}
-		mm := get(cols, idx, "MM")
-		dd := get(cols, idx, "DD")
-		hh := get(cols, idx, "HH")
-		if hh == "" {
-			hh = get(cols, idx, "hh")
-		}
-		mn := get(cols, idx, "mm")
+	direction := ndbc.DirectionKeep
+	switch v := metrow.Getenv("WDIR_MODE", "keep"); v {
+	case "keep":
+	case "wrap":
+		direction = ndbc.DirectionWrap
+	case "reject":
+		direction = ndbc.DirectionReject
+	default:
+		log.Printf("WARN  invalid WDIR_MODE %q (keeping values as reported)", v)
+	}
 
-		year, _ := strconv.Atoi(yy)
-		if len(yy) == 2 {
-			year += 2000
-		}
-		month, _ := strconv.Atoi(mm)
-		day, _ := strconv.Atoi(dd)
-		hour, _ := strconv.Atoi(hh)
-		minute, _ := strconv.Atoi(mn)
-
-		t := time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC)
-
-		out = append(out, MetRow{
-			StationID: strings.ToUpper(station),
-			Time:      t.Unix(),
-			WDIRDeg:   atoiP(get(cols, idx, "WDIR")),
-			WSPDmS:    atofP(get(cols, idx, "WSPD")),
-			GUSTmS:    atofP(get(cols, idx, "GST")),
-			PREShPa:   atofP(get(cols, idx, "PRES")),
-			ATMPC:     atofP(get(cols, idx, "ATMP")),
-			WTMPC:     atofP(get(cols, idx, "WTMP")),
-			DEWPC:     atofP(get(cols, idx, "DEWP")),
-		})
-	}
-
-	return out, nil
-}
+	format := ndbc.FormatAuto
+	switch v := metrow.Getenv("FORMAT", "auto"); v {
+	case "auto":
+	case "whitespace":
+		format = ndbc.FormatWhitespace
+	case "fixed":
+		format = ndbc.FormatFixed
+	default:
+		log.Printf("WARN  invalid FORMAT %q (using auto)", v)
+	}
 
-func fetchStation(ctx context.Context, station string) ([]MetRow, error) {
-	u := fmt.Sprintf("%s/%s.txt", ndbcBase, strings.ToUpper(station))
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	tmpl, err := metrow.ParseFilenameTemplate(metrow.Getenv("FILENAME_TEMPLATE", ""))
 	if err != nil {
-		return nil, err
+		log.Fatalf("ERROR invalid FILENAME_TEMPLATE: %v", err)
 	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("fetch %s: %w", station, err)
+
+	var retention time.Duration
+	if v := metrow.Getenv("RETENTION_HOURS", ""); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h < 0 {
+			log.Printf("WARN  invalid RETENTION_HOURS %q (history disabled)", v)
+		} else {
+			retention = time.Duration(h * float64(time.Hour))
+		}
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetch %s: HTTP %d", station, resp.StatusCode)
+
+	csvSidecar, _ := strconv.ParseBool(metrow.Getenv("WRITE_CSV", "false"))
+	failFast, _ := strconv.ParseBool(metrow.Getenv("FAIL_FAST", "false"))
+
+	mode := metrow.Getenv("MODE", "stdmet")
+	if mode != "stdmet" && mode != "dart" {
+		log.Fatalf("ERROR invalid MODE %q (want stdmet or dart)", mode)
+	}
+	combined, _ := strconv.ParseBool(metrow.Getenv("COMBINED_OUTPUT", "false"))
+	if combined && mode == "dart" {
+		log.Fatalf("ERROR COMBINED_OUTPUT is not supported with MODE=dart")
 	}
-	b, err := io.ReadAll(resp.Body)
+	dartTmpl, err := metrow.ParseFilenameTemplate(metrow.Getenv("DART_FILENAME_TEMPLATE", metrow.DefaultDartFilenameTemplate))
 	if err != nil {
-		return nil, err
+		log.Fatalf("ERROR invalid DART_FILENAME_TEMPLATE: %v", err)
+	}
+
+	stations := excludeStations(
+		strings.Split(metrow.Getenv("STATIONS", "SANF1,SMKF1,LONF1,VAKF1,KYWF1"), ","),
+		metrow.Getenv("STATIONS_EXCLUDE", ""))
+
+	// 48 rows is two days of hourly data; sub-hourly (6/10-minute) feeds
+	// need more to cover the same span.
+	maxRows, err := strconv.Atoi(metrow.Getenv("MAX_ROWS", "48"))
+	if err != nil || maxRows < 0 {
+		log.Printf("WARN  invalid MAX_ROWS (using 48)")
+		maxRows = 48
+	}
+
+	conc, err := strconv.Atoi(metrow.Getenv("STATION_CONCURRENCY", "4"))
+	if err != nil || conc < 1 {
+		log.Printf("WARN  invalid STATION_CONCURRENCY (using 4)")
+		conc = 4
+	}
+
+	rps, err := strconv.ParseFloat(metrow.Getenv("REQUESTS_PER_SECOND", "0"), 64)
+	if err != nil || rps < 0 {
+		log.Printf("WARN  invalid REQUESTS_PER_SECOND (rate limiting disabled)")
+		rps = 0
+	}
+
+	jitter, err := parseFraction(metrow.Getenv("REFRESH_JITTER", "0"))
+	if err != nil || jitter < 0 || jitter >= 1 {
+		log.Printf("WARN  invalid REFRESH_JITTER (want a fraction below 1 such as 0.1 or 10%%; jitter disabled)")
+		jitter = 0
+	}
+
+	return config{
+		Stations:          stations,
+		DataDir:           metrow.Getenv("DATA_DIR", "/data"),
+		RefreshMinutes:    mins,
+		CycleTimeout:      timeout,
+		QCChecks:          qc,
+		MaxNullFields:     maxNull,
+		YearPivot:         pivot,
+		Dedup:             dedup,
+		Direction:         direction,
+		TimeISO:           hasColumn(cols, "time_iso"),
+		TruncateHour:      truncHour,
+		SkipAllNull:       skipAllNull,
+		Format:            format,
+		Combined:          combined,
+		Columns:           cols,
+		RequestsPerSecond: rps,
+		Filename:          tmpl,
+		MaxRows:           maxRows,
+		Concurrency:       conc,
+		Longitudes:        stationLongitudes(),
+		StationRefresh:    stationRefresh(),
+		Jitter:            jitter,
+		Clock:             clock.Real{},
+		Retention:         retention,
+		WriteCSV:          csvSidecar,
+		FailFast:          failFast,
+		WebhookURL:        metrow.Getenv("WEBHOOK_URL", ""),
+		Mode:              mode,
+		DartFilename:      dartTmpl.ForDataset(metrow.DartDataset),
 	}
-	return parseNdbcStdMet(station, b, 48)
 }
 
-// writeParquet atomically writes rows to path via a .tmp intermediate file.
-func writeParquet(path string, rows []MetRow) error {
-	tmp := path + ".tmp"
-	f, err := os.Create(tmp)
+// fetchStation fetches and parses station's realtime met file. It also
+// returns the file metadata to store with the rows (see fileMeta).
+func fetchStation(ctx context.Context, clk clock.Clock, station string, opts ...ndbc.ParseOption) ([]metrow.MetRow, map[string]string, error) {
+	b, src, err := fetchBody(ctx, clk, station, "txt")
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	var units map[string]string
+	var warnings []string
+	var nulled map[string]int
+	opts = append([]ndbc.ParseOption{ndbc.WithStation(station), ndbc.WithUnits(&units), ndbc.WithWarnings(&warnings),
+		ndbc.WithSentinelCounts(&nulled)}, opts...)
+	rows, err := ndbc.ParseStdMet(b, opts...)
+	if err != nil {
+		return nil, nil, err
 	}
+	for _, w := range warnings {
+		log.Printf("WARN  %s: %s", station, w)
+	}
+	sentinels.add(station, nulled)
+	return rows, fileMeta(units, src), nil
+}
 
-	w := parquet.NewGenericWriter[MetRow](f)
-	if _, err := w.Write(rows); err != nil {
-		f.Close()
-		os.Remove(tmp)
-		return err
+// parquetEncodings are the column encodings station files are written in
+// (PARQUET_ENCODING); nil keeps parquet-go's defaults.
+var parquetEncodings metrow.ParquetEncodings
+
+// bloomFilterColumns are the columns station files carry bloom filters for
+// (BLOOM_FILTER_COLUMNS).
+var bloomFilterColumns []string
+
+// writeParquet encodes rows as Parquet, keeping only the selected cols
+// (plus station_id and time) and attaching meta as file-level key/value
+// metadata, then stores the file in sink under name, followed by its
+// checksum sidecar when enabled. When the content hash matches the file
+// already stored under name, nothing is written and it reports false.
+func writeParquet(sink Sink, name string, cols []metrow.Column, rows []metrow.MetRow, meta map[string]string) (bool, error) {
+	hash, err := contentHash(cols, rows, meta)
+	if err != nil {
+		return false, err
 	}
-	if err := w.Close(); err != nil {
-		f.Close()
-		os.Remove(tmp)
-		return err
+	if storedHash(sink, name) == hash {
+		return false, nil
 	}
-	if err := f.Close(); err != nil {
-		os.Remove(tmp)
-		return err
+	withHash := map[string]string{metrow.ContentHashKey: hash, metrow.ToolVersionKey: metrow.Version}
+	for k, v := range meta {
+		withHash[k] = v
+	}
+	var buf bytes.Buffer
+	if err := ndbc.WriteParquet(&buf, cols, rows, withHash, ndbc.WithEncodings(parquetEncodings),
+		ndbc.WithBloomFilters(bloomFilterColumns)); err != nil {
+		return false, err
 	}
-	return os.Rename(tmp, path)
+	if err := sink.Write(name, buf.Bytes()); err != nil {
+		return false, err
+	}
+	return true, writeChecksum(sink, name, buf.Bytes())
 }
 
-func runOnce(ctx context.Context, stations []string, dataDir string) {
-	if err := os.MkdirAll(dataDir, 0o755); err != nil {
-		log.Printf("ERROR mkdir %s: %v", dataDir, err)
-		return
+// runOnce fetches and writes every configured station once. Stations run
+// independently (up to cfg.Concurrency at a time), so each file is written
+// as soon as its station finishes and a slow or retrying station does not
+// hold back the others. The whole cycle is bounded by cfg.CycleTimeout;
+// stations not started before the deadline are logged as skipped. Once all
+// stations are done, a manifest of the files written and the stations'
+// fetch status (metrow.FetchStatusName) are stored alongside, and
+// cfg.WebhookURL, if set, is notified unless no file was written.
+//
+// With cfg.FailFast the first station failure cancels the cycle: stations
+// not yet started are skipped, no manifest is written, and the error is
+// returned. Otherwise the error is always nil. The per-station results are
+// returned either way, in station order.
+func runOnce(ctx context.Context, cfg config, sink Sink) ([]stationResult, error) {
+	cycle := cfg.Clock.Now().UTC()
+	cfg.BatchID = uuid.NewString()
+	if cfg.CycleTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.CycleTimeout)
+		defer cancel()
 	}
-	for _, s := range stations {
+	ctx, abort := context.WithCancel(ctx)
+	defer abort()
+	sentinels.reset()
+	loadFetchStatus(sink)
+	sem := make(chan struct{}, max(cfg.Concurrency, 1))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		manifest = metrow.Manifest{Cycle: cycle, BatchID: cfg.BatchID, Files: []metrow.ManifestEntry{}}
+		written  []metrow.ManifestEntry // files whose content changed, for the webhook
+		results  []stationResult
+		fresh    = make(map[string][]metrow.MetRow) // COMBINED_OUTPUT rows by station
+		failed   error
+	)
+	for _, s := range cfg.Stations {
 		s = strings.TrimSpace(s)
 		if s == "" {
 			continue
 		}
-		rows, err := fetchStation(ctx, s)
-		if err != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			if err := ctx.Err(); err != nil {
+				log.Printf("SKIP  %s: cycle aborted: %v", s, err)
+				mu.Lock()
+				results = append(results, stationResult{Station: strings.ToUpper(s), Error: "skipped: " + err.Error()})
+				mu.Unlock()
+				return
+			}
+			var (
+				e     metrow.ManifestEntry
+				rows  []metrow.MetRow
+				wrote bool
+				err   error
+			)
+			switch {
+			case cfg.Combined:
+				if rows, _, err = prepareStation(ctx, cfg, s); err == nil {
+					e = stationEntry(s, cfg.Filename.ExpandCombined(cycle), rows)
+				}
+			case cfg.Mode == "dart":
+				e, wrote, err = processDart(ctx, cfg, sink, s)
+			default:
+				e, wrote, err = processStation(ctx, cfg, sink, s)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			res := newStationResult(s, e, err)
+			res.Sentinels = sentinels.station(s)
+			results = append(results, res)
+			recordFetch(res.Station, cycle, err)
+			switch {
+			case err == nil && cfg.Combined:
+				fresh[e.Station] = rows
+			case err == nil:
+				manifest.Files = append(manifest.Files, e)
+				if wrote {
+					written = append(written, e)
+				}
+			case cfg.FailFast && !errors.Is(err, errNoRows):
+				if failed == nil {
+					failed = fmt.Errorf("station %s: %w", s, err)
+					abort()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Station < results[j].Station })
+	if totals := sentinels.totals(); len(totals) > 0 {
+		log.Printf("INFO  cycle nulled sentinel values: %s", formatCounts(totals))
+	}
+	if err := writeFetchStatus(sink); err != nil {
+		log.Printf("ERROR write %s: %v", metrow.FetchStatusName, err)
+	}
+	if failed != nil {
+		log.Printf("ERROR cycle aborted (FAIL_FAST): %v", failed)
+		return results, failed
+	}
+	if cfg.Combined {
+		e, wrote, err := writeCombined(cfg, sink, cycle, fresh)
+		switch {
+		case err == nil:
+			manifest.Files = append(manifest.Files, e)
+			if wrote {
+				written = append(written, e)
+			}
+		case cfg.FailFast && !errors.Is(err, errNoRows):
+			log.Printf("ERROR cycle aborted (FAIL_FAST): %v", err)
+			return results, err
+		}
+	}
+
+	manifest.Files = publish(manifest.Files)
+	if err := writeManifest(sink, manifest); err != nil {
+		log.Printf("ERROR write %s: %v", metrow.ManifestName, err)
+	}
+	switch {
+	case cfg.WebhookURL == "":
+	case len(written) == 0:
+		// Nothing changed, so there is nothing for downstream to pick up.
+		log.Printf("SKIP  webhook: no station files written this cycle")
+	default:
+		// CYCLE_TIMEOUT bounds the stations, not the notification.
+		notifyWebhook(context.WithoutCancel(ctx), cfg.Clock, cfg.WebhookURL, newWebhookPayload(cycle, cfg.BatchID, written, results))
+	}
+	return results, nil
+}
+
+// published holds the latest manifest entry of every station written by
+// this process, so a cycle that refreshes only the stations due
+// (STATION_REFRESH) still publishes a manifest listing all of them.
+var published = make(map[string]metrow.ManifestEntry)
+
+// publish records entries and returns every published entry by station.
+// It is only called from runOnce, which never runs concurrently.
+func publish(entries []metrow.ManifestEntry) []metrow.ManifestEntry {
+	for _, e := range entries {
+		published[e.Station] = e
+	}
+	out := make([]metrow.ManifestEntry, 0, len(published))
+	for _, e := range published {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Station < out[j].Station })
+	return out
+}
+
+// writeManifest stores m as metrow.ManifestName through sink.
+func writeManifest(sink Sink, m metrow.Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return sink.Write(metrow.ManifestName, append(b, '\n'))
+}
+
+// errNoRows reports a station that parsed cleanly but left nothing to
+// write; it is not a failure for FAIL_FAST.
+var errNoRows = errors.New("no rows")
+
+// errAllNull reports a station skipped under SKIP_ALL_NULL because its
+// fetch held only missing measurements. Like errNoRows it is not a failure
+// for FAIL_FAST, but the station's fetch status records it as one.
+var errAllNull = fmt.Errorf("all measurements missing: %w", errNoRows)
+
+// processStation fetches, parses, and writes one station, returning its
+// manifest entry and whether the file was written (see writeStationFile).
+// Failures are logged here; the error is returned so FAIL_FAST can stop
+// the cycle.
+func processStation(ctx context.Context, cfg config, sink Sink, s string) (metrow.ManifestEntry, bool, error) {
+	rows, meta, err := prepareStation(ctx, cfg, s)
+	if err != nil {
+		return metrow.ManifestEntry{}, false, err
+	}
+	return writeStationFile(cfg, sink, s, cfg.Filename.Expand(s, cfg.Clock.Now()), rows, meta)
+}
+
+// prepareStation fetches and parses one station and applies the row
+// filters and derived columns, logging failures.
+func prepareStation(ctx context.Context, cfg config, s string) ([]metrow.MetRow, map[string]string, error) {
+	rows, meta, err := fetchStation(ctx, cfg.Clock, s,
+		ndbc.WithMaxRows(cfg.MaxRows), ndbc.WithYearPivot(cfg.YearPivot), ndbc.WithQCChecks(cfg.QCChecks), ndbc.WithDedup(cfg.Dedup),
+		ndbc.WithDirection(cfg.Direction), ndbc.WithTimeISO(cfg.TimeISO), ndbc.WithFormat(cfg.Format),
+		ndbc.WithTruncateToHour(cfg.TruncateHour))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrStationNotFound):
+			log.Printf("WARN  %s: no realtime data at NDBC (check STATIONS): %v", s, err)
+		case errors.Is(err, ErrBadContent):
+			log.Printf("ERROR %s: %v", s, err)
+		default:
 			log.Printf("WARN  %s: %v", s, err)
-			continue
 		}
-		if len(rows) == 0 {
-			log.Printf("INFO  %s: no rows parsed", s)
-			continue
+		return nil, nil, err
+	}
+	if allNull(rows) {
+		if cfg.SkipAllNull {
+			log.Printf("SKIP  %s: all %d rows have every measurement missing (station offline?); keeping the stored file", s, len(rows))
+			return nil, nil, errAllNull
 		}
-		out := filepath.Join(dataDir, strings.ToUpper(s)+"_latest.parquet")
-		if err := writeParquet(out, rows); err != nil {
-			log.Printf("ERROR %s: write parquet: %v", s, err)
-			continue
+		log.Printf("WARN  %s: all %d rows have every measurement missing (station offline?)", s, len(rows))
+	}
+	if cfg.MaxNullFields >= 0 {
+		before := len(rows)
+		rows = dropSparse(rows, cfg.MaxNullFields)
+		if dropped := before - len(rows); dropped > 0 {
+			log.Printf("INFO  %s: dropped %d sparse rows (MAX_NULL_FIELDS=%d)", s, dropped, cfg.MaxNullFields)
 		}
-		log.Printf("WROTE %s (%d rows)", out, len(rows))
 	}
+	if len(rows) == 0 {
+		log.Printf("INFO  %s: no rows parsed", s)
+		return nil, nil, errNoRows
+	}
+	if cfg.QCChecks {
+		flagged := 0
+		for _, r := range rows {
+			if r.QCFlags != 0 {
+				flagged++
+			}
+		}
+		if flagged > 0 {
+			log.Printf("INFO  %s: %d rows flagged by QC range checks", s, flagged)
+		}
+	}
+	if lon, ok := cfg.Longitudes[strings.ToUpper(s)]; ok {
+		for i := range rows {
+			st := ndbc.SolarTime(rows[i].Time, lon)
+			rows[i].SolarTime = &st
+		}
+	}
+	metrow.RoundRows(cfg.Columns, rows)
+	return rows, meta, nil
+}
+
+// writeStationFile writes rows to out, first merging the stored history
+// when RETENTION_HOURS is set. s labels the log lines and manifest entry.
+// It reports false when out already held the same content and was left
+// alone.
+func writeStationFile(cfg config, sink Sink, s, out string, rows []metrow.MetRow, fetched map[string]string) (metrow.ManifestEntry, bool, error) {
+	// Hold the file from reading history through the final write, so a
+	// concurrent writer cannot merge against a stale copy.
+	unlock, err := lockFile(sink, out)
+	if err != nil {
+		log.Printf("ERROR %s: lock %s: %v", s, out, err)
+		return metrow.ManifestEntry{}, false, fmt.Errorf("%s: lock: %w", s, err)
+	}
+	defer unlock()
+	if cfg.Retention > 0 {
+		before := len(rows)
+		rows = mergeHistory(sink, out, rows, cfg.Retention)
+		log.Printf("INFO  %s: %d rows after merging history (%d fetched, RETENTION_HOURS=%g)",
+			s, len(rows), before, cfg.Retention.Hours())
+	}
+	// NDBC lists newest first; store oldest first so readers can merge
+	// station files without sorting them. Rows of a combined file sharing
+	// a timestamp are ordered by station.
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Time != rows[j].Time {
+			return rows[i].Time < rows[j].Time
+		}
+		return rows[i].StationID < rows[j].StationID
+	})
+	meta := map[string]string{metrow.SortedKey: "time", metrow.BatchIDKey: cfg.BatchID}
+	for k, v := range fetched {
+		meta[k] = v
+	}
+	wrote, err := writeParquet(sink, out, cfg.Columns, rows, meta)
+	if err != nil {
+		log.Printf("ERROR %s: write parquet: %v", s, err)
+		return metrow.ManifestEntry{}, false, fmt.Errorf("%s: write parquet: %w", s, err)
+	}
+	if wrote {
+		log.Printf("WROTE %s/%s (%d rows)", sink, out, len(rows))
+		if cfg.WriteCSV {
+			writeCSV(sink, csvName(out), cfg.Columns, rows)
+		}
+	} else {
+		log.Printf("UNCHANGED %s/%s (%d rows)", sink, out, len(rows))
+	}
+	return stationEntry(s, out, rows), wrote, nil
+}
+
+// stationEntry is the manifest entry for rows of station s stored in path.
+func stationEntry(s, path string, rows []metrow.MetRow) metrow.ManifestEntry {
+	e := metrow.ManifestEntry{Station: strings.ToUpper(s), Path: path, Rows: len(rows)}
+	if len(rows) > 0 {
+		newest := rows[0].Time
+		for _, r := range rows[1:] {
+			newest = max(newest, r.Time)
+		}
+		e.Newest = time.Unix(newest, 0).UTC()
+	}
+	return e
+}
+
+// settingKeys are the settings go-ingest reads; CONFIG_FILE keys and
+// command-line flags must be one of these.
+var settingKeys = []string{
+	"STATIONS", "STATIONS_EXCLUDE", "DATA_DIR", "REFRESH_MINUTES", "CYCLE_TIMEOUT",
+	"QC_CHECKS", "WIND_CHILL", "MAX_ROWS", "MAX_NULL_FIELDS", "YEAR_PIVOT", "COLUMNS",
+	"FILENAME_TEMPLATE", "REQUESTS_PER_SECOND", "STATION_CONCURRENCY", "DEDUP_KEEP",
+	"WDIR_MODE", "TIME_ISO", "STATION_LONGITUDES", "FILE_MODE", "DIR_MODE",
+	"STORAGE", "S3_BUCKET", "S3_PREFIX", "S3_ENDPOINT", "S3_REGION",
+	"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "MODE", "DART_FILENAME_TEMPLATE",
+	"FAIL_FAST", "STATION_REFRESH", "RETENTION_HOURS", "WRITE_CSV",
+	"WRITE_CHECKSUM", "MAX_BODY_BYTES", "INGEST_PORT", "FORMAT", "LIST_STATIONS",
+	"BBOX", "COMBINED_OUTPUT", "LOG_LEVEL", "PRECISION", "REFRESH_JITTER",
+	"TRUNCATE_TO_HOUR", "PARQUET_ENCODING", "DURABLE_WRITE", "MAX_IDLE_CONNS",
+	"MAX_CONNS_PER_HOST", "SKIP_ALL_NULL", "DATA_WINDOW", "BLOOM_FILTER_COLUMNS",
+	"WEBHOOK_URL",
 }
 
 func main() {
-	stationsCSV := getenv("STATIONS", "SANF1,SMKF1,LONF1,VAKF1,KYWF1")
-	stations := strings.Split(stationsCSV, ",")
-	dataDir := getenv("DATA_DIR", "/data")
-	minsStr := getenv("REFRESH_MINUTES", "60")
-	mins, _ := strconv.Atoi(minsStr)
+	if err := metrow.LoadSettings("go-ingest", settingKeys, os.Args[1:]); err != nil {
+		log.Fatalf("ERROR config: %v", err)
+	}
+	if err := metrow.SetupLogging(); err != nil {
+		log.Printf("WARN  invalid LOG_LEVEL: %v (using info)", err)
+	}
+	cfg := loadConfig()
+	if cfg.RequestsPerSecond > 0 {
+		fetchLimiter = newLimiter(cfg.Clock, cfg.RequestsPerSecond, 1)
+	}
+	checksumSidecars = parseChecksumMode(metrow.Getenv("WRITE_CHECKSUM", ""))
+	enc, err := metrow.ParseParquetEncodings(metrow.Getenv("PARQUET_ENCODING", ""), cfg.Columns)
+	if err != nil {
+		log.Printf("WARN  invalid PARQUET_ENCODING: %v (using default encodings)", err)
+	}
+	parquetEncodings = enc
+	bloom, err := metrow.ParseBloomFilterColumns(metrow.Getenv("BLOOM_FILTER_COLUMNS", ""), cfg.Columns)
+	if err != nil {
+		log.Printf("WARN  invalid BLOOM_FILTER_COLUMNS: %v (no bloom filters)", err)
+	}
+	bloomFilterColumns = bloom
+	maxIdle, err := strconv.Atoi(metrow.Getenv("MAX_IDLE_CONNS", strconv.Itoa(defaultMaxIdleConns)))
+	if err != nil || maxIdle < 0 {
+		log.Printf("WARN  invalid MAX_IDLE_CONNS (using %d)", defaultMaxIdleConns)
+		maxIdle = defaultMaxIdleConns
+	}
+	perHost, err := strconv.Atoi(metrow.Getenv("MAX_CONNS_PER_HOST", strconv.Itoa(defaultMaxConnsPerHost)))
+	if err != nil || perHost < 0 {
+		log.Printf("WARN  invalid MAX_CONNS_PER_HOST (using %d)", defaultMaxConnsPerHost)
+		perHost = defaultMaxConnsPerHost
+	}
+	ndbcClient = newNDBCClient(maxIdle, perHost)
+	if v := metrow.Getenv("DATA_WINDOW", ""); v != "" {
+		if v != window45Day && v != window5Day {
+			log.Printf("WARN  invalid DATA_WINDOW %q (want %s or %s; using %s)", v, window45Day, window5Day, dataWindow)
+		} else {
+			dataWindow = v
+		}
+	}
+	if v := metrow.Getenv("MAX_BODY_BYTES", ""); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			log.Printf("WARN  invalid MAX_BODY_BYTES %q (using %d)", v, maxBodyBytes)
+		} else {
+			maxBodyBytes = n
+		}
+	}
 
-	log.Printf("Starting go-ingest | stations=%s refresh=%dmin dataDir=%s",
-		stationsCSV, mins, dataDir)
+	if list, _ := strconv.ParseBool(metrow.Getenv("LIST_STATIONS", "false")); list {
+		if err := listStations(context.Background(), os.Stdout, cfg.Mode); err != nil {
+			log.Fatalf("ERROR list stations: %v", err)
+		}
+		return
+	}
+	if v := metrow.Getenv("BBOX", ""); v != "" {
+		if err := addBBoxStations(context.Background(), &cfg, v); err != nil {
+			log.Fatalf("ERROR BBOX: %v", err)
+		}
+	}
+
+	sink, err := newSink(cfg.DataDir)
+	if err != nil {
+		log.Fatalf("ERROR storage: %v", err)
+	}
+
+	log.Printf("Starting go-ingest | mode=%s stations=%s refresh=%dmin cycleTimeout=%s output=%s qc=%t",
+		cfg.Mode, strings.Join(cfg.Stations, ","), cfg.RefreshMinutes, cfg.CycleTimeout, sink, cfg.QCChecks)
 
 	ctx := context.Background()
-	for {
-		runOnce(ctx, stations, dataDir)
-		if mins <= 0 {
-			log.Println("One-shot mode complete, exiting.")
-			break
+	if cfg.RefreshMinutes <= 0 {
+		if _, err := runOnce(ctx, cfg, sink); err != nil {
+			log.Fatalf("ERROR one-shot run failed: %v", err)
 		}
-		log.Printf("Sleeping %d minutes until next fetch.", mins)
-		time.Sleep(time.Duration(mins) * time.Minute)
+		log.Println("One-shot mode complete, exiting.")
+		return
+	}
+
+	if port := metrow.Getenv("INGEST_PORT", ""); port != "" {
+		go serveControl(port, cfg, sink)
+	}
+
+	sched := newSchedule(cfg.Stations, time.Duration(cfg.RefreshMinutes)*time.Minute, cfg.StationRefresh)
+	if cfg.Jitter > 0 {
+		sched.setJitter(cfg.Jitter, cfg.Clock.Now().UnixNano())
+	}
+	for {
+		now := cfg.Clock.Now()
+		due := sched.due(now)
+		tick := cfg
+		tick.Stations = due
+		cycleMu.Lock()
+		runOnce(ctx, tick, sink)
+		cycleMu.Unlock()
+		sched.done(due, now)
+
+		next := sched.nextDue()
+		wait := next.Sub(cfg.Clock.Now())
+		log.Printf("Sleeping %s until next fetch.", wait.Round(time.Second))
+		cfg.Clock.Sleep(wait)
 	}
 }
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// stdmetHeader is the two header lines of a realtime2 .txt file.
+const stdmetHeader = `#YY  MM DD hh mm WDIR WSPD GST  WVHT   DPD   APD MWD   PRES  ATMP  WTMP  DEWP  VIS PTDY  TIDE
+#yr  mo dy hr mn degT m/s  m/s     m   sec   sec degT   hPa  degC  degC  degC  nmi  hPa    ft
+`
+
+// stubNDBC points ndbcRoot at a server answering every request with h.
+func stubNDBC(t *testing.T, h http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	old := ndbcRoot
+	ndbcRoot = srv.URL
+	t.Cleanup(func() { ndbcRoot = old })
+}
+
+func TestCycleTimeoutSkipsRemainingStations(t *testing.T) {
+	var calls atomic.Int32
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-r.Context().Done()
+	})
+	cfg := config{
+		Clock:        clock.Real{},
+		Stations:     []string{"TMOA1", "TMOB1", "TMOC1"},
+		DataDir:      t.TempDir(),
+		CycleTimeout: 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("cycle took %s with a %s deadline", d, cfg.CycleTimeout)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1 before the deadline skipped the rest", n)
+	}
+	if files, _ := filepath.Glob(filepath.Join(cfg.DataDir, "*.parquet")); len(files) != 0 {
+		t.Errorf("files written: %v", files)
+	}
+}
+
+func TestWriteParquetQCFlagsColumnOnlyWithQC(t *testing.T) {
+	pres := 750.0
+	for _, qc := range []bool{false, true} {
+		t.Setenv("QC_CHECKS", strconv.FormatBool(qc))
+		t.Setenv("COLUMNS", "pres_hpa,wspd_ms,qc_flags")
+		cfg := loadConfig()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "SANF1_latest.parquet")
+		in := []metrow.MetRow{{StationID: "SANF1", Time: 1718002800, PREShPa: &pres, QCFlags: ndbc.QCPRES}}
+		if _, err := writeParquet(testSink(dir), "SANF1_latest.parquet", cfg.Columns, in, nil); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, _ := f.Stat()
+		pf, err := parquet.OpenFile(f, st.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok := pf.Schema().Lookup("qc_flags")
+		if ok != qc {
+			t.Errorf("QC_CHECKS=%t: qc_flags column present = %t", qc, ok)
+		}
+		out, err := metrow.ReadParquet(f)
+		f.Close()
+		if err != nil || len(out) != 1 || out[0].StationID != "SANF1" || out[0].Time != in[0].Time ||
+			out[0].PREShPa == nil || *out[0].PREShPa != pres || out[0].WSPDmS != nil {
+			t.Errorf("QC_CHECKS=%t: read back %+v, %v", qc, out, err)
+		}
+	}
+}
+
+func TestWindChillColumnOptIn(t *testing.T) {
+	for _, tt := range []struct {
+		windChill, columns string
+		want               bool
+	}{
+		{"", "", false},
+		{"true", "", true},
+		{"", "wspd_ms,windchill_c", true},
+	} {
+		t.Setenv("WIND_CHILL", tt.windChill)
+		t.Setenv("COLUMNS", tt.columns)
+		if got := hasColumn(loadConfig().Columns, "windchill_c"); got != tt.want {
+			t.Errorf("WIND_CHILL=%q COLUMNS=%q: windchill_c selected = %t", tt.windChill, tt.columns, got)
+		}
+	}
+}
+
+func TestDropSparse(t *testing.T) {
+	v := 1.0
+	full := metrow.MetRow{StationID: "A", WSPDmS: &v, GUSTmS: &v, PREShPa: &v, ATMPC: &v, WTMPC: &v, DEWPC: &v}
+	wdir := int32(90)
+	full.WDIRDeg = &wdir
+	twoMissing := full
+	twoMissing.StationID, twoMissing.GUSTmS, twoMissing.DEWPC = "B", nil, nil
+	empty := metrow.MetRow{StationID: "C"}
+
+	for _, tt := range []struct {
+		maxNull int
+		want    string
+	}{{0, "A"}, {1, "A"}, {2, "AB"}, {7, "ABC"}} {
+		got := ""
+		for _, r := range dropSparse([]metrow.MetRow{full, twoMissing, empty}, tt.maxNull) {
+			got += r.StationID
+		}
+		if got != tt.want {
+			t.Errorf("MAX_NULL_FIELDS=%d kept %q, want %q", tt.maxNull, got, tt.want)
+		}
+	}
+}
+
+func TestMaxNullFieldsConfig(t *testing.T) {
+	for v, want := range map[string]int{"": -1, "3": 3, "0": 0, "-2": -1, "x": -1} {
+		t.Setenv("MAX_NULL_FIELDS", v)
+		if got := loadConfig().MaxNullFields; got != want {
+			t.Errorf("MAX_NULL_FIELDS=%q: %d, want %d", v, got, want)
+		}
+	}
+}
+
+func TestUnitsLineStoredAsMetadata(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"
+	units := ndbc.Units([]byte(body))
+	if units["WSPD"] != "m/s" || units["PRES"] != "hPa" || units["MM"] != "mo" {
+		t.Errorf("units = %v", units)
+	}
+
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) })
+	cfg := config{Stations: []string{"SANF1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+	f, err := os.Open(filepath.Join(cfg.DataDir, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	st, _ := f.Stat()
+	pf, err := parquet.OpenFile(f, st.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := pf.Lookup(metrow.UnitsKey)
+	if !ok || !strings.Contains(v, `"WSPD":"m/s"`) {
+		t.Errorf("%s metadata = %q, %t", metrow.UnitsKey, v, ok)
+	}
+}
+
+func TestFastStationWrittenBeforeSlowOneFinishes(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"
+	release := make(chan struct{})
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "SLOWA1") {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write([]byte(body))
+	})
+	cfg := config{Stations: []string{"SLOWA1", "FASTA1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1, Concurrency: 2}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	done := make(chan struct{})
+	go func() {
+		runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+		close(done)
+	}()
+
+	fast := filepath.Join(cfg.DataDir, "FASTA1_latest.parquet")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(fast); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("%s not written while the slow station was still fetching", fast)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("cycle finished before the slow station was released")
+	default:
+	}
+	close(release)
+	<-done
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, "SLOWA1_latest.parquet")); err != nil {
+		t.Errorf("slow station: %v", err)
+	}
+}
+
+func TestWDIRModeConfig(t *testing.T) {
+	for v, want := range map[string]ndbc.DirectionMode{
+		"":       ndbc.DirectionKeep,
+		"keep":   ndbc.DirectionKeep,
+		"wrap":   ndbc.DirectionWrap,
+		"reject": ndbc.DirectionReject,
+		"bogus":  ndbc.DirectionKeep,
+	} {
+		t.Setenv("WDIR_MODE", v)
+		if got := loadConfig().Direction; got != want {
+			t.Errorf("WDIR_MODE=%q: %d, want %d", v, got, want)
+		}
+	}
+}
+
+func TestTimeISOColumnOptIn(t *testing.T) {
+	for _, tt := range []struct {
+		timeISO, columns string
+		want             bool
+	}{
+		{"", "", false},
+		{"true", "", true},
+		{"", "wspd_ms,time_iso", true},
+	} {
+		t.Setenv("TIME_ISO", tt.timeISO)
+		t.Setenv("COLUMNS", tt.columns)
+		cfg := loadConfig()
+		if got := hasColumn(cfg.Columns, "time_iso"); got != tt.want || cfg.TimeISO != tt.want {
+			t.Errorf("TIME_ISO=%q COLUMNS=%q: time_iso selected = %t, TimeISO = %t", tt.timeISO, tt.columns, got, cfg.TimeISO)
+		}
+	}
+}
+
+func TestFailFastStopsAtFirstFailure(t *testing.T) {
+	var calls atomic.Int32
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.NotFound(w, r)
+	})
+	cfg := config{
+		Clock:       clock.Real{},
+		Stations:    []string{"FFA1", "FFB1", "FFC1"},
+		DataDir:     t.TempDir(),
+		Concurrency: 1,
+		FailFast:    true,
+	}
+	sink := testSink(cfg.DataDir)
+
+	results, err := runOnce(context.Background(), cfg, sink)
+	if !errors.Is(err, ErrStationNotFound) {
+		t.Errorf("err = %v, want ErrStationNotFound", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d stations fetched, want 1", n)
+	}
+	skipped := 0
+	for _, r := range results {
+		if strings.HasPrefix(r.Error, "skipped: ") {
+			skipped++
+		}
+	}
+	if len(results) != 3 || skipped != 2 {
+		t.Errorf("results = %+v, want 3 with 2 skipped", results)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, metrow.ManifestName)); err == nil {
+		t.Error("manifest written for an aborted cycle")
+	}
+
+	// Without FAIL_FAST every station is attempted.
+	calls.Store(0)
+	cfg.FailFast = false
+	if _, err := runOnce(context.Background(), cfg, sink); err != nil || calls.Load() != 3 {
+		t.Errorf("err %v after %d fetches, want nil after 3", err, calls.Load())
+	}
+}
+
+func TestStationsExclude(t *testing.T) {
+	t.Setenv("STATIONS", "SANF1,smkf1, LONF1,VAKF1")
+	t.Setenv("STATIONS_EXCLUDE", "SMKF1, lonf1,NOPE1")
+	if got, want := loadConfig().Stations, []string{"SANF1", "VAKF1"}; !slices.Equal(got, want) {
+		t.Errorf("Stations = %q, want %q", got, want)
+	}
+	if got := excludeStations([]string{"SANF1"}, " , "); !slices.Equal(got, []string{"SANF1"}) {
+		t.Errorf("empty denylist: %q", got)
+	}
+}
+
+func TestCycleUsesConfiguredClock(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"))
+	})
+	now := time.Date(2030, 1, 2, 23, 30, 0, 0, time.UTC)
+	cfg := config{Stations: []string{"SANF1"}, DataDir: t.TempDir(), Clock: clock.NewFake(now), MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("{station}_{date}.parquet")
+	resetPublished(t)
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, "SANF1_20300102.parquet")); err != nil {
+		t.Errorf("file not named from the clock's date: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(cfg.DataDir, metrow.ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m metrow.Manifest
+	if err := json.Unmarshal(b, &m); err != nil || !m.Cycle.Equal(now) {
+		t.Errorf("manifest cycle %s (err %v), want %s", m.Cycle, err, now)
+	}
+}
+
+// resetPublished clears the manifest entries earlier tests published.
+func resetPublished(t *testing.T) {
+	t.Helper()
+	old := published
+	published = make(map[string]metrow.ManifestEntry)
+	t.Cleanup(func() { published = old })
+}
+
+func TestManifestListsWrittenFiles(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 08 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 06 10 07 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8  26.0  20.0   MM   MM    MM\n"
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "BAD01") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	})
+	cfg := config{Stations: []string{"smkf1", "BAD01", "SANF1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1, Concurrency: 2}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+
+	b, err := os.ReadFile(filepath.Join(cfg.DataDir, metrow.ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m metrow.Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Cycle.IsZero() || len(m.Files) != 2 {
+		t.Fatalf("manifest = %s", b)
+	}
+	for i, station := range []string{"SANF1", "SMKF1"} {
+		e := m.Files[i]
+		f, err := os.Open(filepath.Join(cfg.DataDir, e.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := ndbc.ReadParquet(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Station != station || e.Rows != 2 || len(rows) != 2 || !e.Newest.Equal(time.Unix(rows[1].Time, 0)) {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+	}
+}
+
+func TestMaxRowsConfig(t *testing.T) {
+	for v, want := range map[string]int{"": 48, "144": 144, "0": 0, "-1": 48, "lots": 48} {
+		t.Setenv("MAX_ROWS", v)
+		if got := loadConfig().MaxRows; got != want {
+			t.Errorf("MAX_ROWS=%q: %d, want %d", v, got, want)
+		}
+	}
+}
+
+// TestSettingKeysCoverGetenv keeps settingKeys in step with the settings
+// the service actually reads, so CONFIG_FILE and flags accept all of them.
+func TestSettingKeysCoverGetenv(t *testing.T) {
+	files, _ := filepath.Glob("*.go")
+	files = append(files, "../internal/objstore/s3.go")
+	getenv := regexp.MustCompile(`Getenv\("([A-Z0-9_]+)"`)
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range getenv.FindAllSubmatch(b, -1) {
+			if key := string(m[1]); !slices.Contains(settingKeys, key) {
+				t.Errorf("%s reads %s, which is missing from settingKeys", f, key)
+			}
+		}
+	}
+}
+
+func TestProvenanceMetadata(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"))
+	})
+	fetched := time.Date(2024, 6, 10, 7, 30, 0, 0, time.UTC)
+	clk := clock.NewFake(fetched)
+	cfg := config{Stations: []string{"sanf1"}, DataDir: t.TempDir(), Clock: clk, MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	sink := testSink(cfg.DataDir)
+	runOnce(context.Background(), cfg, sink)
+	// Fetching the same data again only changes the provenance, which
+	// does not count as a change: the file keeps the first fetch time.
+	clk.Advance(time.Hour)
+	runOnce(context.Background(), cfg, sink)
+
+	b, err := sink.Read("SANF1_latest.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		metrow.SourceURLKey:   ndbcRoot + "/realtime2/SANF1.txt",
+		metrow.FetchedAtKey:   "2024-06-10T07:30:00Z",
+		metrow.ToolVersionKey: metrow.Version,
+	} {
+		if got, _ := pf.Lookup(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSkipAllNull(t *testing.T) {
+	good := stdmetHeader +
+		"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"
+	placeholder := stdmetHeader +
+		"2024 06 10 09 00  MM   MM   MM    MM    MM    MM  MM     MM    MM    MM    MM   MM   MM    MM\n" +
+		"2024 06 10 08 00  MM   MM   MM    MM    MM    MM  MM     MM    MM    MM    MM   MM   MM    MM\n"
+	var body atomic.Value
+	body.Store(good)
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body.Load().(string)))
+	})
+	if allNull(nil) {
+		t.Error("allNull(nil) = true, want false")
+	}
+
+	for _, skip := range []bool{true, false} {
+		cfg := config{Stations: []string{"SANF1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1, SkipAllNull: skip}
+		cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+		sink := testSink(cfg.DataDir)
+		resetPublished(t)
+		fetchStatus = nil
+		t.Cleanup(func() { fetchStatus = nil })
+		body.Store(good)
+		runOnce(context.Background(), cfg, sink)
+
+		body.Store(placeholder)
+		if _, err := runOnce(context.Background(), cfg, sink); err != nil {
+			t.Fatalf("SKIP_ALL_NULL=%v: %v", skip, err)
+		}
+		rows := readStored(t, sink, "SANF1_latest.parquet")
+		if skip && (len(rows) != 1 || rows[0].Time != time.Date(2024, 6, 10, 7, 0, 0, 0, time.UTC).Unix()) {
+			t.Errorf("SKIP_ALL_NULL=true: stored %d rows, want the 1 good one kept", len(rows))
+		}
+		if !skip && len(rows) != 2 {
+			t.Errorf("SKIP_ALL_NULL=false: stored %d rows, want the 2 placeholder rows", len(rows))
+		}
+		if s := fetchStatus["SANF1"]; s.Stale() != skip {
+			t.Errorf("SKIP_ALL_NULL=%v: fetch status %+v, stale %v", skip, s, s.Stale())
+		}
+	}
+}
+
+func TestBatchIDStampedPerCycle(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"))
+	})
+	cfg := config{Stations: []string{"SANF1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	sink := testSink(cfg.DataDir)
+
+	var ids []string
+	for range 2 {
+		runOnce(context.Background(), cfg, sink)
+		var m metrow.Manifest
+		b, _ := sink.Read(metrow.ManifestName)
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := uuid.Parse(m.BatchID); err != nil {
+			t.Fatalf("manifest batch_id %q: %v", m.BatchID, err)
+		}
+		ids = append(ids, m.BatchID)
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("both cycles have batch_id %s", ids[0])
+	}
+	// The data did not change, so the file is the first cycle's.
+	b, err := sink.Read("SANF1_latest.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pf.Lookup(metrow.BatchIDKey); got != ids[0] {
+		t.Errorf("file batch_id = %q, want %q", got, ids[0])
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+)
+
+// limiter is a small token bucket: up to burst calls proceed immediately,
+// after which calls are spaced 1/rps apart, as measured by clk. A nil
+// *limiter never blocks.
+type limiter struct {
+	mu     sync.Mutex
+	clk    clock.Clock
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(clk clock.Clock, rps float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{clk: clk, rps: rps, burst: float64(burst), tokens: float64(burst), last: clk.Now()}
+}
+
+// Wait blocks until a token is available or ctx is done. A cancelled wait
+// returns its reserved token to the bucket.
+func (l *limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.clk.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rps)
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rps * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	select {
+	case <-l.clk.After(wait):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+)
+
+func TestLimiterSpacesCalls(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1718000000, 0))
+	l := newLimiter(clk, 2, 1) // one call every 500ms
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- l.Wait(context.Background()) }()
+	for clk.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(400 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("second Wait returned early (%v)", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clk.Advance(100 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("second Wait: %v", err)
+	}
+
+	// After two idle seconds the bucket is full again (burst 1): no wait.
+	clk.Advance(2 * time.Second)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := clk.Waiters(); n != 0 {
+		t.Errorf("Wait after idle period left %d timers pending", n)
+	}
+}
+
+func TestLimiterCancelReturnsToken(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1718000000, 0))
+	l := newLimiter(clk, 1, 1)
+	l.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("Wait on cancelled context succeeded")
+	}
+	// The cancelled wait gave its token back: one second later a call
+	// goes through without blocking.
+	clk.Advance(time.Second)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if n := clk.Waiters(); n != 0 {
+		t.Errorf("Wait slept after the token was returned (%d timers pending)", n)
+	}
+}
+
+func TestNilLimiterNeverBlocks(t *testing.T) {
+	var l *limiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
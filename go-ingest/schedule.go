@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// schedule tracks when each station is next due, so stations with a
+// STATION_REFRESH override are fetched on their own cadence instead of the
+// global REFRESH_MINUTES.
+type schedule struct {
+	stations []string
+	every    map[string]time.Duration // upper-case station → interval
+	def      time.Duration
+	next     map[string]time.Time
+	// jitter scales each cycle's intervals by a random factor in
+	// [1-jitter, 1+jitter] (REFRESH_JITTER); zero keeps them exact.
+	jitter float64
+	rng    *rand.Rand
+}
+
+// newSchedule makes every station in stations due immediately.
+func newSchedule(stations []string, def time.Duration, every map[string]time.Duration) *schedule {
+	s := &schedule{every: every, def: def, next: make(map[string]time.Time)}
+	for _, st := range stations {
+		if st = strings.TrimSpace(st); st != "" {
+			s.stations = append(s.stations, st)
+			s.next[st] = time.Time{}
+		}
+	}
+	return s
+}
+
+// setJitter enables REFRESH_JITTER with an RNG seeded by seed.
+func (s *schedule) setJitter(jitter float64, seed int64) {
+	s.jitter = jitter
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
+func (s *schedule) interval(station string) time.Duration {
+	if d, ok := s.every[strings.ToUpper(station)]; ok {
+		return d
+	}
+	return s.def
+}
+
+// due returns the stations whose next fetch is at or before now, in
+// configuration order.
+func (s *schedule) due(now time.Time) []string {
+	var out []string
+	for _, st := range s.stations {
+		if !s.next[st].After(now) {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// done schedules the next fetch of each station one interval after now.
+// With jitter, the stations share one random factor, so a cycle's
+// stations stay due together.
+func (s *schedule) done(stations []string, now time.Time) {
+	factor := 1.0
+	if s.jitter > 0 {
+		factor += s.jitter * (2*s.rng.Float64() - 1)
+	}
+	for _, st := range stations {
+		s.next[st] = now.Add(time.Duration(float64(s.interval(st)) * factor))
+	}
+}
+
+// nextDue returns the earliest next fetch time of any station.
+func (s *schedule) nextDue() time.Time {
+	var first time.Time
+	for _, t := range s.next {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+	return first
+}
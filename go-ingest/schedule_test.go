@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestScheduleCadence(t *testing.T) {
+	s := newSchedule([]string{"SCHA1", " scha2 ", ""}, time.Hour, map[string]time.Duration{"SCHA1": 10 * time.Minute})
+	fetches := map[string]int{}
+	now := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	for end := now.Add(2 * time.Hour); now.Before(end); now = s.nextDue() {
+		due := s.due(now)
+		if len(due) == 0 {
+			t.Fatalf("woke at %s with nothing due", now)
+		}
+		for _, st := range due {
+			fetches[st]++
+		}
+		s.done(due, now)
+	}
+	if fetches["SCHA1"] != 12 || fetches["scha2"] != 2 {
+		t.Errorf("fetches over 2h = %v, want SCHA1:12 scha2:2", fetches)
+	}
+}
+
+func TestScheduleJitterKeepsStationsTogether(t *testing.T) {
+	s := newSchedule([]string{"SCHB1", "SCHB2"}, time.Hour, nil)
+	s.setJitter(0.1, 1)
+	now := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	varied := false
+	for range 20 {
+		due := s.due(now)
+		if len(due) != 2 {
+			t.Fatalf("at %s due %v, want both stations", now, due)
+		}
+		s.done(due, now)
+		next := s.nextDue()
+		d := next.Sub(now)
+		if d < 54*time.Minute || d > 66*time.Minute {
+			t.Errorf("interval %s outside 1h ± 10%%", d)
+		}
+		varied = varied || d != time.Hour
+		now = next
+	}
+	if !varied {
+		t.Error("every interval exactly 1h with jitter on")
+	}
+}
+
+func TestRefreshJitterConfig(t *testing.T) {
+	for v, want := range map[string]float64{"": 0, "0.1": 0.1, "25%": 0.25, " 5 % ": 0.05, "1": 0, "-0.1": 0, "lots": 0} {
+		t.Setenv("REFRESH_JITTER", v)
+		if got := loadConfig().Jitter; math.Abs(got-want) > 1e-9 {
+			t.Errorf("REFRESH_JITTER=%q: %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestStationRefresh(t *testing.T) {
+	t.Setenv("STATION_REFRESH", "scha1:10, SCHA2:x, SCHA3:0,,SCHA4:90")
+	got := stationRefresh()
+	if len(got) != 2 || got["SCHA1"] != 10*time.Minute || got["SCHA4"] != 90*time.Minute {
+		t.Errorf("stationRefresh = %v, want SCHA1:10m SCHA4:1h30m", got)
+	}
+}
+
+func TestPublishKeepsStationsNotDue(t *testing.T) {
+	resetPublished(t)
+	publish([]metrow.ManifestEntry{{Station: "SCHB1", Rows: 1}, {Station: "SCHA1", Rows: 1}})
+	got := publish([]metrow.ManifestEntry{{Station: "SCHA1", Rows: 2}})
+	if len(got) != 2 || got[0].Station != "SCHA1" || got[0].Rows != 2 || got[1].Station != "SCHB1" {
+		t.Errorf("publish = %+v, want SCHA1 (2 rows) and SCHB1", got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sentinelTally counts, per station and NDBC column, the values the parser
+// stored as null because they matched a sentinel (99, 999, 9999) during
+// the current cycle. It shows whether the sentinel rule is nulling real
+// readings.
+type sentinelTally struct {
+	mu        sync.Mutex
+	byStation map[string]map[string]int
+}
+
+// sentinels is reset at the start of every runOnce.
+var sentinels sentinelTally
+
+func (t *sentinelTally) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byStation = nil
+}
+
+// add records one station's counts and logs them.
+func (t *sentinelTally) add(station string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	station = strings.ToUpper(station)
+	log.Printf("INFO  %s: nulled sentinel values: %s", station, formatCounts(counts))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byStation == nil {
+		t.byStation = make(map[string]map[string]int)
+	}
+	t.byStation[station] = counts
+}
+
+// station returns the counts recorded for station this cycle, or nil.
+func (t *sentinelTally) station(station string) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.byStation[strings.ToUpper(station)]
+}
+
+// totals sums the counts of every station by column.
+func (t *sentinelTally) totals() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]int)
+	for _, counts := range t.byStation {
+		for col, n := range counts {
+			out[col] += n
+		}
+	}
+	return out
+}
+
+// formatCounts renders counts as "ATMP=2, WTMP=48", sorted by column.
+func formatCounts(counts map[string]int) string {
+	cols := make([]string, 0, len(counts))
+	for c := range counts {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = fmt.Sprintf("%s=%d", c, counts[c])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestSentinelsReportedPerStation(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 08 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0 999.0  20.0   MM   MM    MM\n" +
+			"2024 06 10 07 00 110  4.0  5.0    MM    MM    MM  MM 1013.0 999.0 999.0  20.0   MM   MM    MM\n"))
+	})
+	cfg := config{Stations: []string{"sanf1"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	results, err := runOnce(context.Background(), cfg, testSink(cfg.DataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"ATMP": 1, "WTMP": 2}; len(results) != 1 || !maps.Equal(results[0].Sentinels, want) {
+		t.Errorf("results = %+v, want sentinels %v", results, want)
+	}
+	if got := formatCounts(sentinels.totals()); got != "ATMP=1, WTMP=2" {
+		t.Errorf("cycle totals = %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// cycleMu is held for the duration of every runOnce, so a POST /refresh
+// never overlaps the scheduled cycle or another refresh.
+var cycleMu sync.Mutex
+
+// stationResult is the outcome of one station in a cycle.
+type stationResult struct {
+	Station string    `json:"station"`
+	OK      bool      `json:"ok"`
+	Path    string    `json:"path,omitempty"`
+	Rows    int       `json:"rows,omitempty"`
+	Newest  time.Time `json:"newest,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	// Sentinels counts values nulled as sentinels, by NDBC column.
+	Sentinels map[string]int `json:"sentinels,omitempty"`
+}
+
+func newStationResult(station string, e metrow.ManifestEntry, err error) stationResult {
+	r := stationResult{Station: strings.ToUpper(station)}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.OK, r.Path, r.Rows, r.Newest = true, e.Path, e.Rows, e.Newest
+	return r
+}
+
+type refreshResponse struct {
+	Started  time.Time       `json:"started"`
+	Duration string          `json:"duration"`
+	Stations []stationResult `json:"stations"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// refreshHandler serves POST /refresh: an immediate cycle over every
+// configured station, answered with the per-station results. It returns
+// 409 while another cycle is running.
+func refreshHandler(cfg config, sink Sink) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !cycleMu.TryLock() {
+			http.Error(w, "a cycle is already running", http.StatusConflict)
+			return
+		}
+		defer cycleMu.Unlock()
+
+		log.Printf("INFO  refresh requested by %s", r.RemoteAddr)
+		start := cfg.Clock.Now()
+		// Not r.Context(): a client hanging up must not leave half-written
+		// station files behind.
+		results, err := runOnce(context.Background(), cfg, sink)
+		resp := refreshResponse{
+			Started:  start.UTC(),
+			Duration: cfg.Clock.Now().Sub(start).Round(time.Millisecond).String(),
+			Stations: results,
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		if resp.Stations == nil {
+			resp.Stations = []stationResult{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("ERROR encode /refresh: %v", err)
+		}
+	}
+}
+
+// serveControl runs go-ingest's HTTP server on port: POST /refresh and
+// GET /healthz.
+func serveControl(port string, cfg config, sink Sink) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/refresh", refreshHandler(cfg, sink))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	s := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("INFO  control server on :%s (POST /refresh)", port)
+	if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("ERROR control server: %v", err)
+	}
+}
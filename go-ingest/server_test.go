@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "BAD01") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"))
+	})
+	cfg := config{Stations: []string{"sanf1", "BAD01"}, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	h := refreshHandler(cfg, testSink(cfg.DataDir))
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/refresh", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp refreshResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Stations) != 2 {
+		t.Fatalf("stations = %+v", resp.Stations)
+	}
+	bad, good := resp.Stations[0], resp.Stations[1]
+	if bad.Station != "BAD01" || bad.OK || bad.Error == "" {
+		t.Errorf("BAD01 = %+v, want a failure", bad)
+	}
+	if good.Station != "SANF1" || !good.OK || good.Rows != 1 || good.Path != "SANF1_latest.parquet" {
+		t.Errorf("SANF1 = %+v", good)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/refresh", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("GET: status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	// A refresh while a cycle holds cycleMu is refused, not queued.
+	cycleMu.Lock()
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/refresh", nil))
+	cycleMu.Unlock()
+	if w.Code != http.StatusConflict {
+		t.Errorf("during a cycle: status %d, want 409", w.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/internal/objstore"
+)
+
+// Sink stores a finished output file under name (relative to the sink's
+// root). Readers must never observe a partially written file. Read returns
+// a previously stored file.
+type Sink interface {
+	Write(name string, data []byte) error
+	Read(name string) ([]byte, error)
+}
+
+// locker is implemented by sinks that can serialize writers of one file
+// across goroutines and processes. Lock blocks until name is free and
+// returns the function that releases it.
+type locker interface {
+	Lock(name string) (unlock func(), err error)
+}
+
+// lockFile locks name when sink supports it; otherwise unlock is a no-op.
+func lockFile(sink Sink, name string) (func(), error) {
+	if l, ok := sink.(locker); ok {
+		return l.Lock(name)
+	}
+	return func() {}, nil
+}
+
+// Default permissions for local output (FILE_MODE, DIR_MODE).
+const (
+	defaultFileMode os.FileMode = 0o644
+	defaultDirMode  os.FileMode = 0o755
+)
+
+// localSink writes into a directory using a .tmp file + rename. Files and
+// created directories are chmod'ed to the configured modes so the result
+// does not depend on the process umask. With durable set (DURABLE_WRITE),
+// the .tmp file is fsynced before the rename and the directory after it,
+// so a crash cannot leave an empty or missing file behind.
+type localSink struct {
+	dir      string
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	durable  bool
+}
+
+func (s localSink) Write(name string, data []byte) error {
+	p := filepath.Join(s.dir, name)
+	if err := s.mkdirAll(filepath.Dir(p)); err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := s.writeTemp(tmp, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, s.fileMode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return err
+	}
+	if s.durable {
+		return syncDir(filepath.Dir(p))
+	}
+	return nil
+}
+
+// syncFile is the part of *os.File a durable write needs.
+type syncFile interface {
+	io.Writer
+	Sync() error
+	Close() error
+}
+
+// openSync opens the files and directories that DURABLE_WRITE fsyncs;
+// tests replace it to observe the Sync calls.
+var openSync = func(name string, flag int, perm os.FileMode) (syncFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+// writeTemp writes data to tmp, fsyncing it first when s is durable.
+func (s localSink) writeTemp(tmp string, data []byte) error {
+	if !s.durable {
+		return os.WriteFile(tmp, data, s.fileMode)
+	}
+	f, err := openSync(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// mkdirAll creates dir and any missing parents with dirMode.
+func (s localSink) mkdirAll(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := s.mkdirAll(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, s.dirMode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.Chmod(dir, s.dirMode)
+}
+
+func (s localSink) Read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, name))
+}
+
+func (s localSink) String() string { return s.dir }
+
+// s3Sink uploads to an S3-compatible bucket under prefix.
+type s3Sink struct {
+	client *objstore.Client
+	prefix string
+}
+
+func (s s3Sink) Write(name string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	return s.client.Put(ctx, path.Join(s.prefix, name), data, contentTypeOf(name))
+}
+
+func (s s3Sink) Read(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	return s.client.Get(ctx, path.Join(s.prefix, name))
+}
+
+func (s s3Sink) String() string {
+	return "s3://" + path.Join(s.client.Bucket, s.prefix)
+}
+
+func contentTypeOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".parquet"):
+		return "application/vnd.apache.parquet"
+	case strings.HasSuffix(name, ".csv"):
+		return "text/csv"
+	}
+	return "application/octet-stream"
+}
+
+// newSink builds the output sink selected by STORAGE (local or s3).
+func newSink(dataDir string) (Sink, error) {
+	switch storage := metrow.Getenv("STORAGE", "local"); storage {
+	case "local":
+		durable, _ := strconv.ParseBool(metrow.Getenv("DURABLE_WRITE", "false"))
+		return localSink{
+			dir:      dataDir,
+			fileMode: parseMode("FILE_MODE", defaultFileMode),
+			dirMode:  parseMode("DIR_MODE", defaultDirMode),
+			durable:  durable,
+		}, nil
+	case "s3":
+		c, err := objstore.NewFromEnv(metrow.Getenv("S3_BUCKET", ""))
+		if err != nil {
+			return nil, err
+		}
+		return s3Sink{client: c, prefix: strings.Trim(metrow.Getenv("S3_PREFIX", ""), "/")}, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q (want local or s3)", storage)
+	}
+}
+
+// parseMode reads an octal permission (e.g. 0664 or 664) from env key,
+// falling back to def when unset or invalid.
+func parseMode(key string, def os.FileMode) os.FileMode {
+	v := metrow.Getenv(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(v, "0o"), 8, 32)
+	if err != nil || n > 0o777 {
+		log.Printf("WARN  invalid %s %q (using %#o)", key, v, def)
+		return def
+	}
+	return os.FileMode(n)
+}
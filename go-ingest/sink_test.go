@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/objstore"
+)
+
+// testSink is a localSink with the default permissions.
+func testSink(dir string) localSink {
+	return localSink{dir: dir, fileMode: defaultFileMode, dirMode: defaultDirMode}
+}
+
+func TestLocalSinkWrite(t *testing.T) {
+	dir := t.TempDir()
+	s := testSink(dir)
+	if err := s.Write("sub/SANF1_latest.parquet", []byte("PAR1")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "sub", "SANF1_latest.parquet"))
+	if err != nil || string(got) != "PAR1" {
+		t.Fatalf("read back %q, %v", got, err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "sub", "*.tmp")); len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestLocalSinkModes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("STORAGE", "local")
+	t.Setenv("FILE_MODE", "0640")
+	t.Setenv("DIR_MODE", "750")
+	s, err := newSink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("a/b/SANF1_latest.parquet", []byte("PAR1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path string
+		want os.FileMode
+	}{
+		{filepath.Join(dir, "a"), 0o750},
+		{filepath.Join(dir, "a", "b"), 0o750},
+		{filepath.Join(dir, "a", "b", "SANF1_latest.parquet"), 0o640},
+	} {
+		fi, err := os.Stat(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fi.Mode().Perm(); got != tt.want {
+			t.Errorf("%s: mode %#o, want %#o", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for v, want := range map[string]os.FileMode{
+		"":      defaultFileMode,
+		"0664":  0o664,
+		"600":   0o600,
+		"0o660": 0o660,
+		"0999":  defaultFileMode,
+		"1777":  defaultFileMode,
+	} {
+		t.Setenv("FILE_MODE", v)
+		if got := parseMode("FILE_MODE", defaultFileMode); got != want {
+			t.Errorf("FILE_MODE=%q: %#o, want %#o", v, got, want)
+		}
+	}
+}
+
+func TestS3SinkWrite(t *testing.T) {
+	var gotPath, gotType, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method %s, want PUT", r.Method)
+		}
+		b, _ := io.ReadAll(r.Body)
+		gotPath, gotType, gotAuth, gotBody = r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(b)
+	}))
+	defer srv.Close()
+
+	c := &objstore.Client{Endpoint: srv.URL, Region: "us-east-1", Bucket: "buoys", AccessKey: "AK", SecretKey: "SK", HTTP: srv.Client()}
+	s := s3Sink{client: c, prefix: "ndbc"}
+	if err := s.Write("SANF1_latest.parquet", []byte("PAR1")); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/buoys/ndbc/SANF1_latest.parquet" {
+		t.Errorf("path %q", gotPath)
+	}
+	if gotType != "application/vnd.apache.parquet" {
+		t.Errorf("content type %q", gotType)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AK/") {
+		t.Errorf("authorization %q", gotAuth)
+	}
+	if gotBody != "PAR1" {
+		t.Errorf("body %q", gotBody)
+	}
+	if got := s.String(); got != "s3://buoys/ndbc" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	t.Setenv("STORAGE", "local")
+	if s, err := newSink("/data"); err != nil || s != testSink("/data") {
+		t.Errorf("local: %v, %v", s, err)
+	}
+	t.Setenv("STORAGE", "s3")
+	t.Setenv("S3_BUCKET", "")
+	if _, err := newSink("/data"); err == nil {
+		t.Error("s3 without a bucket should fail")
+	}
+	t.Setenv("STORAGE", "ftp")
+	if _, err := newSink("/data"); err == nil {
+		t.Error("unknown STORAGE should fail")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// knownLongitudes holds the longitude (degrees east) of the default
+// Straits of Florida stations, used for the solar_time column.
+var knownLongitudes = map[string]float64{
+	"SANF1": -81.877,
+	"SMKF1": -81.110,
+	"LONF1": -80.862,
+	"VAKF1": -80.166,
+	"KYWF1": -81.808,
+}
+
+// stationLongitudes returns knownLongitudes extended or overridden by
+// STATION_LONGITUDES, a comma-separated list of STATION=LON pairs.
+func stationLongitudes() map[string]float64 {
+	out := make(map[string]float64, len(knownLongitudes))
+	for k, v := range knownLongitudes {
+		out[k] = v
+	}
+	for _, pair := range strings.Split(metrow.Getenv("STATION_LONGITUDES", ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		id, v, ok := strings.Cut(pair, "=")
+		lon, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if !ok || err != nil || lon < -180 || lon > 180 {
+			log.Printf("WARN  invalid STATION_LONGITUDES entry %q (ignored)", pair)
+			continue
+		}
+		out[strings.ToUpper(strings.TrimSpace(id))] = lon
+	}
+	return out
+}
+
+// stationRefresh parses STATION_REFRESH, a comma-separated list of
+// STATION:MINUTES pairs overriding REFRESH_MINUTES per station.
+func stationRefresh() map[string]time.Duration {
+	out := make(map[string]time.Duration)
+	for _, pair := range strings.Split(metrow.Getenv("STATION_REFRESH", ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		id, v, ok := strings.Cut(pair, ":")
+		mins, err := strconv.Atoi(strings.TrimSpace(v))
+		if !ok || err != nil || mins <= 0 {
+			log.Printf("WARN  invalid STATION_REFRESH entry %q (ignored)", pair)
+			continue
+		}
+		out[strings.ToUpper(strings.TrimSpace(id))] = time.Duration(mins) * time.Minute
+	}
+	return out
+}
+
+// activeStationsURL is NDBC's station list; tests point it at a local server.
+var activeStationsURL = ndbc.ActiveStationsURL
+
+// fetchActiveStations downloads and parses NDBC's active station list.
+func fetchActiveStations(ctx context.Context) ([]ndbc.Station, error) {
+	b, _, err := fetchOnce(ctx, clock.Real{}, "activestations.xml", activeStationsURL)
+	if err != nil {
+		return nil, err
+	}
+	return ndbc.ParseActiveStations(b)
+}
+
+// bbox is a latitude/longitude rectangle. minLon > maxLon means the box
+// crosses the antimeridian.
+type bbox struct {
+	minLat, minLon, maxLat, maxLon float64
+}
+
+// parseBBox parses "minlat,minlon,maxlat,maxlon" in decimal degrees.
+func parseBBox(v string) (bbox, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) != 4 {
+		return bbox{}, fmt.Errorf("want minlat,minlon,maxlat,maxlon")
+	}
+	var f [4]float64
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return bbox{}, fmt.Errorf("%q is not a number", p)
+		}
+		f[i] = n
+	}
+	b := bbox{minLat: f[0], minLon: f[1], maxLat: f[2], maxLon: f[3]}
+	switch {
+	case b.minLat < -90 || b.maxLat > 90 || b.minLat > b.maxLat:
+		return bbox{}, fmt.Errorf("latitudes must satisfy -90 <= minlat <= maxlat <= 90")
+	case b.minLon < -180 || b.minLon > 180 || b.maxLon < -180 || b.maxLon > 180:
+		return bbox{}, fmt.Errorf("longitudes must be within [-180,180]")
+	}
+	return b, nil
+}
+
+func (b bbox) contains(lat, lon float64) bool {
+	if lat < b.minLat || lat > b.maxLat {
+		return false
+	}
+	if b.minLon <= b.maxLon {
+		return lon >= b.minLon && lon <= b.maxLon
+	}
+	return lon >= b.minLon || lon <= b.maxLon
+}
+
+// selectStations returns the stations in all that carry MODE's data (met,
+// or DART with MODE=dart) and lie inside box, if given, sorted by ID.
+func selectStations(all []ndbc.Station, box *bbox, mode string) []ndbc.Station {
+	var out []ndbc.Station
+	for _, s := range all {
+		if mode == "dart" && !s.Dart || mode != "dart" && !s.Met {
+			continue
+		}
+		if box != nil && !box.contains(s.Lat, s.Lon) {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// addBBoxStations adds the active stations inside BBOX to cfg.Stations.
+// An explicit STATIONS list is kept and the region's stations are added to
+// it; without one, the region replaces the default list. STATIONS_EXCLUDE
+// applies to both. Longitudes from the station list fill in solar_time for
+// stations STATION_LONGITUDES does not cover.
+func addBBoxStations(ctx context.Context, cfg *config, v string) error {
+	box, err := parseBBox(v)
+	if err != nil {
+		return err
+	}
+	all, err := fetchActiveStations(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	if metrow.Getenv("STATIONS", "") != "" {
+		ids = cfg.Stations
+	}
+	have := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		have[strings.ToUpper(strings.TrimSpace(id))] = true
+	}
+	var found []string
+	region := selectStations(all, &box, cfg.Mode)
+	for _, s := range region {
+		if _, ok := cfg.Longitudes[s.ID]; !ok {
+			cfg.Longitudes[s.ID] = s.Lon
+		}
+		if !have[s.ID] {
+			found = append(found, s.ID)
+		}
+	}
+	found = excludeStations(found, metrow.Getenv("STATIONS_EXCLUDE", ""))
+	log.Printf("INFO  BBOX %s: %d stations in region, %d added: %s", v, len(region), len(found), strings.Join(found, ","))
+	cfg.Stations = append(ids, found...)
+	if len(cfg.Stations) == 0 {
+		return fmt.Errorf("no active stations in %s and no STATIONS set", v)
+	}
+	return nil
+}
+
+// listStations prints the active met stations (DART stations with
+// MODE=dart), limited to BBOX when set, followed by a STATIONS line ready
+// to paste into the configuration.
+func listStations(ctx context.Context, w io.Writer, mode string) error {
+	var box *bbox
+	if v := metrow.Getenv("BBOX", ""); v != "" {
+		b, err := parseBBox(v)
+		if err != nil {
+			return fmt.Errorf("invalid BBOX: %w", err)
+		}
+		box = &b
+	}
+	all, err := fetchActiveStations(ctx)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLAT\tLON\tTYPE\tOWNER\tNAME")
+	for _, s := range selectStations(all, box, mode) {
+		ids = append(ids, s.ID)
+		fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%s\t%s\t%s\n", s.ID, s.Lat, s.Lon, s.Type, s.Owner, s.Name)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d stations\nSTATIONS=%s\n", len(ids), strings.Join(ids, ","))
+	return nil
+}
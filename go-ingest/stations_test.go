@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStationLongitudes(t *testing.T) {
+	t.Setenv("STATION_LONGITUDES", " 42040=-88.2, sanf1=-81.9,bad,X=200,Y=abc")
+	got := stationLongitudes()
+	if got["42040"] != -88.2 {
+		t.Errorf("42040 = %v, want -88.2", got["42040"])
+	}
+	if got["SANF1"] != -81.9 {
+		t.Errorf("SANF1 = %v, want the override -81.9", got["SANF1"])
+	}
+	if got["SMKF1"] != knownLongitudes["SMKF1"] {
+		t.Errorf("SMKF1 = %v, want the built-in value", got["SMKF1"])
+	}
+	for _, id := range []string{"BAD", "X", "Y"} {
+		if _, ok := got[id]; ok {
+			t.Errorf("invalid entry %s was kept", id)
+		}
+	}
+	if knownLongitudes["SANF1"] != -81.877 {
+		t.Error("STATION_LONGITUDES modified knownLongitudes")
+	}
+}
+
+const activeStationsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<stations created="2024-06-10T12:00:00UTC" count="4">
+<station id="smkf1" lat="24.628" lon="-81.111" name="Sombrero Key, FL" owner="NDBC" pgm="NDBC Meteorological/Ocean" type="fixed" met="y" currents="n" waterquality="n" dart="n"/>
+<station id="sanf1" lat="24.456" lon="-81.877" name="Sand Key, FL" owner="NDBC" pgm="NDBC Meteorological/Ocean" type="fixed" met="y" currents="n" waterquality="n" dart="n"/>
+<station id="41001" lat="34.724" lon="-72.317" name="East Hatteras" owner="NDBC" pgm="NDBC Meteorological/Ocean" type="buoy" met="y" currents="n" waterquality="n" dart="n"/>
+<station id="21413" lat="30.528" lon="152.132" name="SOUTHEAST TOKYO" owner="NDBC" pgm="Tsunami" type="dart" met="n" currents="n" waterquality="n" dart="y"/>
+</stations>
+`
+
+// stubActiveStations points activeStationsURL at a server answering with
+// activeStationsXML.
+func stubActiveStations(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(activeStationsXML))
+	}))
+	t.Cleanup(srv.Close)
+	old := activeStationsURL
+	activeStationsURL = srv.URL
+	t.Cleanup(func() { activeStationsURL = old })
+}
+
+func TestListStations(t *testing.T) {
+	stubActiveStations(t)
+	t.Setenv("BBOX", "24,-82,25,-81")
+	var out strings.Builder
+	if err := listStations(context.Background(), &out, "stdmet"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "\n2 stations\nSTATIONS=SANF1,SMKF1\n") || !strings.Contains(out.String(), "Sand Key, FL") {
+		t.Errorf("output:\n%s", out.String())
+	}
+
+	t.Setenv("BBOX", "")
+	out.Reset()
+	if err := listStations(context.Background(), &out, "dart"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "STATIONS=21413\n") {
+		t.Errorf("dart output:\n%s", out.String())
+	}
+
+	t.Setenv("BBOX", "25,-82,24,-81")
+	if err := listStations(context.Background(), &out, "stdmet"); err == nil {
+		t.Error("inverted BBOX accepted")
+	}
+}
+
+func TestBBoxAntimeridian(t *testing.T) {
+	b, err := parseBBox("-60, 170, 60, -170")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		lon  float64
+		want bool
+	}{{175, true}, {-175, true}, {180, true}, {0, false}, {-160, false}} {
+		if got := b.contains(0, tt.lon); got != tt.want {
+			t.Errorf("contains(0, %g) = %t, want %t", tt.lon, got, tt.want)
+		}
+	}
+	if _, err := parseBBox("1,2,3"); err == nil {
+		t.Error("three values accepted")
+	}
+}
+
+func TestAddBBoxStations(t *testing.T) {
+	stubActiveStations(t)
+	const box = "24,-82,25,-81"
+
+	// Without STATIONS the region replaces the default list.
+	t.Setenv("STATIONS", "")
+	t.Setenv("STATIONS_EXCLUDE", "smkf1")
+	cfg := config{Stations: []string{"42040"}, Longitudes: map[string]float64{"SANF1": -80}}
+	if err := addBBoxStations(context.Background(), &cfg, box); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Stations, []string{"SANF1"}) {
+		t.Errorf("Stations = %q, want [SANF1]", cfg.Stations)
+	}
+	if cfg.Longitudes["SANF1"] != -80 || cfg.Longitudes["SMKF1"] != -81.111 {
+		t.Errorf("Longitudes = %v, want the override kept and SMKF1 filled in", cfg.Longitudes)
+	}
+
+	// An explicit STATIONS list is kept and extended without duplicates.
+	t.Setenv("STATIONS", "sanf1,41001")
+	t.Setenv("STATIONS_EXCLUDE", "")
+	cfg = config{Stations: []string{"sanf1", "41001"}, Longitudes: map[string]float64{}}
+	if err := addBBoxStations(context.Background(), &cfg, box); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Stations, []string{"sanf1", "41001", "SMKF1"}) {
+		t.Errorf("Stations = %q, want sanf1,41001,SMKF1", cfg.Stations)
+	}
+
+	// An empty region with no STATIONS is an error, not an idle ingest.
+	t.Setenv("STATIONS", "")
+	cfg = config{Longitudes: map[string]float64{}}
+	if err := addBBoxStations(context.Background(), &cfg, "-10,0,-5,5"); err == nil {
+		t.Error("empty region accepted")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// contentHash fingerprints what a station file would contain: the column
+// set, the rows, and the key/value metadata other than provenance. It is
+// independent of Parquet encoding details, so identical input always
+// hashes the same.
+func contentHash(cols []metrow.Column, rows []metrow.MetRow, meta map[string]string) (string, error) {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	if len(meta) > 0 {
+		kept := make(map[string]string, len(meta))
+		for k, v := range meta {
+			if !slices.Contains(metrow.ProvenanceKeys, k) {
+				kept[k] = v
+			}
+		}
+		meta = kept
+	}
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, v := range []any{names, rows, meta} {
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storedHash returns the content hash recorded in the file currently
+// stored under name, or "" when there is no such file or it carries no
+// hash. The stored file is always consulted, so a file deleted or replaced
+// behind go-ingest's back is rewritten on the next cycle.
+func storedHash(sink Sink, name string) string {
+	b, err := sink.Read(name)
+	if err != nil {
+		return ""
+	}
+	pf, err := parquet.OpenFile(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return ""
+	}
+	h, _ := pf.Lookup(metrow.ContentHashKey)
+	return h
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestUnchangedFileNotRewritten(t *testing.T) {
+	dir := t.TempDir()
+	sink := testSink(dir)
+	cols := metrow.DefaultColumns()
+	const name = "SANF1_latest.parquet"
+	rows := func(wspd float64) []metrow.MetRow {
+		return []metrow.MetRow{{StationID: "SANF1", Time: 1718000000, WSPDmS: &wspd}}
+	}
+	write := func(r []metrow.MetRow) bool {
+		t.Helper()
+		wrote, err := writeParquet(sink, name, cols, r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wrote
+	}
+	path := filepath.Join(dir, name)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	age := func() {
+		t.Helper()
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !write(rows(5)) {
+		t.Fatal("first write skipped")
+	}
+	age()
+	if write(rows(5)) {
+		t.Error("unchanged rows were rewritten")
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("file touched (err %v)", err)
+	}
+
+	// A file removed between cycles is written again even though this
+	// process wrote the same content before.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if !write(rows(5)) {
+		t.Error("deleted file was not rewritten")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("file not restored: %v", err)
+	}
+
+	age()
+	if !write(rows(6)) {
+		t.Error("changed rows were not written")
+	}
+	if fi, _ := os.Stat(path); fi.ModTime().Equal(old) {
+		t.Error("changed rows left the old file in place")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// Webhook delivery policy: each POST is bounded by webhookTimeout and a
+// failed one is retried up to webhookRetries times, backing off
+// exponentially from webhookBackoff.
+const (
+	webhookTimeout = 10 * time.Second
+	webhookRetries = 2
+	webhookBackoff = 2 * time.Second
+)
+
+// webhookPayload is the JSON summary POSTed to WEBHOOK_URL after a cycle.
+// Files lists the station files written this cycle, leaving out those
+// found unchanged; Failed lists the stations that failed. Status is "ok"
+// when no station failed and "partial" when some did.
+type webhookPayload struct {
+	Cycle       time.Time              `json:"cycle"`
+	BatchID     string                 `json:"batch_id"`
+	Status      string                 `json:"status"`
+	Files       []metrow.ManifestEntry `json:"files"`
+	Failed      []string               `json:"failed"`
+	FailedCount int                    `json:"failed_count"`
+}
+
+// newWebhookPayload summarizes a finished cycle.
+func newWebhookPayload(cycle time.Time, batchID string, files []metrow.ManifestEntry, results []stationResult) webhookPayload {
+	p := webhookPayload{Cycle: cycle, BatchID: batchID, Status: "ok", Files: files, Failed: []string{}}
+	for _, r := range results {
+		if !r.OK {
+			p.Failed = append(p.Failed, r.Station)
+		}
+	}
+	if p.FailedCount = len(p.Failed); p.FailedCount > 0 {
+		p.Status = "partial"
+	}
+	return p
+}
+
+// notifyWebhook POSTs p to url, retrying failures. It only logs: a webhook
+// that is down never fails the cycle.
+func notifyWebhook(ctx context.Context, clk clock.Clock, url string, p webhookPayload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("ERROR webhook: %v", err)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		err := postWebhook(ctx, url, body)
+		if err == nil {
+			log.Printf("SENT  webhook %s (%d files)", url, len(p.Files))
+			return
+		}
+		if attempt >= webhookRetries {
+			log.Printf("WARN  webhook %s: %v; giving up", url, err)
+			return
+		}
+		wait := webhookBackoff << attempt
+		log.Printf("WARN  webhook %s: %v, retrying in %s", url, err, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(wait):
+		}
+	}
+}
+
+// postWebhook makes one delivery attempt; any non-2xx status is an error.
+func postWebhook(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/arrow-buoys/internal/clock"
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// webhookRecorder is a WEBHOOK_URL endpoint that keeps every payload and
+// answers the first failures requests with a 500.
+type webhookRecorder struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	payloads []webhookPayload
+}
+
+func (h *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.calls++; h.calls <= h.failures {
+		http.Error(w, "down", http.StatusInternalServerError)
+		return
+	}
+	var p webhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.payloads = append(h.payloads, p)
+}
+
+func (h *webhookRecorder) received() []webhookPayload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.payloads
+}
+
+// webhookConfig is a cycle over stations into a temp dir that notifies a
+// new webhookRecorder.
+func webhookConfig(t *testing.T, stations ...string) (config, *webhookRecorder) {
+	t.Helper()
+	hook := &webhookRecorder{}
+	srv := httptest.NewServer(hook)
+	t.Cleanup(srv.Close)
+	cfg := config{Stations: stations, DataDir: t.TempDir(), Clock: clock.Real{}, MaxNullFields: -1, WebhookURL: srv.URL}
+	cfg.Filename, _ = metrow.ParseFilenameTemplate("")
+	resetPublished(t)
+	return cfg, hook
+}
+
+func TestWebhookAfterCycle(t *testing.T) {
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "WHKB1") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+			"2024 06 10 06 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8  26.0  20.1   MM   MM    MM\n"))
+	})
+	cfg, hook := webhookConfig(t, "WHKA1", "WHKB1")
+	if _, err := runOnce(context.Background(), cfg, testSink(cfg.DataDir)); err != nil {
+		t.Fatal(err)
+	}
+	got := hook.received()
+	if len(got) != 1 {
+		t.Fatalf("webhook received %d payloads, want 1", len(got))
+	}
+	p := got[0]
+	if p.Cycle.IsZero() || p.BatchID == "" {
+		t.Errorf("cycle %s batch %q", p.Cycle, p.BatchID)
+	}
+	if p.Status != "partial" || p.FailedCount != 1 || len(p.Failed) != 1 || p.Failed[0] != "WHKB1" {
+		t.Errorf("status %q failed %v (%d), want partial [WHKB1] (1)", p.Status, p.Failed, p.FailedCount)
+	}
+	if len(p.Files) != 1 || p.Files[0].Station != "WHKA1" || p.Files[0].Rows != 2 {
+		t.Errorf("files = %+v, want WHKA1 with 2 rows", p.Files)
+	}
+}
+
+func TestWebhookListsOnlyChangedFiles(t *testing.T) {
+	// WHKB1's reading changes every cycle; WHKA1's never does.
+	var cycle atomic.Int32
+	stubNDBC(t, func(w http.ResponseWriter, r *http.Request) {
+		wspd := "5.0"
+		if strings.Contains(r.URL.Path, "WHKB1") {
+			wspd = []string{"5.0", "6.0", "7.0"}[cycle.Load()]
+		}
+		w.Write([]byte(stdmetHeader +
+			"2024 06 10 07 00 120  " + wspd + "  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"))
+	})
+	cfg, hook := webhookConfig(t, "WHKA1", "WHKB1")
+	cfg.Columns = metrow.DefaultColumns()
+	sink := testSink(cfg.DataDir)
+	for range 2 {
+		runOnce(context.Background(), cfg, sink)
+		cycle.Add(1)
+	}
+	got := hook.received()
+	if len(got) != 2 || len(got[0].Files) != 2 {
+		t.Fatalf("payloads = %+v, want 2 with both files first", got)
+	}
+	if files := got[1].Files; len(files) != 1 || files[0].Station != "WHKB1" {
+		t.Errorf("second cycle files = %+v, want only the changed WHKB1", files)
+	}
+	if got[1].Status != "ok" || got[1].FailedCount != 0 {
+		t.Errorf("second cycle status %q failed %d, want ok 0", got[1].Status, got[1].FailedCount)
+	}
+
+	// Nothing changes: nothing to report.
+	cycle.Store(1)
+	runOnce(context.Background(), cfg, sink)
+	if n := len(hook.received()); n != 2 {
+		t.Errorf("webhook called for a cycle with only unchanged files (%d payloads)", n)
+	}
+}
+
+func TestWebhookSkippedWhenNothingWritten(t *testing.T) {
+	stubNDBC(t, http.NotFound)
+	cfg, hook := webhookConfig(t, "WHKC1", "WHKD1")
+	if _, err := runOnce(context.Background(), cfg, testSink(cfg.DataDir)); err != nil {
+		t.Fatal(err)
+	}
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.calls != 0 {
+		t.Errorf("webhook called %d times for a cycle where every station failed", hook.calls)
+	}
+}
+
+func TestNotifyWebhookRetries(t *testing.T) {
+	hook := &webhookRecorder{failures: 2}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	clk := clock.NewFake(time.Date(2024, 6, 10, 7, 30, 0, 0, time.UTC))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notifyWebhook(context.Background(), clk, srv.URL, webhookPayload{BatchID: "b1", Status: "ok"})
+	}()
+	for _, wait := range []time.Duration{webhookBackoff, 2 * webhookBackoff} {
+		for clk.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clk.Advance(wait)
+	}
+	<-done
+	if got := hook.received(); len(got) != 1 || got[0].BatchID != "b1" {
+		t.Errorf("payloads = %+v after %d calls", got, hook.calls)
+	}
+}
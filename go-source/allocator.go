@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/apache/arrow/go/v16/arrow/memory"
+)
+
+// ALLOCATOR values.
+const (
+	allocatorGo      = "go"
+	allocatorChecked = "checked"
+)
+
+// allocatorKind is the Arrow allocator responses are built with. Set once
+// in main from ALLOCATOR.
+var allocatorKind = allocatorGo
+
+// setAllocator validates an ALLOCATOR value and selects it.
+func setAllocator(v string) error {
+	switch v {
+	case allocatorGo, allocatorChecked:
+		allocatorKind = v
+		return nil
+	}
+	return fmt.Errorf("want %s or %s", allocatorGo, allocatorChecked)
+}
+
+// newAllocator returns the allocator for one response to endpoint and the
+// function to defer until every buffer built with it is released. With
+// ALLOCATOR=checked that function logs any bytes still outstanding, which
+// point at a missing Release.
+func newAllocator(endpoint string) (memory.Allocator, func()) {
+	if allocatorKind != allocatorChecked {
+		return memory.NewGoAllocator(), func() {}
+	}
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	return mem, func() {
+		if n := mem.CurrentAlloc(); n != 0 {
+			log.Printf("WARN  %s: %d bytes of Arrow buffers not released", endpoint, n)
+		}
+	}
+}
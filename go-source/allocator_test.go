@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useCheckedAllocator selects ALLOCATOR=checked for the test and returns
+// the log, where a response that leaks Arrow buffers leaves a WARN.
+func useCheckedAllocator(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	old := allocatorKind
+	if err := setAllocator(allocatorChecked); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		allocatorKind = old
+		log.SetOutput(out)
+	})
+	return &buf
+}
+
+func TestStreamReleasesArrowBuffers(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 5))
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 3))
+	logs := useCheckedAllocator(t)
+
+	for _, query := range []string{"", "?order=time", "?group=station", "?at=1718007200"} {
+		logs.Reset()
+		w := httptest.NewRecorder()
+		streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("/stream%s: status %d", query, w.Code)
+		}
+		if strings.Contains(logs.String(), "not released") {
+			t.Errorf("/stream%s leaked: %s", query, logs)
+		}
+	}
+}
+
+func TestCheckedAllocatorReportsLeak(t *testing.T) {
+	logs := useCheckedAllocator(t)
+	mem, release := newAllocator("/test")
+	mem.Allocate(64)
+	release()
+	if !strings.Contains(logs.String(), "/test: 64 bytes of Arrow buffers not released") {
+		t.Errorf("leak not reported; log: %q", logs)
+	}
+}
+
+func TestSetAllocator(t *testing.T) {
+	old := allocatorKind
+	t.Cleanup(func() { allocatorKind = old })
+	if err := setAllocator("jemalloc"); err == nil || allocatorKind != old {
+		t.Errorf("setAllocator(jemalloc) = %v, kind %q", err, allocatorKind)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// latestAt returns the row with the greatest Time at or before at, and
+// false when every row is later.
+func latestAt(rows []metrow.MetRow, at int64) (metrow.MetRow, bool) {
+	best := -1
+	for i, r := range rows {
+		if r.Time <= at && (best < 0 || r.Time > rows[best].Time) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return metrow.MetRow{}, false
+	}
+	return rows[best], true
+}
+
+// asOfRows returns, per station in dirs, the latest observation at or
+// before at, sorted by station. Stations with nothing that early are left
+// out.
+func asOfRows(dirs []string, at time.Time) []metrow.MetRow {
+	var out []metrow.MetRow
+	for _, g := range findShards(dirs, filenameTemplate()) {
+		rows, err := readShards(g)
+		if err != nil {
+			log.Printf("WARN readParquet %s: %v", g.Station, err)
+			continue
+		}
+		for _, rows := range splitStations(g, rows) {
+			if r, ok := latestAt(rows, at.Unix()); ok {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StationID < out[j].StationID })
+	return out
+}
+
+// writeAsOf writes the ?at= snapshot: one record with a row per station.
+func writeAsOf(wr recordWriter, mem memory.Allocator, schema *arrow.Schema, cols []metrow.Column, dirs []string, at time.Time) {
+	rows := asOfRows(dirs, at)
+	if len(rows) == 0 {
+		return
+	}
+	rec := rowsToRecord(mem, schema, cols, rows)
+	defer rec.Release()
+	if err := wr.Write(rec); err != nil {
+		log.Printf("ERROR ipc write ?at=: %v", err)
+		return
+	}
+	stats.records.Add(1)
+	log.Printf("SENT  %d stations as of %s", len(rows), at.UTC().Format(time.RFC3339))
+}
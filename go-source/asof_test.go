@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAsOfRows(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 5))
+	late := hourlyRows("SMKF1", 2)
+	for i := range late {
+		late[i].Time += 10 * 3600
+	}
+	writeStation(t, dir, "SMKF1", late)
+
+	// Between SANF1's third and fourth observations, before any of SMKF1's.
+	rows := asOfRows([]string{dir}, time.Unix(1718000000+2*3600+10, 0))
+	if len(rows) != 1 || rows[0].StationID != "SANF1" || *rows[0].WSPDmS != 2 {
+		t.Fatalf("asOfRows = %+v, want SANF1's third row only", rows)
+	}
+	if rows := asOfRows([]string{dir}, time.Unix(1718000000+20*3600, 0)); len(rows) != 2 || *rows[1].WSPDmS != 1 {
+		t.Errorf("asOfRows after both = %+v, want each station's newest", rows)
+	}
+
+	rec := httptest.NewRecorder()
+	streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?at=2024-06-10T09:00:00Z", nil))
+	if got := streamStations(t, rec.Body); len(got) != 1 || got["SANF1"] != 1 {
+		t.Errorf("/stream?at= stations = %v, want one SANF1 row", got)
+	}
+	rec = httptest.NewRecorder()
+	streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?at=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad at: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/internal/objstore"
+)
+
+// errChecksum reports a station file that does not match its sidecar.
+var errChecksum = errors.New("checksum mismatch")
+
+// verifyChecksums reports VERIFY_CHECKSUM.
+func verifyChecksums() bool {
+	on, _ := strconv.ParseBool(metrow.Getenv("VERIFY_CHECKSUM", "false"))
+	return on
+}
+
+// verifyChecksum checks p against the <p>.sha256 sidecar go-ingest writes
+// with WRITE_CHECKSUM=sha256. A file without a sidecar passes.
+func verifyChecksum(p string) error {
+	side, err := readObject(p + ".sha256")
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, objstore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	want, _, _ := strings.Cut(strings.TrimSpace(string(side)), " ")
+	data, err := readObject(p)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%s: sha256 %s, sidecar says %s: %w", p, got, want, errChecksum)
+	}
+	return nil
+}
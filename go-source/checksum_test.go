@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := useDataDir(t)
+	t.Setenv("VERIFY_CHECKSUM", "true")
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 3))
+	p := filepath.Join(dir, "SANF1_latest.parquet")
+
+	// No sidecar: the file is served as is.
+	if rows, err := readParquet(p); err != nil || len(rows) != 3 {
+		t.Fatalf("without sidecar: %d rows, err %v", len(rows), err)
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if err := os.WriteFile(p+".sha256", []byte(hex.EncodeToString(sum[:])+"  SANF1_latest.parquet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksum(p); err != nil {
+		t.Errorf("matching sidecar: %v", err)
+	}
+
+	if err := os.WriteFile(p+".sha256", []byte("00ff  SANF1_latest.parquet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readParquet(p); !errors.Is(err, errChecksum) {
+		t.Errorf("readParquet err = %v, want errChecksum", err)
+	}
+	if _, err := openCursor(p); !errors.Is(err, errChecksum) {
+		t.Errorf("openCursor err = %v, want errChecksum", err)
+	}
+}
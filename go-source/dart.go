@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// dartTemplate returns DART_FILENAME_TEMPLATE, the names go-ingest gives
+// DART files in MODE=dart. main validates it at startup.
+func dartTemplate() metrow.FilenameTemplate {
+	t, err := metrow.ParseFilenameTemplate(metrow.Getenv("DART_FILENAME_TEMPLATE", metrow.DefaultDartFilenameTemplate))
+	if err != nil {
+		t, _ = metrow.ParseFilenameTemplate(metrow.DefaultDartFilenameTemplate)
+	}
+	return t.ForDataset(metrow.DartDataset)
+}
+
+// readDart reads all DartRows from a local or s3:// Parquet file.
+func readDart(path string) ([]metrow.DartRow, error) {
+	f, _, err := openSource(path)
+	if err != nil {
+		stats.readErrors.Add(1)
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := ndbc.ReadDartParquet(f)
+	if err != nil {
+		stats.readErrors.Add(1)
+	}
+	return rows, err
+}
+
+// dartHandler serves every station's DART water-column heights as an Arrow
+// IPC stream with metrow.DartSchema.
+func dartHandler(w http.ResponseWriter, r *http.Request) {
+	const contentType = "application/vnd.apache.arrow.stream"
+	if !serveHeadOrReject(w, r, contentType) {
+		return
+	}
+	dirs := dataDirs()
+	matches := findFiles(dirs, dartTemplate())
+	if len(matches) == 0 {
+		log.Printf("WARN no DART files in %s", strings.Join(dirs, ":"))
+	}
+
+	mem, release := newAllocator("/dart")
+	defer release()
+	w.Header().Set("Content-Type", contentType)
+	wr := ipc.NewWriter(w, ipc.WithSchema(metrow.DartSchema()), ipc.WithAllocator(mem))
+	defer wr.Close()
+
+	batch := batchSize()
+	for _, p := range matches {
+		rows, err := readDart(p)
+		if err != nil {
+			log.Printf("WARN readDart %s: %v", p, err)
+			continue
+		}
+		for start := 0; start < len(rows); start += batch {
+			end := min(start+batch, len(rows))
+			rec := metrow.DartRecord(mem, rows[start:end])
+			err := wr.Write(rec)
+			rec.Release()
+			if err != nil {
+				log.Printf("ERROR ipc write %s: %v", p, err)
+				return
+			}
+			stats.records.Add(1)
+		}
+		log.Printf("SENT  %s (%d rows)", p, len(rows))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+func TestDartStream(t *testing.T) {
+	dir := useDataDir(t)
+	h := 5812.345
+	var buf bytes.Buffer
+	err := ndbc.WriteDartParquet(&buf, []metrow.DartRow{
+		{StationID: "21413", Time: 1718000000, HeightM: &h, Type: 1},
+		{StationID: "21413", Time: 1718000060, Type: 2, Event: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "21413_dart.parquet"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A stdmet station file in the same directory is not part of /dart.
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 3))
+
+	w := httptest.NewRecorder()
+	dartHandler(w, httptest.NewRequest(http.MethodGet, "/dart", nil))
+	rd, err := ipc.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Release()
+	if !rd.Schema().Equal(metrow.DartSchema()) {
+		t.Errorf("schema %s", rd.Schema())
+	}
+	n := 0
+	for rd.Next() {
+		rec := rd.Record()
+		heights := rec.Column(2).(*array.Float64)
+		events := rec.Column(4).(*array.Boolean)
+		if heights.Value(0) != h || !heights.IsNull(1) || events.Value(0) || !events.Value(1) {
+			t.Errorf("record %v", rec)
+		}
+		n += int(rec.NumRows())
+	}
+	if n != 2 {
+		t.Errorf("%d rows, want 2", n)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// debugRowsHandler serves GET /debug/rows?station=SANF1&limit=10: the
+// station's first rows exactly as read from Parquet, as indented JSON with
+// every field present (nil fields as null). It is only registered when
+// DEBUG=true.
+func debugRowsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	station := strings.ToUpper(q.Get("station"))
+	if station == "" {
+		http.Error(w, "station is required", http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, ok, err := stationRows(station)
+	if !ok {
+		http.Error(w, "no data for station "+station, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("WARN readParquet %s: %v", station, err)
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return
+	}
+	rows = rows[:min(limit, len(rows))]
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rows); err != nil {
+		log.Printf("ERROR encode /debug/rows: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugRows(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 5))
+
+	w := httptest.NewRecorder()
+	debugRowsHandler(w, httptest.NewRequest(http.MethodGet, "/debug/rows?station=sanf1&limit=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["station_id"] != "SANF1" {
+		t.Fatalf("rows = %v", rows)
+	}
+	// Unset fields are listed as null rather than left out.
+	if v, ok := rows[0]["time_iso"]; !ok || v != nil {
+		t.Errorf("time_iso = %v, %t; want null", v, ok)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusBadRequest},
+		{"station=SANF1&limit=-1", http.StatusBadRequest},
+		{"station=NOPE1", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		debugRowsHandler(w, httptest.NewRequest(http.MethodGet, "/debug/rows?"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+}
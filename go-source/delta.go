@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// maxCursorBytes caps the POST /delta request body.
+const maxCursorBytes = 1 << 20
+
+// deltaRows returns, per station, the rows of byStation newer than the
+// station's entry in cursor (epoch seconds), in time order. Stations
+// missing from cursor keep all their rows; stations left with none are
+// dropped.
+func deltaRows(byStation map[string][]metrow.MetRow, cursor map[string]int64) map[string][]metrow.MetRow {
+	out := make(map[string][]metrow.MetRow, len(byStation))
+	for st, rows := range byStation {
+		last, ok := cursor[strings.ToUpper(st)]
+		var kept []metrow.MetRow
+		for _, r := range rows {
+			if !ok || r.Time > last {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].Time < kept[j].Time })
+		out[st] = kept
+	}
+	return out
+}
+
+// deltaHandler serves POST /delta. The body is a JSON object mapping
+// station IDs to the newest time (epoch seconds) the client already has,
+// e.g. {"SANF1": 1718000000}; the response holds only newer rows, per
+// station, and every row of stations not in the map. It is an Arrow IPC
+// stream with one record per station, or a JSON array of rows with
+// ?format=json.
+func deltaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "arrow" {
+		http.Error(w, "format must be json or arrow", http.StatusBadRequest)
+		return
+	}
+	var raw map[string]int64
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCursorBytes)).Decode(&raw); err != nil {
+		http.Error(w, "body must be a JSON object of station to epoch seconds: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	cursor := make(map[string]int64, len(raw))
+	for st, t := range raw {
+		cursor[strings.ToUpper(strings.TrimSpace(st))] = t
+	}
+
+	byStation := deltaRows(rowsByStation(dataDirs()), cursor)
+	stations := make([]string, 0, len(byStation))
+	for st := range byStation {
+		stations = append(stations, st)
+	}
+	sort.Strings(stations)
+
+	if format == "json" {
+		out := []metrow.MetRow{}
+		for _, st := range stations {
+			out = append(out, byStation[st]...)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			log.Printf("ERROR encode /delta: %v", err)
+		}
+		return
+	}
+
+	mem, release := newAllocator("/delta")
+	defer release()
+	cols := selectedColumns()
+	schema := streamSchema(cols)
+	w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+	wr := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	defer wr.Close()
+	for _, st := range stations {
+		rec := rowsToRecord(mem, schema, cols, byStation[st])
+		err := wr.Write(rec)
+		rec.Release()
+		if err != nil {
+			log.Printf("ERROR ipc write /delta %s: %v", st, err)
+			return
+		}
+		stats.records.Add(1)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestDelta(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 5))
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 3))
+	writeStation(t, dir, "LONF1", hourlyRows("LONF1", 2))
+	// SANF1 has its first three hours, LONF1 everything, SMKF1 nothing.
+	cursor := `{"sanf1": 1718007200, "LONF1": 1718003600}`
+
+	newer := func(rows []metrow.MetRow) map[string][]int64 {
+		out := map[string][]int64{}
+		for _, r := range rows {
+			out[r.StationID] = append(out[r.StationID], r.Time)
+		}
+		return out
+	}
+	check := func(format string, got map[string][]int64) {
+		t.Helper()
+		if len(got) != 2 || len(got["SANF1"]) != 2 || got["SANF1"][0] != 1718010800 || len(got["SMKF1"]) != 3 {
+			t.Errorf("%s: rows by station = %v, want SANF1's last 2 and all 3 of SMKF1", format, got)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	deltaHandler(w, httptest.NewRequest(http.MethodPost, "/delta?format=json", strings.NewReader(cursor)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("json: status %d: %s", w.Code, w.Body)
+	}
+	var rows []metrow.MetRow
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	check("json", newer(rows))
+
+	w = httptest.NewRecorder()
+	deltaHandler(w, httptest.NewRequest(http.MethodPost, "/delta", strings.NewReader(cursor)))
+	rows, _ = decodeStream(t, w.Body)
+	check("arrow", newer(rows))
+
+	for _, bad := range []string{"", "[1,2]", `{"SANF1": "yesterday"}`} {
+		w = httptest.NewRecorder()
+		deltaHandler(w, httptest.NewRequest(http.MethodPost, "/delta", strings.NewReader(bad)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %q: status %d, want 400", bad, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	deltaHandler(w, httptest.NewRequest(http.MethodGet, "/delta", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", w.Code)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// diffSide is one station's latest observation in /diff.
+type diffSide struct {
+	Station string `json:"station"`
+	Time    int64  `json:"time"`
+}
+
+type diffResponse struct {
+	A diffSide `json:"a"`
+	B diffSide `json:"b"`
+	// Diffs holds a minus b per numeric column; null when either station
+	// has no value.
+	Diffs map[string]*float64 `json:"diffs"`
+}
+
+// fieldDiffs returns a minus b for every numeric column in cols, nil where
+// either row lacks the value.
+func fieldDiffs(a, b metrow.MetRow, cols []metrow.Column) map[string]*float64 {
+	out := make(map[string]*float64)
+	for _, c := range cols {
+		if !c.Numeric() {
+			continue
+		}
+		va, okA := c.Float(&a)
+		vb, okB := c.Float(&b)
+		if !okA || !okB {
+			out[c.Name] = nil
+			continue
+		}
+		d := va - vb
+		out[c.Name] = &d
+	}
+	return out
+}
+
+// latestRow returns station's most recent observation. When there is none
+// or the read fails it writes the error response and returns false.
+func latestRow(w http.ResponseWriter, station string) (metrow.MetRow, bool) {
+	rows, ok, err := stationRows(station)
+	if err != nil {
+		log.Printf("WARN readParquet %s: %v", station, err)
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return metrow.MetRow{}, false
+	}
+	r, found := latestAt(rows, math.MaxInt64)
+	if !ok || !found {
+		http.Error(w, "no data for station "+station, http.StatusNotFound)
+		return metrow.MetRow{}, false
+	}
+	return r, true
+}
+
+// diffHandler serves GET /diff?a=SANF1&b=SMKF1: the field-wise difference
+// (a minus b) between the two stations' latest observations, over the
+// numeric COLUMNS.
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	a, b := strings.ToUpper(q.Get("a")), strings.ToUpper(q.Get("b"))
+	if a == "" || b == "" {
+		http.Error(w, "a and b are required", http.StatusBadRequest)
+		return
+	}
+	ra, ok := latestRow(w, a)
+	if !ok {
+		return
+	}
+	rb, ok := latestRow(w, b)
+	if !ok {
+		return
+	}
+
+	resp := diffResponse{
+		A:     diffSide{Station: a, Time: ra.Time},
+		B:     diffSide{Station: b, Time: rb.Time},
+		Diffs: fieldDiffs(ra, rb, selectedColumns()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("ERROR encode /diff: %v", err)
+	}
+}
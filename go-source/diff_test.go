@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 4))
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 2))
+
+	w := httptest.NewRecorder()
+	diffHandler(w, httptest.NewRequest(http.MethodGet, "/diff?a=sanf1&b=SMKF1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp diffResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.A != (diffSide{"SANF1", 1718000000 + 3*3600}) || resp.B != (diffSide{"SMKF1", 1718000000 + 3600}) {
+		t.Errorf("sides = %+v, %+v", resp.A, resp.B)
+	}
+	// Latest wspd_ms is 3 at SANF1 and 1 at SMKF1; neither has pres_hpa.
+	if d := resp.Diffs["wspd_ms"]; d == nil || *d != 2 {
+		t.Errorf("wspd_ms diff = %v, want 2", d)
+	}
+	if d, ok := resp.Diffs["pres_hpa"]; !ok || d != nil {
+		t.Errorf("pres_hpa diff = %v (present %v), want null", d, ok)
+	}
+
+	for query, code := range map[string]int{"a=SANF1": 400, "a=SANF1&b=NOPE1": 404, "a=NOPE1&b=SANF1": 404} {
+		w := httptest.NewRecorder()
+		diffHandler(w, httptest.NewRequest(http.MethodGet, "/diff?"+query, nil))
+		if w.Code != code {
+			t.Errorf("?%s: status %d, want %d", query, w.Code, code)
+		}
+	}
+}
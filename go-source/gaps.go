@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// gap is a stretch between two consecutive observations that are further
+// apart than expected. Start and End are the bounding observation times
+// (epoch seconds).
+type gap struct {
+	Start    int64 `json:"start"`
+	End      int64 `json:"end"`
+	Duration int64 `json:"duration_s"`
+}
+
+type gapsResponse struct {
+	Station  string `json:"station"`
+	Expected int64  `json:"expected_s"`
+	Rows     int    `json:"rows"`
+	Gaps     []gap  `json:"gaps"`
+}
+
+// findGaps returns the spans between consecutive distinct observation
+// times in rows longer than expected seconds. rows need not be sorted.
+func findGaps(rows []metrow.MetRow, expected int64) []gap {
+	times := make([]int64, len(rows))
+	for i, r := range rows {
+		times[i] = r.Time
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	out := []gap{}
+	for i := 1; i < len(times); i++ {
+		if d := times[i] - times[i-1]; d > expected {
+			out = append(out, gap{Start: times[i-1], End: times[i], Duration: d})
+		}
+	}
+	return out
+}
+
+// gapsHandler serves GET /gaps?station=SANF1&expected=3600: the gaps in the
+// station's time series longer than expected seconds (default 3600).
+func gapsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	station := strings.ToUpper(q.Get("station"))
+	if station == "" {
+		http.Error(w, "station is required", http.StatusBadRequest)
+		return
+	}
+	expected := int64(3600)
+	if v := q.Get("expected"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "expected must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+		expected = n
+	}
+	rows, ok, err := stationRows(station)
+	if !ok {
+		http.Error(w, "no data for station "+station, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("WARN readParquet %s: %v", station, err)
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return
+	}
+
+	resp := gapsResponse{Station: station, Expected: expected, Rows: len(rows), Gaps: findGaps(rows, expected)}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("ERROR encode /gaps: %v", err)
+	}
+}
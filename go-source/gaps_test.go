@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestGaps(t *testing.T) {
+	dir := useDataDir(t)
+	rows := hourlyRows("SANF1", 10)
+	// Drop hours 3-4 and 7, and store newest first.
+	rows = slices.Delete(rows, 7, 8)
+	rows = slices.Delete(rows, 3, 5)
+	slices.Reverse(rows)
+	writeStation(t, dir, "SANF1", rows)
+
+	get := func(query string) (*httptest.ResponseRecorder, gapsResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		gapsHandler(w, httptest.NewRequest(http.MethodGet, "/gaps?"+query, nil))
+		var resp gapsResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w, resp
+	}
+
+	const t0 = 1718000000
+	_, resp := get("station=sanf1")
+	want := []gap{{Start: t0 + 2*3600, End: t0 + 5*3600, Duration: 3 * 3600}, {Start: t0 + 6*3600, End: t0 + 8*3600, Duration: 2 * 3600}}
+	if resp.Station != "SANF1" || resp.Rows != 7 || !slices.Equal(resp.Gaps, want) {
+		t.Errorf("/gaps = %+v, want gaps %+v", resp, want)
+	}
+	if _, resp := get("station=SANF1&expected=7200"); len(resp.Gaps) != 1 || resp.Gaps[0].Duration != 3*3600 {
+		t.Errorf("expected=7200: gaps %+v, want only the 3h one", resp.Gaps)
+	}
+
+	for query, code := range map[string]int{"": 400, "station=SANF1&expected=0": 400, "station=NOPE1": 404} {
+		if w, _ := get(query); w.Code != code {
+			t.Errorf("?%s: status %d, want %d", query, w.Code, code)
+		}
+	}
+	if got := findGaps([]metrow.MetRow{{Time: t0}}, 3600); got == nil || len(got) != 0 {
+		t.Errorf("one row: %v, want an empty list", got)
+	}
+}
@@ -1,8 +1,9 @@
-module example.com/arrow-buoys-source
+module example.com/arrow-buoys/go-source
 
 go 1.22
 
 require (
+	example.com/arrow-buoys v0.0.0-00010101000000-000000000000
 	github.com/apache/arrow/go/v16 v16.1.0
 	github.com/parquet-go/parquet-go v0.23.0
 )
@@ -26,3 +27,5 @@ require (
 	golang.org/x/tools v0.19.0 // indirect
 	golang.org/x/xerrors v0.0.0-20231012003039-104605ab7028 // indirect
 )
+
+replace example.com/arrow-buoys => ../
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"sort"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// rowsByStation reads every station's rows in dirs, however the files are
+// laid out. A station's own file wins over its rows in a combined file
+// (COMBINED_OUTPUT), as in stationRows.
+func rowsByStation(dirs []string) map[string][]metrow.MetRow {
+	out := make(map[string][]metrow.MetRow)
+	own := make(map[string]bool)
+	for _, g := range findShards(dirs, filenameTemplate()) {
+		rows, err := readShards(g)
+		if err != nil {
+			log.Printf("WARN readParquet %s: %v", g.Station, err)
+			continue
+		}
+		for st, rows := range splitStations(g, rows) {
+			if g.Combined() && own[st] {
+				continue
+			}
+			out[st] = rows
+			own[st] = own[st] || !g.Combined()
+		}
+	}
+	return out
+}
+
+// writeGrouped writes /stream?group=station: one record per station,
+// stations in ID order, whatever the file layout. BATCH_SIZE does not
+// apply, so a record always holds exactly one station's rows.
+func writeGrouped(wr recordWriter, mem memory.Allocator, schema *arrow.Schema, cols []metrow.Column, dirs []string) {
+	byStation := rowsByStation(dirs)
+	stations := make([]string, 0, len(byStation))
+	for st := range byStation {
+		stations = append(stations, st)
+	}
+	sort.Strings(stations)
+	for _, st := range stations {
+		rows := byStation[st]
+		if len(rows) == 0 {
+			continue
+		}
+		rec := rowsToRecord(mem, schema, cols, rows)
+		err := wr.Write(rec)
+		rec.Release()
+		if err != nil {
+			log.Printf("ERROR ipc write %s: %v", st, err)
+			return
+		}
+		stats.records.Add(1)
+		log.Printf("SENT  %s (%d rows, one record)", st, len(rows))
+	}
+}
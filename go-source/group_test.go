@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestStreamGroupStation(t *testing.T) {
+	dir := useDataDir(t)
+	t.Setenv("BATCH_SIZE", "1000")
+	writeStation(t, dir, metrow.CombinedStation, append(hourlyRows("SANF1", 2), hourlyRows("SMKF1", 2)...))
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 3))
+	writeStation(t, dir, "41001", hourlyRows("41001", 2500))
+
+	rec := httptest.NewRecorder()
+	streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?group=station", nil))
+	rd, err := ipc.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Release()
+	var got []string
+	for rd.Next() {
+		ids := rd.Record().Column(0).(*array.String)
+		for i := 1; i < ids.Len(); i++ {
+			if ids.Value(i) != ids.Value(0) {
+				t.Errorf("record mixes %s and %s", ids.Value(0), ids.Value(i))
+			}
+		}
+		got = append(got, fmt.Sprintf("%s:%d", ids.Value(0), ids.Len()))
+	}
+	// BATCH_SIZE does not split 41001; SMKF1 comes from its own file.
+	if want := []string{"41001:2500", "SANF1:2", "SMKF1:3"}; !slices.Equal(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+
+	for _, q := range []string{"group=file", "group=station&order=time", "group=station&at=1718000000"} {
+		rec := httptest.NewRecorder()
+		streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("?%s: status %d, want 400", q, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// jsonRow is one /json row; when smoothing, the EMA is appended as an
+// extra "<field>_ema" member.
+type jsonRow struct {
+	metrow.MetRow
+	emaName string
+	ema     *float64
+}
+
+func (r jsonRow) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(r.MetRow)
+	if err != nil || r.emaName == "" {
+		return b, err
+	}
+	v := []byte("null")
+	if r.ema != nil {
+		if v, err = json.Marshal(*r.ema); err != nil {
+			return nil, err
+		}
+	}
+	b = bytes.TrimSuffix(b, []byte("}"))
+	b = append(b, `,"`+r.emaName+`":`...)
+	b = append(b, v...)
+	return append(b, '}'), nil
+}
+
+// ema computes the exponential moving average of c over rows, which must be
+// one station's rows sorted by time: s = alpha*x + (1-alpha)*s, seeded with
+// the first non-null value. Null readings do not update the average and get
+// a nil result.
+func ema(rows []metrow.MetRow, c metrow.Column, alpha float64) []*float64 {
+	out := make([]*float64, len(rows))
+	var s float64
+	seeded := false
+	for i := range rows {
+		x, ok := c.Float(&rows[i])
+		if !ok {
+			continue
+		}
+		if seeded {
+			s = alpha*x + (1-alpha)*s
+		} else {
+			s, seeded = x, true
+		}
+		v := s
+		out[i] = &v
+	}
+	return out
+}
+
+// jsonHandler serves every station's rows as a JSON array. With
+// ?smooth=wspd_ms&alpha=0.3 each row also carries wspd_ms_ema, computed per
+// station in time order.
+func jsonHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var smooth metrow.Column
+	alpha := 0.0
+	if name := q.Get("smooth"); name != "" {
+		c, ok := metrow.LookupColumn(name)
+		if !ok || !c.Numeric() {
+			http.Error(w, "unknown field "+name, http.StatusBadRequest)
+			return
+		}
+		a, err := strconv.ParseFloat(q.Get("alpha"), 64)
+		if err != nil || a <= 0 || a > 1 {
+			http.Error(w, "alpha must be in (0,1]", http.StatusBadRequest)
+			return
+		}
+		smooth, alpha = c, a
+	}
+
+	out := []jsonRow{}
+	for _, g := range findShards(dataDirs(), filenameTemplate()) {
+		rows, err := readShards(g)
+		if err != nil {
+			log.Printf("WARN readParquet %s: %v", g.Station, err)
+			continue
+		}
+		if smooth.Name == "" {
+			for _, row := range rows {
+				out = append(out, jsonRow{MetRow: row})
+			}
+			continue
+		}
+		byStation := splitStations(g, rows)
+		stations := make([]string, 0, len(byStation))
+		for st := range byStation {
+			stations = append(stations, st)
+		}
+		sort.Strings(stations)
+		for _, st := range stations {
+			rows := byStation[st]
+			sort.SliceStable(rows, func(i, j int) bool { return rows[i].Time < rows[j].Time })
+			for i, v := range ema(rows, smooth, alpha) {
+				out = append(out, jsonRow{MetRow: rows[i], emaName: smooth.Name + "_ema", ema: v})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("ERROR encode /json: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestEMA(t *testing.T) {
+	c, _ := metrow.LookupColumn("wspd_ms")
+	rows := []metrow.MetRow{
+		{Time: 1},
+		{Time: 2, WSPDmS: f64(10)},
+		{Time: 3},
+		{Time: 4, WSPDmS: f64(20)},
+		{Time: 5, WSPDmS: f64(0)},
+	}
+	got := ema(rows, c, 0.5)
+	want := []*float64{nil, f64(10), nil, f64(15), f64(7.5)}
+	for i := range want {
+		switch {
+		case (got[i] == nil) != (want[i] == nil):
+			t.Errorf("row %d: got %v, want %v", i, got[i], want[i])
+		case got[i] != nil && *got[i] != *want[i]:
+			t.Errorf("row %d: got %g, want %g", i, *got[i], *want[i])
+		}
+	}
+}
+
+func TestJSONSmooth(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718003600, WSPDmS: f64(20)},
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: f64(10)},
+	})
+
+	w := httptest.NewRecorder()
+	jsonHandler(w, httptest.NewRequest(http.MethodGet, "/json?smooth=wspd_ms&alpha=0.5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["time"] != 1718000000.0 || rows[0]["wspd_ms_ema"] != 10.0 || rows[1]["wspd_ms_ema"] != 15.0 {
+		t.Errorf("rows = %v", rows)
+	}
+	if v, ok := rows[0]["pres_hpa"]; !ok || v != nil {
+		t.Errorf("pres_hpa = %v (present %v), want null", v, ok)
+	}
+
+	for _, q := range []string{"smooth=nope&alpha=0.5", "smooth=qc_flags&alpha=0.5", "smooth=wspd_ms&alpha=0", "smooth=wspd_ms&alpha=1.5"} {
+		w := httptest.NewRecorder()
+		jsonHandler(w, httptest.NewRequest(http.MethodGet, "/json?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Long-poll limits. maxWait stays below the server's WriteTimeout so a
+// client that waited the full period still has time to receive the body.
+const (
+	maxWait      = 45 * time.Second
+	pollInterval = time.Second
+)
+
+// dataModHeader carries the newest data modification time (Unix seconds)
+// so clients can pass it back as ?since= on their next poll.
+const dataModHeader = "X-Data-Modified"
+
+// latestModTime returns the newest modification time of the station files
+// /stream would serve from dirs.
+func latestModTime(dirs []string) time.Time {
+	var latest time.Time
+	seen := make(map[string]bool)
+	for _, d := range dirs {
+		files, err := listStored(d)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			station := stationOf(f.Path)
+			if seen[station] {
+				continue
+			}
+			seen[station] = true
+			if f.ModTime.After(latest) {
+				latest = f.ModTime
+			}
+		}
+	}
+	return latest
+}
+
+// parseSince accepts Unix seconds or an RFC 3339 timestamp.
+func parseSince(s string) (time.Time, error) {
+	return parseTimeParam("since", s)
+}
+
+// parseTimeParam parses query parameter name as Unix seconds or an
+// RFC 3339 timestamp.
+func parseTimeParam(name, s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: want Unix seconds or RFC 3339, got %q", name, s)
+	}
+	return t, nil
+}
+
+// parseWait reads ?wait= and ?since= from the query. A zero wait means
+// the request is not a long poll.
+func parseWait(r *http.Request) (wait time.Duration, since time.Time, err error) {
+	q := r.URL.Query()
+	if v := q.Get("wait"); v != "" {
+		if wait, err = time.ParseDuration(v); err != nil || wait < 0 {
+			return 0, time.Time{}, fmt.Errorf("wait: invalid duration %q", v)
+		}
+		wait = min(wait, maxWait)
+	}
+	if v := q.Get("since"); v != "" {
+		if since, err = parseSince(v); err != nil {
+			return 0, time.Time{}, err
+		}
+	}
+	return wait, since, nil
+}
+
+// waitForChange polls dirs until a station file is newer than since (at
+// one-second resolution, matching ?since=), wait elapses, or ctx is
+// cancelled. It returns the newest modification time seen.
+func waitForChange(ctx context.Context, dirs []string, since time.Time, wait time.Duration) time.Time {
+	latest := latestModTime(dirs)
+	if wait <= 0 || latest.Unix() > since.Unix() {
+		return latest
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return latest
+		case <-deadline.C:
+			return latest
+		case <-tick.C:
+			if latest = latestModTime(dirs); latest.Unix() > since.Unix() {
+				return latest
+			}
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apache/arrow/go/v16/arrow"
@@ -15,178 +18,706 @@ import (
 	"github.com/apache/arrow/go/v16/arrow/memory"
 
 	parquet "github.com/parquet-go/parquet-go"
-)
 
-// MetRow must mirror the schema written by go-ingest.
-type MetRow struct {
-	StationID string   `parquet:"station_id"`
-	Time      int64    `parquet:"time"`
-	WDIRDeg   *int32   `parquet:"wdir_deg"`
-	WSPDmS    *float64 `parquet:"wspd_ms"`
-	GUSTmS    *float64 `parquet:"gust_ms"`
-	PREShPa   *float64 `parquet:"pres_hpa"`
-	ATMPC     *float64 `parquet:"atmp_c"`
-	WTMPC     *float64 `parquet:"wtmp_c"`
-	DEWPC     *float64 `parquet:"dewp_c"`
-}
-
-func buildSchema() *arrow.Schema {
-	ts := &arrow.TimestampType{Unit: arrow.Second, TimeZone: "UTC"}
-	return arrow.NewSchema([]arrow.Field{
-		{Name: "station_id", Type: arrow.BinaryTypes.String, Nullable: false},
-		{Name: "time", Type: ts, Nullable: false},
-		{Name: "wdir_deg", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
-		{Name: "wspd_ms", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
-		{Name: "gust_ms", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
-		{Name: "pres_hpa", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
-		{Name: "atmp_c", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
-		{Name: "wtmp_c", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
-		{Name: "dewp_c", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
-	}, nil)
-}
-
-func appendOptF64(b *array.Float64Builder, p *float64) {
-	if p == nil {
-		b.AppendNull()
-	} else {
-		b.Append(*p)
-	}
-}
-
-func rowsToRecord(mem memory.Allocator, schema *arrow.Schema, rows []MetRow) arrow.Record {
-	ts := &arrow.TimestampType{Unit: arrow.Second, TimeZone: "UTC"}
-
-	sb := array.NewStringBuilder(mem)
-	tb := array.NewTimestampBuilder(mem, ts)
-	wdirb := array.NewInt32Builder(mem)
-	wspdb := array.NewFloat64Builder(mem)
-	gustb := array.NewFloat64Builder(mem)
-	presb := array.NewFloat64Builder(mem)
-	atmpb := array.NewFloat64Builder(mem)
-	wtmpb := array.NewFloat64Builder(mem)
-	dewpb := array.NewFloat64Builder(mem)
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
 
-	defer func() {
-		sb.Release(); tb.Release(); wdirb.Release()
-		wspdb.Release(); gustb.Release(); presb.Release()
-		atmpb.Release(); wtmpb.Release(); dewpb.Release()
-	}()
+// rowsToRecord builds one Arrow record from rows: station_id and time (in
+// the schema's unit), followed by the selected cols in schema order.
+func rowsToRecord(mem memory.Allocator, schema *arrow.Schema, cols []metrow.Column, rows []metrow.MetRow) arrow.Record {
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
 
-	for _, r := range rows {
+	sb := b.Field(0).(*array.StringBuilder)
+	tb := b.Field(1).(*array.TimestampBuilder)
+	unit := schema.Field(1).Type.(*arrow.TimestampType).Unit
+	for i := range rows {
+		r := &rows[i]
 		sb.Append(r.StationID)
-		tb.Append(arrow.Timestamp(r.Time))
-		if r.WDIRDeg == nil {
-			wdirb.AppendNull()
-		} else {
-			wdirb.Append(*r.WDIRDeg)
+		tb.Append(metrow.ToTimestamp(r.Time, unit))
+		for j, c := range cols {
+			c.AppendArrow(b.Field(j+2), r)
 		}
-		appendOptF64(wspdb, r.WSPDmS)
-		appendOptF64(gustb, r.GUSTmS)
-		appendOptF64(presb, r.PREShPa)
-		appendOptF64(atmpb, r.ATMPC)
-		appendOptF64(wtmpb, r.WTMPC)
-		appendOptF64(dewpb, r.DEWPC)
 	}
+	return b.NewRecord()
+}
 
-	cols := []arrow.Array{
-		sb.NewArray(), tb.NewArray(),
-		wdirb.NewArray(), wspdb.NewArray(), gustb.NewArray(),
-		presb.NewArray(), atmpb.NewArray(), wtmpb.NewArray(), dewpb.NewArray(),
+// readParquet reads all MetRows from a local or s3:// Parquet file. With
+// VERIFY_CHECKSUM=true the file must first match its .sha256 sidecar.
+func readParquet(path string) ([]metrow.MetRow, error) {
+	if verifyChecksums() {
+		if err := verifyChecksum(path); err != nil {
+			stats.readErrors.Add(1)
+			return nil, err
+		}
 	}
-	rec := array.NewRecord(schema, cols, int64(len(rows)))
-	for _, c := range cols {
-		c.Release()
+	f, _, err := openSource(path)
+	if err != nil {
+		stats.readErrors.Add(1)
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := ndbc.ReadParquet(f)
+	if err != nil {
+		stats.readErrors.Add(1)
 	}
-	return rec
+	return rows, err
 }
 
-// readParquet reads all MetRows from a Parquet file using the generic reader.
-func readParquet(path string) ([]MetRow, error) {
-	f, err := os.Open(path)
+// openParquetFile opens path for metadata inspection. The caller must close
+// the returned source once done with the parquet.File.
+func openParquetFile(path string) (*parquet.File, io.Closer, error) {
+	f, size, err := openSource(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	pf, err := parquet.OpenFile(f, size)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return pf, f, nil
+}
+
+// schemaMismatches compares the schema of the parquet file at path with the
+// expected schema for cols and returns one description per incompatible column.
+func schemaMismatches(path string, cols []metrow.Column) ([]string, error) {
+	pf, f, err := openParquetFile(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	r := parquet.NewGenericReader[MetRow](f)
-	defer r.Close()
+	have := make(map[string]parquet.Field)
+	for _, fld := range pf.Schema().Fields() {
+		have[fld.Name()] = fld
+	}
+	var problems []string
+	for _, want := range metrow.ParquetSchema(cols).Fields() {
+		got, ok := have[want.Name()]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing column %q", want.Name()))
+			continue
+		}
+		delete(have, want.Name())
+		if !compatibleKinds(got.Type().Kind(), want.Type().Kind()) || got.Optional() != want.Optional() {
+			problems = append(problems, fmt.Sprintf("column %q is %s (optional=%t), want %s (optional=%t)",
+				want.Name(), got.Type(), got.Optional(), want.Type(), want.Optional()))
+		}
+	}
+	extra := make([]string, 0, len(have))
+	for name := range have {
+		// Opt-in columns (e.g. qc_flags) may be present without being served.
+		if optInColumn(name) {
+			continue
+		}
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		problems = append(problems, fmt.Sprintf("unexpected column %q", name))
+	}
+	return problems, nil
+}
+
+// optInColumn reports whether name is one of metrow's OptIn columns.
+func optInColumn(name string) bool {
+	c, ok := metrow.LookupColumn(name)
+	return ok && c.OptIn
+}
+
+// compatibleKinds reports whether a file column of kind got reads into one
+// of kind want. Float and double are interchangeable, so files written
+// before or after a PRECISION change are served together.
+func compatibleKinds(got, want parquet.Kind) bool {
+	isFloat := func(k parquet.Kind) bool { return k == parquet.Float || k == parquet.Double }
+	return got == want || isFloat(got) && isFloat(want)
+}
 
-	var all []MetRow
-	buf := make([]MetRow, 1024)
-	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			all = append(all, buf[:n]...)
+// dataDirs splits DATA_DIR on ':' so hot and archive tiers can be served
+// together. Earlier directories take precedence. Entries may be
+// s3://bucket/prefix URLs; the colon after the scheme is not a separator.
+func dataDirs() []string {
+	return splitDirs(metrow.Getenv("DATA_DIR", "/data"))
+}
+
+// splitDirs splits a colon-separated directory list, keeping s3:// URLs
+// whole.
+func splitDirs(v string) []string {
+	var dirs []string
+	parts := strings.Split(v, ":")
+	for i := 0; i < len(parts); i++ {
+		d := strings.TrimSpace(parts[i])
+		if d == "s3" && i+1 < len(parts) && strings.HasPrefix(parts[i+1], "//") {
+			i++
+			d = "s3:" + strings.TrimSpace(parts[i])
 		}
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return all, err
+		if d != "" {
+			dirs = append(dirs, d)
 		}
 	}
-	return all, nil
+	return dirs
 }
 
-func streamHandler(w http.ResponseWriter, _ *http.Request) {
-	dataDir := getenv("DATA_DIR", "/data")
-	mem := memory.NewGoAllocator()
-	schema := buildSchema()
+// selectedColumns returns the COLUMNS allow-list at PRECISION. main
+// validates both at startup, so an error here falls back to the default
+// columns or to float64.
+func selectedColumns() []metrow.Column {
+	cols, err := metrow.SelectColumns(metrow.Getenv("COLUMNS", ""))
+	if err != nil {
+		cols = metrow.DefaultColumns()
+	}
+	cols, _ = metrow.WithPrecision(cols, metrow.Getenv("PRECISION", ""))
+	return cols
+}
+
+// timestampUnit returns the TIMESTAMP_UNIT the time column is served in.
+// main validates it at startup, so an error here falls back to seconds.
+func timestampUnit() arrow.TimeUnit {
+	u, err := metrow.ParseTimeUnit(metrow.Getenv("TIMESTAMP_UNIT", "s"))
+	if err != nil {
+		return arrow.Second
+	}
+	return u
+}
 
-	w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+// streamSchema returns the Arrow schema of the met streams: SchemaFor cols
+// with the time column in timestampUnit. The schema metadata carries the
+// batch_id of the latest go-ingest cycle, from its manifest, when there is
+// one.
+func streamSchema(cols []metrow.Column) *arrow.Schema {
+	s := metrow.SchemaForUnit(cols, timestampUnit())
+	if m, ok := readManifest(); ok && m.BatchID != "" {
+		md := arrow.NewMetadata([]string{metrow.BatchIDKey}, []string{m.BatchID})
+		s = arrow.NewSchema(s.Fields(), &md)
+	}
+	return s
+}
 
-	wr, err := ipc.NewWriter(w, ipc.WithSchema(schema))
+// filenameTemplate returns the FILENAME_TEMPLATE shared with go-ingest.
+// main validates it at startup, so an error here falls back to the default.
+func filenameTemplate() metrow.FilenameTemplate {
+	t, err := metrow.ParseFilenameTemplate(metrow.Getenv("FILENAME_TEMPLATE", ""))
 	if err != nil {
-		http.Error(w, "Arrow writer init: "+err.Error(), http.StatusInternalServerError)
+		t, _ = metrow.ParseFilenameTemplate("")
+	}
+	return t
+}
+
+// stationOf derives the station ID from a parquet file path.
+func stationOf(path string) string {
+	station, _ := filenameTemplate().Station(strings.TrimSuffix(path, gzSuffix))
+	return station
+}
+
+// findParquet lists station files in each directory in order and keeps
+// one file per station, preferring the first directory it appears in.
+// Within a directory the lexically greatest name wins, so dated templates
+// serve the newest file.
+func findParquet(dirs []string) []string {
+	return findFiles(dirs, filenameTemplate())
+}
+
+// findFiles is findParquet for files named by tmpl.
+func findFiles(dirs []string, tmpl metrow.FilenameTemplate) []string {
+	var out []string
+	for _, s := range findShards(dirs, tmpl) {
+		out = append(out, s.Newest())
+	}
+	return out
+}
+
+// checkDataDirs warns about local DATA_DIR entries that do not exist yet,
+// which otherwise only shows up as empty streams. With CREATE_DATA_DIR=true
+// they are created instead.
+func checkDataDirs(dirs []string) {
+	create, _ := strconv.ParseBool(metrow.Getenv("CREATE_DATA_DIR", "false"))
+	for _, d := range missingDirs(dirs) {
+		if !create {
+			log.Printf("WARN  DATA_DIR %s does not exist; serving empty streams and failing /readyz until go-ingest creates it", d)
+			continue
+		}
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			log.Printf("WARN  DATA_DIR %s does not exist and could not be created: %v", d, err)
+			continue
+		}
+		log.Printf("INFO  created missing DATA_DIR %s", d)
+	}
+}
+
+// readyzHandler reports whether there is data to serve: 503 when a local
+// DATA_DIR is missing or no station file exists yet, 200 otherwise.
+func readyzHandler(w http.ResponseWriter, _ *http.Request) {
+	dirs := dataDirs()
+	if missing := missingDirs(dirs); len(missing) > 0 {
+		http.Error(w, "data dir missing: "+strings.Join(missing, ":"), http.StatusServiceUnavailable)
 		return
 	}
-	defer wr.Close()
+	if len(findParquet(dirs)) == 0 {
+		http.Error(w, "no station files yet", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
 
-	matches, _ := filepath.Glob(filepath.Join(dataDir, "*_latest.parquet"))
+// checkDataSchema validates one existing parquet file in dirs against
+// the selected columns at startup so ingest/source drift is reported loudly
+// instead of surfacing as silently zeroed columns.
+func checkDataSchema(dirs []string, cols []metrow.Column) {
+	matches := findParquet(dirs)
 	if len(matches) == 0 {
-		log.Printf("WARN no parquet files in %s", dataDir)
+		log.Printf("INFO  schema check skipped: no parquet files in %s", strings.Join(dirs, ":"))
+		return
+	}
+	problems, err := schemaMismatches(matches[0], cols)
+	if err != nil {
+		log.Printf("ERROR schema check %s: %v", matches[0], err)
+		return
+	}
+	if len(problems) == 0 {
+		log.Printf("INFO  schema check ok: %s", matches[0])
+		return
+	}
+	for _, p := range problems {
+		log.Printf("ERROR schema mismatch in %s: %s", matches[0], p)
+	}
+}
+
+// recordWriter is satisfied by both the IPC stream and file writers.
+type recordWriter interface {
+	Write(rec arrow.Record) error
+}
+
+// rowCountTrailer is the HTTP trailer /stream sends with the number of
+// rows it streamed, so clients can check it without decoding the body.
+const rowCountTrailer = "X-Row-Count"
+
+// countingWriter passes records on to w and counts the rows written.
+type countingWriter struct {
+	w    recordWriter
+	rows int64
+}
+
+func (c *countingWriter) Write(rec arrow.Record) error {
+	if err := c.w.Write(rec); err != nil {
+		return err
+	}
+	c.rows += rec.NumRows()
+	return nil
+}
+
+// listenAddr returns the address go-source listens on: LISTEN_ADDR, else
+// every interface on ARROW_PORT.
+func listenAddr() string {
+	return metrow.Getenv("LISTEN_ADDR", ":"+metrow.Getenv("ARROW_PORT", "8080"))
+}
+
+// batchSize returns BATCH_SIZE, the maximum number of rows per Arrow record.
+func batchSize() int {
+	n, err := strconv.Atoi(metrow.Getenv("BATCH_SIZE", "1024"))
+	if err != nil || n <= 0 {
+		return 1024
+	}
+	return n
+}
+
+// writeRecords reads every station's shards and writes their rows to wr
+// as records of at most BATCH_SIZE rows, releasing each record before
+// building the next.
+func writeRecords(wr recordWriter, mem memory.Allocator, schema *arrow.Schema, cols []metrow.Column, dirs []string) {
+	batch := batchSize()
+	groups := findShards(dirs, filenameTemplate())
+	if len(groups) == 0 {
+		if missing := missingDirs(dirs); len(missing) > 0 {
+			log.Printf("WARN no parquet files: DATA_DIR %s does not exist (go-ingest not started yet?)", strings.Join(missing, ":"))
+			return
+		}
+		log.Printf("WARN no parquet files in %s", strings.Join(dirs, ":"))
 		return
 	}
 
-	for _, p := range matches {
-		rows, err := readParquet(p)
+	for _, g := range groups {
+		rows, err := readShards(g)
 		if err != nil {
-			log.Printf("WARN readParquet %s: %v", p, err)
+			log.Printf("WARN readParquet %s: %v", g.Station, err)
 			continue
 		}
 		if len(rows) == 0 {
 			continue
 		}
-		rec := rowsToRecord(mem, schema, rows)
-		if err := wr.Write(rec); err != nil {
-			log.Printf("ERROR ipc write %s: %v", p, err)
+		for start := 0; start < len(rows); start += batch {
+			end := min(start+batch, len(rows))
+			rec := rowsToRecord(mem, schema, cols, rows[start:end])
+			err := wr.Write(rec)
+			rec.Release()
+			if err != nil {
+				log.Printf("ERROR ipc write %s: %v", g.Station, err)
+				break
+			}
+			stats.records.Add(1)
+		}
+		if len(g.Paths) == 1 {
+			log.Printf("SENT  %s (%d rows)", g.Paths[0], len(rows))
+		} else {
+			log.Printf("SENT  %s (%d rows from %d shards)", g.Station, len(rows), len(g.Paths))
 		}
-		rec.Release()
-		log.Printf("SENT  %s (%d rows)", p, len(rows))
 	}
 }
 
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// serveHeadOrReject handles the non-GET methods for the Arrow endpoints:
+// HEAD gets the headers and no body, anything else gets 405. It reports
+// whether the caller should go on to write the body.
+func serveHeadOrReject(w http.ResponseWriter, r *http.Request, contentType string) bool {
+	switch r.Method {
+	case http.MethodGet:
+		return true
+	case http.MethodHead:
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		return false
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
 	}
-	return def
+}
+
+// streamHandler serves every station as an Arrow IPC stream. With
+// ?wait=30s&since=<mtime> it first blocks until some station file is newer
+// than since or the client goes away; if the wait elapses with nothing new
+// it answers 304. With ?order=time all stations are merged into one
+// time-ordered stream instead of being sent file by file. With ?at=<ts>
+// only each station's latest observation at or before ts is sent.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	const contentType = "application/vnd.apache.arrow.stream"
+	if !serveHeadOrReject(w, r, contentType) {
+		return
+	}
+	wait, since, err := parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var at time.Time
+	if v := r.URL.Query().Get("at"); v != "" {
+		if at, err = parseTimeParam("at", v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	q := r.URL.Query()
+	group := q.Get("group")
+	switch {
+	case group != "" && group != "station":
+		http.Error(w, "group must be station", http.StatusBadRequest)
+		return
+	case group != "" && (!at.IsZero() || q.Get("order") == "time"):
+		http.Error(w, "group=station cannot be combined with at or order=time", http.StatusBadRequest)
+		return
+	}
+	dirs := dataDirs()
+	latest := waitForChange(r.Context(), dirs, since, wait)
+	if r.Context().Err() != nil {
+		return
+	}
+	if wait > 0 && latest.Unix() <= since.Unix() {
+		w.Header().Set(dataModHeader, strconv.FormatInt(since.Unix(), 10))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	mem, release := newAllocator("/stream")
+	defer release()
+	cols := selectedColumns()
+	schema := streamSchema(cols)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", rowCountTrailer)
+	if !latest.IsZero() {
+		w.Header().Set(dataModHeader, strconv.FormatInt(latest.Unix(), 10))
+	}
+
+	ipcw := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	wr := &countingWriter{w: ipcw}
+	defer func() {
+		// The trailer goes out after the body, once the stream is closed.
+		if err := ipcw.Close(); err != nil {
+			log.Printf("ERROR ipc close /stream: %v", err)
+		}
+		w.Header().Set(rowCountTrailer, strconv.FormatInt(wr.rows, 10))
+	}()
+
+	switch {
+	case !at.IsZero():
+		writeAsOf(wr, mem, schema, cols, dirs, at)
+	case q.Get("order") == "time":
+		writeMerged(wr, mem, schema, cols, dirs)
+	case group == "station":
+		writeGrouped(wr, mem, schema, cols, dirs)
+	default:
+		writeRecords(wr, mem, schema, cols, dirs)
+	}
+}
+
+// featherHandler serves the same data as /stream as a seekable Arrow IPC
+// file (Feather v2), footer included. The file writer must seek back, so
+// the file is built in a temp file and then copied to w.
+func featherHandler(w http.ResponseWriter, r *http.Request) {
+	const contentType = "application/vnd.apache.arrow.file"
+	if !serveHeadOrReject(w, r, contentType) {
+		return
+	}
+	mem, release := newAllocator("/feather")
+	defer release()
+	cols := selectedColumns()
+	schema := streamSchema(cols)
+
+	tmp, err := os.CreateTemp("", "feather-*.arrow")
+	if err != nil {
+		http.Error(w, "Arrow file buffer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	fw, err := ipc.NewFileWriter(tmp, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err != nil {
+		http.Error(w, "Arrow file writer init: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeRecords(fw, mem, schema, cols, dataDirs())
+
+	// Close writes the footer; without it the file is unreadable.
+	if err := fw.Close(); err != nil {
+		log.Printf("ERROR arrow file close: %v", err)
+		http.Error(w, "Arrow file close failed", http.StatusInternalServerError)
+		return
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(w, "Arrow file buffer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if _, err := io.Copy(w, tmp); err != nil {
+		log.Printf("ERROR write /feather: %v", err)
+	}
+}
+
+// readUnits returns the NDBC units recorded by go-ingest in the parquet
+// file's key/value metadata, or nil when the file predates units capture.
+func readUnits(path string) (map[string]string, error) {
+	pf, f, err := openParquetFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	v, ok := pf.Lookup(metrow.UnitsKey)
+	if !ok {
+		return nil, nil
+	}
+	var units map[string]string
+	if err := json.Unmarshal([]byte(v), &units); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", metrow.UnitsKey, err)
+	}
+	return units, nil
+}
+
+type schemaField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Nullable    bool   `json:"nullable"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type schemaResponse struct {
+	Fields []schemaField `json:"fields"`
+	// Units maps station → NDBC column → unit, as captured at ingest.
+	Units map[string]map[string]string `json:"units"`
+}
+
+// schemaHandler describes the Arrow schema served by /stream together with
+// the units line go-ingest recorded for each station file.
+func schemaHandler(w http.ResponseWriter, _ *http.Request) {
+	resp := schemaResponse{Units: make(map[string]map[string]string)}
+	for _, f := range streamSchema(selectedColumns()).Fields() {
+		sf := schemaField{Name: f.Name, Type: f.Type.String(), Nullable: f.Nullable}
+		if i := f.Metadata.FindKey("unit"); i >= 0 {
+			sf.Unit = f.Metadata.Values()[i]
+		}
+		if i := f.Metadata.FindKey("description"); i >= 0 {
+			sf.Description = f.Metadata.Values()[i]
+		}
+		resp.Fields = append(resp.Fields, sf)
+	}
+	for _, p := range findParquet(dataDirs()) {
+		units, err := readUnits(p)
+		if err != nil {
+			log.Printf("WARN readUnits %s: %v", p, err)
+			continue
+		}
+		if units != nil {
+			resp.Units[stationOf(p)] = units
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("ERROR encode /schema: %v", err)
+	}
+}
+
+// settingKeys are the settings go-source reads; CONFIG_FILE keys and
+// command-line flags must be one of these.
+var settingKeys = []string{
+	"DATA_DIR", "ARROW_PORT", "METRICS_PORT", "COLUMNS", "FILENAME_TEMPLATE",
+	"BATCH_SIZE", "AUTH_TOKEN", "MODE", "FILE", "REPLAY_DIR",
+	"DART_FILENAME_TEMPLATE", "CREATE_DATA_DIR", "DEBUG",
+	"S3_ENDPOINT", "S3_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY",
+	"VERIFY_CHECKSUM", "STREAM_URL", "LOG_LEVEL", "PRECISION",
+	"MAX_CONCURRENT_STREAMS", "ALLOCATOR", "TIMESTAMP_UNIT", "LISTEN_ADDR",
 }
 
 func main() {
-	port := getenv("ARROW_PORT", "8080")
-	dataDir := getenv("DATA_DIR", "/data")
-	log.Printf("Arrow source on :%s (GET /stream) | dataDir=%s", port, dataDir)
+	if err := metrow.LoadSettings("go-source", settingKeys, os.Args[1:]); err != nil {
+		log.Fatalf("ERROR config: %v", err)
+	}
+	if err := metrow.SetupLogging(); err != nil {
+		log.Printf("WARN  invalid LOG_LEVEL: %v (using info)", err)
+	}
+	if metrow.Getenv("MODE", "serve") == "validate" {
+		cols, err := metrow.SelectColumns(metrow.Getenv("COLUMNS", ""))
+		if err != nil {
+			log.Fatalf("ERROR invalid COLUMNS: %v", err)
+		}
+		os.Exit(runValidate(metrow.Getenv("FILE", ""), cols))
+	}
+	if metrow.Getenv("MODE", "serve") == "verify" {
+		os.Exit(runVerify(verifyURL()))
+	}
 
-	http.HandleFunc("/stream", streamHandler)
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+	addr := listenAddr()
+	dirs := dataDirs()
+	log.Printf("Arrow source on %s (GET /stream, /feather) | dataDir=%s", addr, strings.Join(dirs, ":"))
+	cols, err := metrow.SelectColumns(metrow.Getenv("COLUMNS", ""))
+	if err != nil {
+		log.Fatalf("ERROR invalid COLUMNS: %v", err)
+	}
+	if cols, err = metrow.WithPrecision(cols, metrow.Getenv("PRECISION", "")); err != nil {
+		log.Printf("WARN  invalid PRECISION: %v (using float64)", err)
+	}
+	if _, err := metrow.ParseFilenameTemplate(metrow.Getenv("FILENAME_TEMPLATE", "")); err != nil {
+		log.Fatalf("ERROR invalid FILENAME_TEMPLATE: %v", err)
+	}
+	if _, err := metrow.ParseFilenameTemplate(metrow.Getenv("DART_FILENAME_TEMPLATE", metrow.DefaultDartFilenameTemplate)); err != nil {
+		log.Fatalf("ERROR invalid DART_FILENAME_TEMPLATE: %v", err)
+	}
+	checkDataDirs(dirs)
+	checkDataSchema(dirs, cols)
+
+	if v := metrow.Getenv("MAX_CONCURRENT_STREAMS", ""); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			log.Printf("WARN  invalid MAX_CONCURRENT_STREAMS %q (no limit)", v)
+		} else {
+			streamSlots = make(chan struct{}, n)
+		}
+	}
+
+	if v := metrow.Getenv("TIMESTAMP_UNIT", ""); v != "" {
+		if _, err := metrow.ParseTimeUnit(v); err != nil {
+			log.Printf("WARN  invalid TIMESTAMP_UNIT: %v (using s)", err)
+		}
+	}
+	if v := metrow.Getenv("ALLOCATOR", ""); v != "" {
+		if err := setAllocator(v); err != nil {
+			log.Printf("WARN  invalid ALLOCATOR %q (%v; using %s)", v, err, allocatorGo)
+		}
+	}
+
+	cfg := serverConfig{
+		Addr:        addr,
+		Token:       metrow.Getenv("AUTH_TOKEN", ""),
+		MetricsPort: metrow.Getenv("METRICS_PORT", ""),
+	}
+	cfg.Debug, _ = strconv.ParseBool(metrow.Getenv("DEBUG", "false"))
+	if cfg.Debug {
+		log.Printf("INFO  DEBUG=true: serving /debug/rows")
+	}
+	if cfg.Token != "" {
+		log.Printf("INFO  bearer-token auth enabled (all endpoints except /healthz, /readyz)")
+	}
+
+	// /metrics goes on its own listener when METRICS_PORT is set, so it can
+	// be scraped without the bearer token; otherwise it shares the server.
+	if cfg.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", metricsHandler)
+		go func() {
+			log.Printf("INFO  metrics on :%s/metrics", cfg.MetricsPort)
+			log.Fatalf("ERROR metrics server: %v", http.ListenAndServe(":"+cfg.MetricsPort, mux))
+		}()
+	}
+
+	log.Fatalf("ERROR server: %v", newServer(cfg).ListenAndServe())
+}
+
+// serverConfig holds what newServer needs from the environment.
+type serverConfig struct {
+	// Addr is the host:port to listen on (LISTEN_ADDR, else :ARROW_PORT).
+	Addr string
+	// Token is the bearer token required by AUTH_TOKEN; empty disables auth.
+	Token string
+	// MetricsPort, when set, means /metrics is served elsewhere.
+	MetricsPort string
+	// Debug serves /debug/rows.
+	Debug bool
+}
+
+// newServer returns the go-source HTTP server for cfg with every endpoint
+// registered on its own mux, ready for ListenAndServe or Serve.
+func newServer(cfg serverConfig) *http.Server {
+	mux := http.NewServeMux()
+	handle := func(pattern string, h http.HandlerFunc) {
+		knownEndpoints[pattern] = true
+		mux.HandleFunc(pattern, h)
+	}
+	handle("/stream", limitStreams(streamHandler))
+	handle("/feather", limitStreams(featherHandler))
+	handle("/parquet", parquetHandler)
+	handle("/schema", schemaHandler)
+	handle("/summary", summaryHandler)
+	handle("/json", limitStreams(jsonHandler))
+	handle("/qc", qcHandler)
+	handle("/gaps", gapsHandler)
+	handle("/diff", diffHandler)
+	handle("/pivot", limitStreams(pivotHandler))
+	handle("/replay", limitStreams(replayHandler))
+	handle("/delta", limitStreams(deltaHandler))
+	handle("/dart", dartHandler)
+	handle("/manifest", manifestHandler)
+	handle("/status", statusHandler)
+	handle("/validate-all", validateAllHandler)
+	handle("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		fmt.Fprintln(w, "ok")
 	})
+	handle("/readyz", readyzHandler)
+	if cfg.Debug {
+		handle("/debug/rows", debugRowsHandler)
+	}
+	if cfg.MetricsPort == "" {
+		handle("/metrics", metricsHandler)
+	}
 
-	s := &http.Server{
-		Addr:              ":" + port,
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           instrument(requireToken(cfg.Token, gzipResponses(mux))),
 		ReadHeaderTimeout: 10 * time.Second,
 		WriteTimeout:      60 * time.Second,
 	}
-	log.Fatal(s.ListenAndServe())
 }
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// writeParquetFile writes rows, of any parquet-tagged struct type, to path.
+func writeParquetFile[T any](t *testing.T, path string, rows []T) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := parquet.NewGenericWriter[T](f)
+	if _, err := w.Write(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// noPresRow is MetRow as an older go-ingest might have written it, without
+// pres_hpa.
+type noPresRow struct {
+	StationID string   `parquet:"station_id"`
+	Time      int64    `parquet:"time"`
+	WDIRDeg   *int32   `parquet:"wdir_deg"`
+	WSPDmS    *float64 `parquet:"wspd_ms"`
+	GUSTmS    *float64 `parquet:"gust_ms"`
+	ATMPC     *float64 `parquet:"atmp_c"`
+	WTMPC     *float64 `parquet:"wtmp_c"`
+	DEWPC     *float64 `parquet:"dewp_c"`
+}
+
+func TestSchemaMismatchMissingColumn(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	wspd := 5.5
+	path := filepath.Join(dir, "SANF1_latest.parquet")
+	writeParquetFile(t, path, []noPresRow{{StationID: "SANF1", Time: 1718000000, WSPDmS: &wspd}})
+
+	problems, err := schemaMismatches(path, metrow.DefaultColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`missing column "pres_hpa"`}; !slices.Equal(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+
+	// The file is still served; the missing column reads as null.
+	w := httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	rd, err := ipc.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Release()
+	if !rd.Next() {
+		t.Fatalf("no record streamed: %v", rd.Err())
+	}
+	rec := rd.Record()
+	if rec.NumRows() != 1 {
+		t.Fatalf("%d rows, want 1", rec.NumRows())
+	}
+	pres := rec.Column(rec.Schema().FieldIndices("pres_hpa")[0])
+	if !pres.IsNull(0) {
+		t.Errorf("pres_hpa = %v, want null", pres)
+	}
+}
+
+func TestSchemaMismatchIgnoresOptInColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "SANF1_latest.parquet")
+	writeParquetFile(t, path, []metrow.MetRow{{StationID: "SANF1", Time: 1718000000, QCFlags: 8}})
+	problems, err := schemaMismatches(path, metrow.DefaultColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("problems = %q for a file with unserved opt-in columns", problems)
+	}
+}
+
+func TestStreamFloat32(t *testing.T) {
+	dir := useDataDir(t)
+	f32, _ := metrow.WithPrecision(metrow.DefaultColumns(), "float32")
+	f, err := os.Create(filepath.Join(dir, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := metrow.ParquetSchema(f32)
+	w := parquet.NewWriter(f, schema)
+	if _, err := w.WriteRows(metrow.ParquetRows(schema, f32, hourlyRows("SANF1", 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// A float32 file matches float64 columns: PRECISION may change between
+	// files.
+	problems, err := schemaMismatches(f.Name(), metrow.DefaultColumns())
+	if err != nil || len(problems) != 0 {
+		t.Errorf("problems = %q, err %v", problems, err)
+	}
+	for _, precision := range []string{"", "float32"} {
+		t.Setenv("PRECISION", precision)
+		rec := httptest.NewRecorder()
+		streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+		rd, err := ipc.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rd.Next() {
+			t.Fatalf("PRECISION=%q: no record: %v", precision, rd.Err())
+		}
+		wspd := rd.Record().Column(rd.Record().Schema().FieldIndices("wspd_ms")[0])
+		var last float64
+		switch a := wspd.(type) {
+		case *array.Float32:
+			last = float64(a.Value(a.Len() - 1))
+		case *array.Float64:
+			last = a.Value(a.Len() - 1)
+		}
+		if isF32 := wspd.DataType().ID() == arrow.FLOAT32; isF32 != (precision == "float32") || last != 2 {
+			t.Errorf("PRECISION=%q: wspd_ms is %s ending %v, want 2", precision, wspd.DataType(), last)
+		}
+		rd.Release()
+	}
+}
+
+// f64 returns a pointer to v, for MetRow's nullable fields.
+func f64(v float64) *float64 { return &v }
+
+// useDataDir points DATA_DIR at a fresh temp dir for the test.
+func useDataDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	return dir
+}
+
+// writeStation stores rows as station's file in dir.
+func writeStation(t *testing.T, dir, station string, rows []metrow.MetRow) {
+	t.Helper()
+	writeParquetFile(t, filepath.Join(dir, station+"_latest.parquet"), rows)
+}
+
+// hourlyRows returns n hourly rows of station with WSPD counting up from 0.
+func hourlyRows(station string, n int) []metrow.MetRow {
+	rows := make([]metrow.MetRow, n)
+	for i := range rows {
+		v := float64(i)
+		rows[i] = metrow.MetRow{StationID: station, Time: 1718000000 + int64(i)*3600, WSPDmS: &v}
+	}
+	return rows
+}
+
+// streamStations reads an Arrow IPC stream and counts its rows by station.
+func streamStations(t *testing.T, body io.Reader) map[string]int {
+	t.Helper()
+	rd, err := ipc.NewReader(body)
+	if err != nil {
+		t.Fatalf("ipc.NewReader: %v", err)
+	}
+	defer rd.Release()
+	n := map[string]int{}
+	for rd.Next() {
+		ids := rd.Record().Column(0).(*array.String)
+		for i := 0; i < ids.Len(); i++ {
+			n[ids.Value(i)]++
+		}
+	}
+	if err := rd.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// decodeStream reads an Arrow IPC stream back into rows, also returning
+// the size of each record.
+func decodeStream(t *testing.T, body io.Reader) ([]metrow.MetRow, []int64) {
+	t.Helper()
+	rd, err := ipc.NewReader(body)
+	if err != nil {
+		t.Fatalf("ipc.NewReader: %v", err)
+	}
+	defer rd.Release()
+	var rows []metrow.MetRow
+	var sizes []int64
+	for rd.Next() {
+		rec := rd.Record()
+		sizes = append(sizes, rec.NumRows())
+		rs, err := metrow.RecordToRows(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, rs...)
+	}
+	if err := rd.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return rows, sizes
+}
+
+func TestStreamPrefersFirstDataDir(t *testing.T) {
+	hot, archive := t.TempDir(), t.TempDir()
+	t.Setenv("DATA_DIR", hot+":"+archive)
+	writeStation(t, hot, "SANF1", hourlyRows("SANF1", 1))
+	writeStation(t, archive, "SANF1", hourlyRows("SANF1", 3))
+	writeStation(t, archive, "SMKF1", hourlyRows("SMKF1", 2))
+
+	w := httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	n := streamStations(t, w.Body)
+	if len(n) != 2 || n["SANF1"] != 1 || n["SMKF1"] != 2 {
+		t.Errorf("rows by station = %v, want SANF1:1 (hot dir) SMKF1:2", n)
+	}
+}
+
+func TestFeatherRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 2))
+
+	w := httptest.NewRecorder()
+	featherHandler(w, httptest.NewRequest(http.MethodGet, "/feather", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("Content-Length %s, body %d bytes", got, w.Body.Len())
+	}
+
+	fr, err := ipc.NewFileReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("NewFileReader: %v", err)
+	}
+	defer fr.Close()
+	if fr.NumRecords() != 1 {
+		t.Fatalf("NumRecords = %d, want 1", fr.NumRecords())
+	}
+	rec, err := fr.Record(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wspd := rec.Column(rec.Schema().FieldIndices("wspd_ms")[0]).(*array.Float64)
+	if rec.NumRows() != 2 || wspd.Value(1) != 1 {
+		t.Errorf("record = %v", rec)
+	}
+}
+
+func TestSchemaReportsUnits(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	f, err := os.Create(filepath.Join(dir, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw := parquet.NewGenericWriter[metrow.MetRow](f, parquet.KeyValueMetadata(metrow.UnitsKey, `{"WSPD":"m/s"}`))
+	if _, err := pw.Write(hourlyRows("SANF1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+	f.Close()
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 1)) // no units recorded
+
+	w := httptest.NewRecorder()
+	schemaHandler(w, httptest.NewRequest(http.MethodGet, "/schema", nil))
+	var resp schemaResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Units) != 1 || resp.Units["SANF1"]["WSPD"] != "m/s" {
+		t.Errorf("units = %v", resp.Units)
+	}
+	if len(resp.Fields) != len(metrow.SchemaFor(metrow.DefaultColumns()).Fields()) || resp.Fields[0].Name != "station_id" {
+		t.Errorf("fields = %+v", resp.Fields)
+	}
+	for _, f := range resp.Fields {
+		if f.Name == "pres_hpa" && (f.Unit != "hPa" || f.Description != "sea level pressure") {
+			t.Errorf("pres_hpa field = %+v", f)
+		}
+	}
+}
+
+func TestStreamRowCountTrailer(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 3))
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 4))
+	srv := httptest.NewServer(http.HandlerFunc(streamHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	n := 0
+	for _, c := range streamStations(t, resp.Body) {
+		n += c
+	}
+	// The trailer is only available once the body has been read to EOF.
+	io.Copy(io.Discard, resp.Body)
+	if got := resp.Trailer.Get(rowCountTrailer); got != strconv.Itoa(n) || n != 7 {
+		t.Errorf("%s trailer %q, decoded %d rows", rowCountTrailer, got, n)
+	}
+}
+
+func TestStreamBatchSize(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	t.Setenv("BATCH_SIZE", "1000")
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 2500))
+
+	w := httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	rd, err := ipc.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Release()
+	var sizes []int64
+	var last float64
+	for rd.Next() {
+		rec := rd.Record()
+		sizes = append(sizes, rec.NumRows())
+		wspd := rec.Column(rec.Schema().FieldIndices("wspd_ms")[0]).(*array.Float64)
+		last = wspd.Value(wspd.Len() - 1)
+	}
+	if !slices.Equal(sizes, []int64{1000, 1000, 500}) {
+		t.Errorf("record sizes %v, want [1000 1000 500]", sizes)
+	}
+	if last != 2499 {
+		t.Errorf("last wspd_ms %v, want 2499", last)
+	}
+}
+
+func TestStreamMethods(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 2))
+
+	w := httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodHead, "/stream", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Type") != "application/vnd.apache.arrow.stream" {
+		t.Errorf("HEAD: status %d, %d body bytes, Content-Type %q", w.Code, w.Body.Len(), w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodPost, "/stream", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestStreamLongPoll(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 1))
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(dir, "SANF1_latest.parquet"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	since := strconv.FormatInt(old.Unix(), 10)
+
+	// Nothing newer within the wait: 304.
+	w := httptest.NewRecorder()
+	streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream?wait=10ms&since="+since, nil))
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("idle wait: status %d, want 304", w.Code)
+	}
+
+	// A file written mid-wait ends the wait and is streamed.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		streamHandler(w, httptest.NewRequest(http.MethodGet, "/stream?wait=10s&since="+since, nil))
+		done <- w
+	}()
+	time.Sleep(50 * time.Millisecond)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 2))
+	select {
+	case w = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("long poll did not return after new data was written")
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if n := streamStations(t, w.Body)["SANF1"]; n != 2 {
+		t.Errorf("%d rows, want the 2 new ones", n)
+	}
+	if got, _ := strconv.ParseInt(w.Header().Get(dataModHeader), 10, 64); got <= old.Unix() {
+		t.Errorf("%s = %d, want newer than %s", dataModHeader, got, since)
+	}
+}
+
+func TestFindParquetDatedTemplate(t *testing.T) {
+	t.Setenv("FILENAME_TEMPLATE", "{station}_{date}.parquet")
+	dir := t.TempDir()
+	for _, name := range []string{"SANF1_20240609.parquet", "SANF1_20240611.parquet", "SMKF1_20240610.parquet", "notes.parquet"} {
+		writeParquetFile(t, filepath.Join(dir, name), hourlyRows("X", 1))
+	}
+	got := findParquet([]string{dir})
+	want := []string{filepath.Join(dir, "SANF1_20240611.parquet"), filepath.Join(dir, "SMKF1_20240610.parquet")}
+	if !slices.Equal(got, want) {
+		t.Errorf("findParquet = %q, want %q", got, want)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	t.Setenv("DATA_DIR", dir)
+	t.Setenv("CREATE_DATA_DIR", "false")
+	ready := func() int {
+		w := httptest.NewRecorder()
+		readyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+
+	if got := ready(); got != http.StatusServiceUnavailable {
+		t.Errorf("missing DATA_DIR: status %d, want 503", got)
+	}
+	checkDataDirs([]string{dir})
+	if _, err := os.Stat(dir); err == nil {
+		t.Fatal("checkDataDirs created DATA_DIR without CREATE_DATA_DIR")
+	}
+
+	t.Setenv("CREATE_DATA_DIR", "true")
+	checkDataDirs([]string{dir})
+	if got := ready(); got != http.StatusServiceUnavailable {
+		t.Errorf("empty DATA_DIR: status %d, want 503", got)
+	}
+
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 1))
+	if got := ready(); got != http.StatusOK {
+		t.Errorf("with a station file: status %d, want 200", got)
+	}
+}
+
+// TestSettingKeysCoverGetenv keeps settingKeys in step with the settings
+// the service actually reads, so CONFIG_FILE and flags accept all of them.
+func TestSettingKeysCoverGetenv(t *testing.T) {
+	files, _ := filepath.Glob("*.go")
+	files = append(files, "../internal/objstore/s3.go")
+	getenv := regexp.MustCompile(`Getenv\("([A-Z0-9_]+)"`)
+	for _, f := range files {
+		if strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range getenv.FindAllSubmatch(b, -1) {
+			if key := string(m[1]); !slices.Contains(settingKeys, key) {
+				t.Errorf("%s reads %s, which is missing from settingKeys", f, key)
+			}
+		}
+	}
+}
+
+func TestStreamTimestampUnit(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 2))
+	for unit, scale := range map[string]int64{"": 1, "s": 1, "ms": 1e3, "us": 1e6, "ns": 1e9} {
+		t.Setenv("TIMESTAMP_UNIT", unit)
+		rec := httptest.NewRecorder()
+		streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+		rd, err := ipc.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rd.Next() {
+			t.Fatalf("TIMESTAMP_UNIT=%q: no record: %v", unit, rd.Err())
+		}
+		ts := rd.Record().Column(1).(*array.Timestamp)
+		if got, want := int64(ts.Value(1)), int64(1718003600)*scale; got != want {
+			t.Errorf("TIMESTAMP_UNIT=%q: time = %d, want %d", unit, got, want)
+		}
+		rows, err := metrow.RecordToRows(rd.Record())
+		if err != nil || rows[1].Time != 1718003600 {
+			t.Errorf("TIMESTAMP_UNIT=%q: read back %+v, err %v", unit, rows, err)
+		}
+		rd.Release()
+	}
+}
+
+func TestStreamSchemaBatchID(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 1))
+	batch := func() string {
+		rec := httptest.NewRecorder()
+		streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+		rd, err := ipc.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rd.Release()
+		id, _ := rd.Schema().Metadata().GetValue(metrow.BatchIDKey)
+		return id
+	}
+	if id := batch(); id != "" {
+		t.Errorf("batch_id %q without a manifest", id)
+	}
+	const id = "5c0e8a52-53b4-4c59-9b5e-1f1d2f6d6f01"
+	b, _ := json.Marshal(metrow.Manifest{Cycle: time.Unix(1718000000, 0).UTC(), BatchID: id, Files: []metrow.ManifestEntry{}})
+	if err := os.WriteFile(filepath.Join(dir, metrow.ManifestName), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := batch(); got != id {
+		t.Errorf("batch_id = %q, want %q", got, id)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"container/heap"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// mergeBufRows is how many rows each station cursor holds at a time.
+const mergeBufRows = 1024
+
+// rowCursor walks one station file in time order.
+type rowCursor struct {
+	path string
+	src  parquetSource
+	r    *metrow.Reader // nil once buf holds everything
+	buf  []metrow.MetRow
+	i    int
+	err  error
+}
+
+// openCursor opens path for merging. Files go-ingest marked as sorted by
+// time are read incrementally, mergeBufRows at a time; older files are read
+// fully and sorted in memory.
+func openCursor(path string) (*rowCursor, error) {
+	if verifyChecksums() {
+		if err := verifyChecksum(path); err != nil {
+			return nil, err
+		}
+	}
+	src, size, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parquet.OpenFile(src, size)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	c := &rowCursor{path: path, src: src}
+	if v, ok := pf.Lookup(metrow.SortedKey); ok && v == "time" {
+		c.r = metrow.NewReader(pf)
+		c.buf = make([]metrow.MetRow, mergeBufRows)
+		c.fill()
+		return c, nil
+	}
+	rows, err := readParquet(path)
+	src.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Time < rows[j].Time })
+	c.src, c.buf = nil, rows
+	return c, nil
+}
+
+// openShards opens a cursor over a station's shards. A single shard is
+// streamed by openCursor; several are combined and de-duplicated in memory
+// by readShards.
+func openShards(g stationShards) (*rowCursor, error) {
+	if len(g.Paths) == 1 {
+		return openCursor(g.Paths[0])
+	}
+	rows, err := readShards(g)
+	if err != nil {
+		return nil, err
+	}
+	return &rowCursor{path: g.Station, buf: rows}, nil
+}
+
+// fill refills buf from the reader.
+func (c *rowCursor) fill() {
+	c.buf = c.buf[:cap(c.buf)]
+	n, err := c.r.Read(c.buf)
+	c.buf, c.i = c.buf[:n], 0
+	if err == io.EOF {
+		err = nil
+		if n == 0 {
+			c.close()
+		}
+	}
+	if err != nil {
+		c.err = err
+		c.close()
+	}
+}
+
+// peek returns the cursor's current row, or nil when it is exhausted.
+func (c *rowCursor) peek() *metrow.MetRow {
+	if c.i < len(c.buf) {
+		return &c.buf[c.i]
+	}
+	return nil
+}
+
+// advance moves past the current row, refilling from the file as needed.
+func (c *rowCursor) advance() {
+	c.i++
+	if c.i >= len(c.buf) && c.r != nil {
+		c.fill()
+	}
+}
+
+func (c *rowCursor) close() {
+	if c.r != nil {
+		c.r.Close()
+		c.r = nil
+	}
+	if c.src != nil {
+		c.src.Close()
+		c.src = nil
+	}
+}
+
+// cursorHeap orders cursors by the time (then station) of their current row.
+type cursorHeap []*rowCursor
+
+func (h cursorHeap) Len() int { return len(h) }
+func (h cursorHeap) Less(i, j int) bool {
+	a, b := h[i].peek(), h[j].peek()
+	if a.Time != b.Time {
+		return a.Time < b.Time
+	}
+	return a.StationID < b.StationID
+}
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *cursorHeap) Push(x any)   { *h = append(*h, x.(*rowCursor)) }
+func (h *cursorHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// writeMerged k-way merges every station file into one time-ordered
+// sequence and writes it to wr as records of at most BATCH_SIZE rows. Only
+// one buffer per station and one batch are held in memory at a time.
+func writeMerged(wr recordWriter, mem memory.Allocator, schema *arrow.Schema, cols []metrow.Column, dirs []string) {
+	batch := batchSize()
+	var h cursorHeap
+	for _, g := range findShards(dirs, filenameTemplate()) {
+		c, err := openShards(g)
+		if err != nil {
+			stats.readErrors.Add(1)
+			log.Printf("WARN open %s: %v", g.Station, err)
+			continue
+		}
+		if c.peek() != nil {
+			h = append(h, c)
+		} else {
+			c.close()
+		}
+	}
+	defer func() {
+		for _, c := range h {
+			c.close()
+		}
+	}()
+	heap.Init(&h)
+
+	out := make([]metrow.MetRow, 0, batch)
+	flush := func() bool {
+		if len(out) == 0 {
+			return true
+		}
+		rec := rowsToRecord(mem, schema, cols, out)
+		err := wr.Write(rec)
+		rec.Release()
+		out = out[:0]
+		if err != nil {
+			log.Printf("ERROR ipc write (merged): %v", err)
+			return false
+		}
+		stats.records.Add(1)
+		return true
+	}
+	total := 0
+	for h.Len() > 0 {
+		c := h[0]
+		out = append(out, *c.peek())
+		total++
+		c.advance()
+		if c.peek() == nil {
+			if c.err != nil {
+				stats.readErrors.Add(1)
+				log.Printf("WARN read %s: %v", c.path, c.err)
+			}
+			heap.Pop(&h)
+			c.close()
+		} else {
+			heap.Fix(&h, 0)
+		}
+		if len(out) == batch && !flush() {
+			return
+		}
+	}
+	if flush() {
+		log.Printf("SENT  merged (%d rows)", total)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestStreamOrderTime(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+	t.Setenv("BATCH_SIZE", "700")
+
+	// SANF1 is marked sorted and spans several cursor refills; 41001 is an
+	// unmarked newest-first file interleaved with it.
+	sorted := make([]metrow.MetRow, 2*mergeBufRows+10)
+	for i := range sorted {
+		sorted[i] = metrow.MetRow{StationID: "SANF1", Time: 1718000000 + int64(i)*7200}
+	}
+	f, err := os.Create(filepath.Join(dir, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := parquet.NewGenericWriter[metrow.MetRow](f, parquet.KeyValueMetadata(metrow.SortedKey, "time"))
+	if _, err := w.Write(sorted); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	unsorted := hourlyRows("41001", 300)
+	slices.Reverse(unsorted)
+	writeStation(t, dir, "41001", unsorted)
+
+	rec := httptest.NewRecorder()
+	streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?order=time", nil))
+	rd, err := ipc.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Release()
+	var times []int64
+	n := map[string]int{}
+	for rd.Next() {
+		r := rd.Record()
+		ids := r.Column(0).(*array.String)
+		ts := r.Column(1).(*array.Timestamp)
+		for i := 0; i < ids.Len(); i++ {
+			n[ids.Value(i)]++
+			times = append(times, int64(ts.Value(i)))
+		}
+	}
+	if err := rd.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n["SANF1"] != len(sorted) || n["41001"] != 300 {
+		t.Errorf("rows per station %v", n)
+	}
+	if !slices.IsSorted(times) {
+		t.Error("merged stream is not in time order")
+	}
+}
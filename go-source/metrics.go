@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds (seconds) of the request duration
+// histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+type latencyHist struct {
+	counts []uint64 // per bucket, non-cumulative; last is +Inf
+	sum    float64
+	n      uint64
+}
+
+// metrics collects the counters served on /metrics in the Prometheus text
+// exposition format. It is hand-rolled to avoid a client library.
+type metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	latency  map[string]*latencyHist
+	bytes    map[string]uint64
+
+	records    atomic.Uint64
+	readErrors atomic.Uint64
+}
+
+var stats = &metrics{
+	requests: make(map[requestKey]uint64),
+	latency:  make(map[string]*latencyHist),
+	bytes:    make(map[string]uint64),
+}
+
+func (m *metrics) observe(endpoint string, code int, d time.Duration, n uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{endpoint, code}]++
+	m.bytes[endpoint] += n
+	h := m.latency[endpoint]
+	if h == nil {
+		h = &latencyHist{counts: make([]uint64, len(latencyBuckets)+1)}
+		m.latency[endpoint] = h
+	}
+	s := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, s)
+	h.counts[i]++
+	h.sum += s
+	h.n++
+}
+
+// knownEndpoints keeps label cardinality bounded: any other path is
+// recorded as "other".
+var knownEndpoints = map[string]bool{}
+
+func endpointLabel(path string) string {
+	if knownEndpoints[path] {
+		return path
+	}
+	return "other"
+}
+
+// statusRecorder captures the status code and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes uint64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += uint64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// instrument records request count, latency, and bytes written per
+// endpoint and status code.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		stats.observe(endpointLabel(r.URL.Path), rec.code, time.Since(start), rec.bytes)
+	})
+}
+
+func fmtFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// metricsHandler writes every metric in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, _ *http.Request) {
+	m := stats
+	var b strings.Builder
+
+	m.mu.Lock()
+	reqKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Slice(reqKeys, func(i, j int) bool {
+		if reqKeys[i].endpoint != reqKeys[j].endpoint {
+			return reqKeys[i].endpoint < reqKeys[j].endpoint
+		}
+		return reqKeys[i].code < reqKeys[j].code
+	})
+	b.WriteString("# HELP go_source_http_requests_total HTTP requests by endpoint and status code.\n")
+	b.WriteString("# TYPE go_source_http_requests_total counter\n")
+	for _, k := range reqKeys {
+		fmt.Fprintf(&b, "go_source_http_requests_total{endpoint=%q,code=\"%d\"} %d\n", k.endpoint, k.code, m.requests[k])
+	}
+
+	endpoints := make([]string, 0, len(m.latency))
+	for e := range m.latency {
+		endpoints = append(endpoints, e)
+	}
+	sort.Strings(endpoints)
+	b.WriteString("# HELP go_source_http_request_duration_seconds HTTP request latency by endpoint.\n")
+	b.WriteString("# TYPE go_source_http_request_duration_seconds histogram\n")
+	for _, e := range endpoints {
+		h := m.latency[e]
+		var cum uint64
+		for i, le := range latencyBuckets {
+			cum += h.counts[i]
+			fmt.Fprintf(&b, "go_source_http_request_duration_seconds_bucket{endpoint=%q,le=%q} %d\n", e, fmtFloat(le), cum)
+		}
+		fmt.Fprintf(&b, "go_source_http_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", e, h.n)
+		fmt.Fprintf(&b, "go_source_http_request_duration_seconds_sum{endpoint=%q} %s\n", e, fmtFloat(h.sum))
+		fmt.Fprintf(&b, "go_source_http_request_duration_seconds_count{endpoint=%q} %d\n", e, h.n)
+	}
+
+	b.WriteString("# HELP go_source_response_bytes_total Response body bytes written by endpoint.\n")
+	b.WriteString("# TYPE go_source_response_bytes_total counter\n")
+	for _, e := range endpoints {
+		fmt.Fprintf(&b, "go_source_response_bytes_total{endpoint=%q} %d\n", e, m.bytes[e])
+	}
+	m.mu.Unlock()
+
+	b.WriteString("# HELP go_source_records_emitted_total Arrow records written to clients.\n")
+	b.WriteString("# TYPE go_source_records_emitted_total counter\n")
+	fmt.Fprintf(&b, "go_source_records_emitted_total %d\n", m.records.Load())
+	b.WriteString("# HELP go_source_parquet_read_errors_total Parquet files that failed to read.\n")
+	b.WriteString("# TYPE go_source_parquet_read_errors_total counter\n")
+	fmt.Fprintf(&b, "go_source_parquet_read_errors_total %d\n", m.readErrors.Load())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
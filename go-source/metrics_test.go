@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freshStats swaps in empty metrics for the duration of the test.
+func freshStats(t *testing.T) {
+	t.Helper()
+	old := stats
+	stats = &metrics{
+		requests: make(map[requestKey]uint64),
+		latency:  make(map[string]*latencyHist),
+		bytes:    make(map[string]uint64),
+	}
+	t.Cleanup(func() { stats = old })
+}
+
+func TestMetrics(t *testing.T) {
+	freshStats(t)
+	knownEndpoints["/stream"] = true
+	t.Cleanup(func() { delete(knownEndpoints, "/stream") })
+	dir := useDataDir(t)
+	t.Setenv("BATCH_SIZE", "10")
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 25))
+
+	h := instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			streamHandler(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+	stats.observe("/stream", http.StatusOK, 3*time.Second, 0)
+
+	w := httptest.NewRecorder()
+	metricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`go_source_http_requests_total{endpoint="/stream",code="200"} 3`,
+		`go_source_http_requests_total{endpoint="other",code="404"} 1`,
+		`go_source_http_request_duration_seconds_bucket{endpoint="/stream",le="2.5"} 2`,
+		`go_source_http_request_duration_seconds_bucket{endpoint="/stream",le="5"} 3`,
+		`go_source_http_request_duration_seconds_bucket{endpoint="/stream",le="+Inf"} 3`,
+		`go_source_http_request_duration_seconds_count{endpoint="/stream"} 3`,
+		"go_source_records_emitted_total 6",
+		"go_source_parquet_read_errors_total 0",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `go_source_response_bytes_total{endpoint="/stream"} 0`) {
+		t.Error("stream response bytes not counted")
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type %q", ct)
+	}
+}
+
+func TestMetricsReadErrors(t *testing.T) {
+	freshStats(t)
+	if _, err := readParquet(t.TempDir() + "/missing.parquet"); err == nil {
+		t.Fatal("read of a missing file succeeded")
+	}
+	if n := stats.readErrors.Load(); n != 1 {
+		t.Errorf("readErrors = %d, want 1", n)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name, token, path, auth string
+		want                    int
+	}{
+		{"authorized", "s3cret", "/stream", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "/stream", "Bearer nope", http.StatusUnauthorized},
+		{"no header", "s3cret", "/feather", "", http.StatusUnauthorized},
+		{"not bearer", "s3cret", "/stream", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"healthz open", "s3cret", "/healthz", "", http.StatusOK},
+		{"readyz open", "s3cret", "/readyz", "", http.StatusOK},
+		{"auth disabled", "", "/stream", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		requireToken(tt.token, ok).ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", tt.name)
+		}
+	}
+}
+
+func TestGzipResponses(t *testing.T) {
+	h := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	tests := []struct {
+		name, path, accept string
+		gzipped            bool
+	}{
+		{"json", "/schema", "gzip, deflate", true},
+		{"json q=0", "/schema", "gzip;q=0", false},
+		{"no accept", "/schema", "", false},
+		{"arrow", "/stream", "gzip", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Content-Encoding") == "gzip"; got != tt.gzipped {
+			t.Errorf("%s: gzipped = %t, want %t", tt.name, got, tt.gzipped)
+			continue
+		}
+		body := io.Reader(w.Body)
+		if tt.gzipped {
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			body = zr
+		}
+		if b, _ := io.ReadAll(body); string(b) != `{"ok":true}` {
+			t.Errorf("%s: body %q", tt.name, b)
+		}
+	}
+}
+
+func TestLimitStreams(t *testing.T) {
+	streamSlots = make(chan struct{}, 2)
+	t.Cleanup(func() { streamSlots = nil })
+
+	const clients = 5
+	entered := make(chan struct{}, clients)
+	release := make(chan struct{})
+	h := limitStreams(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	})
+
+	codes := make(chan *httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+			codes <- w
+		}()
+	}
+	// Two requests hold the slots; the other three are turned away.
+	for range clients - cap(streamSlots) {
+		w := <-codes
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Errorf("over the limit: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+	<-entered
+	<-entered
+	close(release)
+	wg.Wait()
+	close(codes)
+	for w := range codes {
+		if w.Code != http.StatusOK {
+			t.Errorf("within the limit: status %d", w.Code)
+		}
+	}
+
+	// Slots are returned once requests finish.
+	w := httptest.NewRecorder()
+	limitStreams(func(http.ResponseWriter, *http.Request) {})(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("after release: status %d", w.Code)
+	}
+}
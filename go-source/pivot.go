@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// pivotTable is one field spread across stations: a row per distinct
+// timestamp, a column per station. Missing cells are nil.
+type pivotTable struct {
+	Stations []string
+	Times    []int64
+	Values   [][]*float64 // [row][station]
+}
+
+// pivot aligns c across stations by Time. Stations are sorted by ID and
+// times ascend; a station without a row (or with a null) at some time gets
+// a nil cell there.
+func pivot(byStation map[string][]metrow.MetRow, c metrow.Column) pivotTable {
+	var t pivotTable
+	for s := range byStation {
+		t.Stations = append(t.Stations, s)
+	}
+	sort.Strings(t.Stations)
+
+	index := make(map[int64]int)
+	for _, rows := range byStation {
+		for _, r := range rows {
+			if _, ok := index[r.Time]; !ok {
+				index[r.Time] = 0
+				t.Times = append(t.Times, r.Time)
+			}
+		}
+	}
+	sort.Slice(t.Times, func(i, j int) bool { return t.Times[i] < t.Times[j] })
+	for i, ts := range t.Times {
+		index[ts] = i
+	}
+
+	t.Values = make([][]*float64, len(t.Times))
+	for i := range t.Values {
+		t.Values[i] = make([]*float64, len(t.Stations))
+	}
+	for j, s := range t.Stations {
+		rows := byStation[s]
+		for k := range rows {
+			if v, ok := c.Float(&rows[k]); ok {
+				t.Values[index[rows[k].Time]][j] = &v
+			}
+		}
+	}
+	return t
+}
+
+// columnNames returns the table header: "time", then "<station>_<field>".
+func (t pivotTable) columnNames(field string) []string {
+	names := []string{"time"}
+	for _, s := range t.Stations {
+		names = append(names, s+"_"+field)
+	}
+	return names
+}
+
+type pivotResponse struct {
+	Field   string   `json:"field"`
+	Columns []string `json:"columns"`
+	// Rows holds [time, value per station...]; missing values are null.
+	Rows [][]any `json:"rows"`
+}
+
+// pivotRecord builds the Arrow form of t: a non-null time column followed
+// by one nullable float64 column per station.
+func pivotRecord(mem memory.Allocator, t pivotTable, c metrow.Column) arrow.Record {
+	names := t.columnNames(c.Name)
+	unit := timestampUnit()
+	fields := []arrow.Field{{Name: names[0], Type: metrow.TimestampTypeFor(unit)}}
+	for _, n := range names[1:] {
+		fields = append(fields, arrow.Field{Name: n, Type: arrow.PrimitiveTypes.Float64, Nullable: true,
+			Metadata: arrow.NewMetadata([]string{"unit"}, []string{c.Unit})})
+	}
+	b := array.NewRecordBuilder(mem, arrow.NewSchema(fields, nil))
+	defer b.Release()
+	tb := b.Field(0).(*array.TimestampBuilder)
+	for i, ts := range t.Times {
+		tb.Append(metrow.ToTimestamp(ts, unit))
+		for j, v := range t.Values[i] {
+			fb := b.Field(j + 1).(*array.Float64Builder)
+			if v == nil {
+				fb.AppendNull()
+			} else {
+				fb.Append(*v)
+			}
+		}
+	}
+	return b.NewRecord()
+}
+
+// pivotHandler serves GET /pivot?field=wtmp_c: one measurement across all
+// stations, aligned by time, as JSON ({"columns": [...], "rows": [[...]]})
+// or, with ?format=arrow, as a single-record Arrow IPC stream.
+func pivotHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c, ok := metrow.LookupColumn(q.Get("field"))
+	if !ok || !c.Numeric() {
+		http.Error(w, "unknown field "+q.Get("field"), http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "arrow" {
+		http.Error(w, "format must be json or arrow", http.StatusBadRequest)
+		return
+	}
+
+	byStation := make(map[string][]metrow.MetRow)
+	for _, g := range findShards(dataDirs(), filenameTemplate()) {
+		rows, err := readShards(g)
+		if err != nil {
+			log.Printf("WARN readParquet %s: %v", g.Station, err)
+			continue
+		}
+		for st, rows := range splitStations(g, rows) {
+			byStation[st] = rows
+		}
+	}
+	t := pivot(byStation, c)
+
+	if format == "arrow" {
+		mem, release := newAllocator("/pivot")
+		defer release()
+		rec := pivotRecord(mem, t, c)
+		defer rec.Release()
+		w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+		wr := ipc.NewWriter(w, ipc.WithSchema(rec.Schema()), ipc.WithAllocator(mem))
+		defer wr.Close()
+		if err := wr.Write(rec); err != nil {
+			log.Printf("ERROR ipc write /pivot: %v", err)
+		}
+		return
+	}
+
+	resp := pivotResponse{Field: c.Name, Columns: t.columnNames(c.Name), Rows: make([][]any, len(t.Times))}
+	for i, ts := range t.Times {
+		row := make([]any, 0, len(t.Stations)+1)
+		row = append(row, ts)
+		for _, v := range t.Values[i] {
+			row = append(row, v)
+		}
+		resp.Rows[i] = row
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("ERROR encode /pivot: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestPivot(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SMKF1", []metrow.MetRow{
+		{StationID: "SMKF1", Time: 1718003600, WTMPC: f64(27)},
+		{StationID: "SMKF1", Time: 1718007200, WTMPC: f64(28)},
+	})
+	writeStation(t, dir, "SANF1", []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718000000, WTMPC: f64(25)},
+		{StationID: "SANF1", Time: 1718003600},
+	})
+
+	w := httptest.NewRecorder()
+	pivotHandler(w, httptest.NewRequest(http.MethodGet, "/pivot?field=wtmp_c", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Columns []string     `json:"columns"`
+		Rows    [][]*float64 `json:"rows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]any{{1718000000.0, 25.0, nil}, {1718003600.0, nil, 27.0}, {1718007200.0, nil, 28.0}}
+	if len(resp.Columns) != 3 || resp.Columns[1] != "SANF1_wtmp_c" || resp.Columns[2] != "SMKF1_wtmp_c" || len(resp.Rows) != len(want) {
+		t.Fatalf("pivot = %s", w.Body)
+	}
+	for i, row := range resp.Rows {
+		for j, v := range row {
+			if w := want[i][j]; (v == nil) != (w == nil) || v != nil && *v != w.(float64) {
+				t.Errorf("row %d col %d = %v, want %v", i, j, v, w)
+			}
+		}
+	}
+
+	w = httptest.NewRecorder()
+	pivotHandler(w, httptest.NewRequest(http.MethodGet, "/pivot?field=wtmp_c&format=arrow", nil))
+	rdr, err := ipc.NewReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Release()
+	if !rdr.Next() {
+		t.Fatal("no record")
+	}
+	rec := rdr.Record()
+	sanf := rec.Column(1).(*array.Float64)
+	if rec.NumRows() != 3 || rec.NumCols() != 3 || sanf.Value(0) != 25 || !sanf.IsNull(1) {
+		t.Errorf("arrow pivot = %v", rec)
+	}
+
+	for _, q := range []string{"field=nope", "field=wdir_card", "field=wtmp_c&format=csv"} {
+		w := httptest.NewRecorder()
+		pivotHandler(w, httptest.NewRequest(http.MethodGet, "/pivot?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
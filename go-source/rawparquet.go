@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// parquetHandler serves GET /parquet?station=SANF1: the station's newest
+// Parquet file exactly as stored, via http.ServeContent so Range requests
+// work and clients such as DuckDB's read_parquet can fetch just the footer
+// and the row groups they need. station=all serves the combined file
+// (COMBINED_OUTPUT).
+func parquetHandler(w http.ResponseWriter, r *http.Request) {
+	station := r.URL.Query().Get("station")
+	if station == "" {
+		http.Error(w, "station is required", http.StatusBadRequest)
+		return
+	}
+	var p string
+	for _, g := range findShards(dataDirs(), filenameTemplate()) {
+		if strings.EqualFold(g.Station, station) {
+			p = g.Newest()
+			break
+		}
+	}
+	if p == "" {
+		http.Error(w, "no data for station "+strings.ToUpper(station), http.StatusNotFound)
+		return
+	}
+	if verifyChecksums() {
+		if err := verifyChecksum(p); err != nil {
+			log.Printf("WARN  /parquet %s: %v", p, err)
+			http.Error(w, "checksum mismatch", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	src, _, err := openSource(p)
+	if err != nil {
+		log.Printf("WARN  /parquet open %s: %v", p, err)
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return
+	}
+	defer src.Close()
+	rs, ok := src.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return
+	}
+	// s3:// objects have no local mtime; ServeContent then omits
+	// Last-Modified.
+	var mod time.Time
+	if st, err := os.Stat(p); err == nil {
+		mod = st.ModTime()
+	}
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	w.Header().Set("Content-Disposition", `inline; filename="`+path.Base(p)+`"`)
+	http.ServeContent(w, r, path.Base(p), mod, rs)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+func TestParquetRange(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 10))
+	want, err := os.ReadFile(filepath.Join(dir, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	parquetHandler(w, httptest.NewRequest(http.MethodGet, "/parquet?station=sanf1", nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), want) {
+		t.Fatalf("full GET: status %d, %d bytes, want %d", w.Code, w.Body.Len(), len(want))
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/vnd.apache.parquet" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if rows, err := ndbc.ReadParquet(bytes.NewReader(w.Body.Bytes())); err != nil || len(rows) != 10 {
+		t.Errorf("served file: %d rows, err %v", len(rows), err)
+	}
+
+	// The footer: magic bytes at the end of the file.
+	r := httptest.NewRequest(http.MethodGet, "/parquet?station=SANF1", nil)
+	r.Header.Set("Range", "bytes=-8")
+	w = httptest.NewRecorder()
+	parquetHandler(w, r)
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Range: status %d, want 206", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), want[len(want)-8:]) || !bytes.HasSuffix(w.Body.Bytes(), []byte("PAR1")) {
+		t.Errorf("Range body %q", w.Body.Bytes())
+	}
+
+	w = httptest.NewRecorder()
+	parquetHandler(w, httptest.NewRequest(http.MethodGet, "/parquet?station=NOPE1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown station: status %d, want 404", w.Code)
+	}
+}
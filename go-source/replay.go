@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// maxReplayGap caps the pause between two snapshots, so a gap of days in
+// the archive does not stall a demo.
+const maxReplayGap = 30 * time.Second
+
+// snapshot is one archived station file and the newest observation in it.
+type snapshot struct {
+	path   string
+	newest int64
+	rows   []metrow.MetRow
+}
+
+// replayDirs returns REPLAY_DIR (colon-separated like DATA_DIR), falling
+// back to the data directories.
+func replayDirs() []string {
+	if v := metrow.Getenv("REPLAY_DIR", ""); v != "" {
+		return splitDirs(v)
+	}
+	return dataDirs()
+}
+
+// loadSnapshots reads every station file in dirs, keeping all snapshots of
+// a station rather than only the newest, ordered oldest first by the newest
+// observation each one contains.
+func loadSnapshots(dirs []string) []snapshot {
+	var out []snapshot
+	for _, d := range dirs {
+		paths, err := listParquet(d)
+		if err != nil {
+			log.Printf("WARN list %s: %v", d, err)
+			continue
+		}
+		for _, p := range paths {
+			rows, err := readParquet(p)
+			if err != nil {
+				log.Printf("WARN readParquet %s: %v", p, err)
+				continue
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			s := snapshot{path: p, rows: rows}
+			for _, r := range rows {
+				s.newest = max(s.newest, r.Time)
+			}
+			out = append(out, s)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].newest != out[j].newest {
+			return out[i].newest < out[j].newest
+		}
+		return out[i].path < out[j].path
+	})
+	return out
+}
+
+// parseSpeed reads ?speed=10x (or 10). Zero, the default, means no pacing.
+func parseSpeed(v string) (float64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "x"), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("speed: want a factor like 10x, got %q", v)
+	}
+	return f, nil
+}
+
+// replayHandler serves GET /replay: every archived snapshot as one Arrow
+// IPC stream, oldest first. With ?speed=N it sleeps between snapshots for
+// the gap between their observation times divided by N (capped at
+// maxReplayGap), flushing each snapshot so the client sees data arrive.
+// It stops as soon as the client goes away.
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	const contentType = "application/vnd.apache.arrow.stream"
+	if !serveHeadOrReject(w, r, contentType) {
+		return
+	}
+	speed, err := parseSpeed(r.URL.Query().Get("speed"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	snaps := loadSnapshots(replayDirs())
+
+	rc := http.NewResponseController(w)
+	if speed > 0 {
+		// A paced replay outlives the server's WriteTimeout by design.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("WARN replay: cannot lift write deadline: %v", err)
+		}
+	}
+
+	mem, release := newAllocator("/replay")
+	defer release()
+	cols := selectedColumns()
+	schema := streamSchema(cols)
+	batch := batchSize()
+
+	w.Header().Set("Content-Type", contentType)
+	wr := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	defer wr.Close()
+
+	for i, s := range snaps {
+		if i > 0 && speed > 0 {
+			gap := time.Duration(float64(time.Duration(s.newest-snaps[i-1].newest)*time.Second) / speed)
+			t := time.NewTimer(min(gap, maxReplayGap))
+			select {
+			case <-r.Context().Done():
+				t.Stop()
+				log.Printf("INFO  replay cancelled after %d/%d snapshots", i, len(snaps))
+				return
+			case <-t.C:
+			}
+		}
+		if r.Context().Err() != nil {
+			return
+		}
+		for start := 0; start < len(s.rows); start += batch {
+			end := min(start+batch, len(s.rows))
+			rec := rowsToRecord(mem, schema, cols, s.rows[start:end])
+			err := wr.Write(rec)
+			rec.Release()
+			if err != nil {
+				log.Printf("ERROR ipc write %s: %v", s.path, err)
+				return
+			}
+			stats.records.Add(1)
+		}
+		rc.Flush()
+		log.Printf("SENT  %s (%d rows, replay)", s.path, len(s.rows))
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// writeSnapshots stores three archived snapshots in dir, named by a
+// {date} template, whose file names do not sort in replay order.
+func writeSnapshots(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("FILENAME_TEMPLATE", "{station}_{date}.parquet")
+	day := int64(86400)
+	for name, newest := range map[string]int64{
+		"SANF1_20240611.parquet": 1718000000 + day,
+		"SANF1_20240610.parquet": 1718000000,
+		"AAAA1_20240612.parquet": 1718000000 + 2*day,
+	} {
+		writeParquetFile(t, filepath.Join(dir, name), []metrow.MetRow{
+			{StationID: name[:5], Time: newest - 3600},
+			{StationID: name[:5], Time: newest},
+		})
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("REPLAY_DIR", dir)
+	writeSnapshots(t, dir)
+
+	w := httptest.NewRecorder()
+	replayHandler(w, httptest.NewRequest(http.MethodGet, "/replay", nil))
+	rd, err := ipc.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("status %d: %v", w.Code, err)
+	}
+	defer rd.Release()
+	var newest []int64
+	for rd.Next() {
+		ts := rd.Record().Column(1).(*array.Timestamp)
+		newest = append(newest, int64(ts.Value(ts.Len()-1)))
+	}
+	want := []int64{1718000000, 1718086400, 1718172800}
+	if len(newest) != len(want) {
+		t.Fatalf("%d snapshots, want %d", len(newest), len(want))
+	}
+	for i := range want {
+		if newest[i] != want[i] {
+			t.Errorf("snapshot %d newest %d, want %d", i, newest[i], want[i])
+		}
+	}
+}
+
+func TestReplayStopsWhenClientLeaves(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("REPLAY_DIR", dir)
+	writeSnapshots(t, dir)
+
+	// At 1x the day-long gaps are capped at maxReplayGap; cancelling must
+	// end the wait well before that.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	w := httptest.NewRecorder()
+	replayHandler(w, httptest.NewRequest(http.MethodGet, "/replay?speed=1x", nil).WithContext(ctx))
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("replay ran %s after the client left", d)
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	for v, want := range map[string]float64{"": 0, "10x": 10, "2.5": 2.5, "0x": 0} {
+		if got, err := parseSpeed(v); err != nil || got != want {
+			t.Errorf("parseSpeed(%q) = %g, %v; want %g", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"fast", "-2x", "x"} {
+		if _, err := parseSpeed(v); err == nil {
+			t.Errorf("parseSpeed(%q) accepted", v)
+		}
+	}
+	w := httptest.NewRecorder()
+	replayHandler(w, httptest.NewRequest(http.MethodGet, "/replay?speed=fast", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad speed: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestNewServer(t *testing.T) {
+	useDataDir(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(serverConfig{Addr: ln.Addr().String(), Token: "s3cret", MetricsPort: "9100"})
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	base := "http://" + ln.Addr().String()
+
+	for _, tt := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/healthz", "", http.StatusOK},
+		{"/schema", "", http.StatusUnauthorized},
+		{"/schema", "s3cret", http.StatusOK},
+		// Served on METRICS_PORT instead; off without DEBUG.
+		{"/metrics", "s3cret", http.StatusNotFound},
+		{"/debug/rows", "s3cret", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, base+tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s (token %q): status %d, want %d", tt.path, tt.token, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	for _, tt := range []struct{ port, addr, want string }{
+		{"", "", ":8080"},
+		{"9000", "", ":9000"},
+		{"9000", "127.0.0.1:8081", "127.0.0.1:8081"},
+	} {
+		t.Setenv("ARROW_PORT", tt.port)
+		t.Setenv("LISTEN_ADDR", tt.addr)
+		if got := listenAddr(); got != tt.want {
+			t.Errorf("ARROW_PORT=%q LISTEN_ADDR=%q: %q, want %q", tt.port, tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// stationShards is every file of one station in the directory that serves
+// it, in name order. With a dated FILENAME_TEMPLATE each file is a history
+// shard and name order is time order; with the default template there is
+// exactly one.
+type stationShards struct {
+	Station string
+	Paths   []string
+}
+
+// Newest returns the last shard, the one findParquet serves alone.
+func (s stationShards) Newest() string { return s.Paths[len(s.Paths)-1] }
+
+// findShards groups the files named by tmpl by station. A station is served
+// from the first directory it appears in (see DATA_DIR); shards of the same
+// station in later directories are ignored.
+func findShards(dirs []string, tmpl metrow.FilenameTemplate) []stationShards {
+	seen := make(map[string]bool)
+	var out []stationShards
+	for _, d := range dirs {
+		files, err := listTemplate(d, tmpl)
+		if err != nil {
+			log.Printf("WARN list %s: %v", d, err)
+			continue
+		}
+		matches := make([]string, len(files))
+		for i, f := range files {
+			matches[i] = f.Path
+		}
+		sort.Strings(matches)
+		group := make(map[string]int)
+		for _, m := range matches {
+			station, _ := tmpl.Station(strings.TrimSuffix(m, gzSuffix))
+			if seen[station] {
+				continue
+			}
+			i, ok := group[station]
+			if !ok {
+				i = len(out)
+				group[station] = i
+				out = append(out, stationShards{Station: station})
+			}
+			out[i].Paths = append(out[i].Paths, m)
+		}
+		for station := range group {
+			seen[station] = true
+		}
+	}
+	return out
+}
+
+// Combined reports whether s is the multi-station file go-ingest writes
+// with COMBINED_OUTPUT rather than a single station's.
+func (s stationShards) Combined() bool {
+	return strings.EqualFold(s.Station, metrow.CombinedStation)
+}
+
+// rowKey identifies an observation across the stations of a combined file.
+type rowKey struct {
+	station string
+	time    int64
+}
+
+// readShards reads every shard of a station and combines them into one
+// time-ordered slice. Rows repeating a station and timestamp are
+// de-duplicated in favour of the later shard, which holds the more recent
+// copy of the observation. A single shard is returned as stored.
+func readShards(s stationShards) ([]metrow.MetRow, error) {
+	if len(s.Paths) == 1 {
+		return readParquet(s.Paths[0])
+	}
+	seen := make(map[rowKey]int)
+	var all []metrow.MetRow
+	for _, p := range s.Paths {
+		rows, err := readParquet(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			k := rowKey{r.StationID, r.Time}
+			if i, dup := seen[k]; dup {
+				all[i] = r
+				continue
+			}
+			seen[k] = len(all)
+			all = append(all, r)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time < all[j].Time })
+	return all, nil
+}
+
+// splitStations files rows read from s under their station: s.Station for
+// a station file, each row's station_id for a combined file.
+func splitStations(s stationShards, rows []metrow.MetRow) map[string][]metrow.MetRow {
+	if !s.Combined() {
+		return map[string][]metrow.MetRow{s.Station: rows}
+	}
+	out := make(map[string][]metrow.MetRow)
+	for _, r := range rows {
+		out[r.StationID] = append(out[r.StationID], r)
+	}
+	return out
+}
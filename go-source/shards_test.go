@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestReadShardsDeduplicates(t *testing.T) {
+	dir := useDataDir(t)
+	t.Setenv("FILENAME_TEMPLATE", "{station}_{date}.parquet")
+	writeParquetFile(t, filepath.Join(dir, "SANF1_20240610.parquet"), []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: f64(1)},
+		{StationID: "SANF1", Time: 1718003600, WSPDmS: f64(2)},
+	})
+	writeParquetFile(t, filepath.Join(dir, "SANF1_20240611.parquet"), []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718003600, WSPDmS: f64(20)},
+		{StationID: "SANF1", Time: 1718007200, WSPDmS: f64(3)},
+	})
+	writeParquetFile(t, filepath.Join(dir, "SMKF1_20240611.parquet"), hourlyRows("SMKF1", 1))
+
+	groups := findShards([]string{dir}, filenameTemplate())
+	if len(groups) != 2 || groups[0].Station != "SANF1" || len(groups[0].Paths) != 2 {
+		t.Fatalf("findShards = %+v, want SANF1 with 2 shards and SMKF1", groups)
+	}
+	if got := groups[0].Newest(); filepath.Base(got) != "SANF1_20240611.parquet" {
+		t.Errorf("Newest = %s", got)
+	}
+
+	rows, err := readShards(groups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1, 20, 3}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i, r := range rows {
+		if r.Time != 1718000000+int64(i)*3600 || *r.WSPDmS != want[i] {
+			t.Errorf("row %d: time %d wspd %v, want %d %v", i, r.Time, *r.WSPDmS, 1718000000+int64(i)*3600, want[i])
+		}
+	}
+}
+
+func TestStationRowsFromCombinedFile(t *testing.T) {
+	dir := useDataDir(t)
+	combined := append(hourlyRows("SANF1", 2), hourlyRows("SMKF1", 2)...)
+	writeStation(t, dir, metrow.CombinedStation, combined)
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 3))
+
+	rows, found, err := stationRows("sanf1")
+	if err != nil || !found || len(rows) != 2 || rows[0].StationID != "SANF1" || rows[1].StationID != "SANF1" {
+		t.Errorf("sanf1: %d rows, found %v, err %v; want its 2 rows of the combined file", len(rows), found, err)
+	}
+	// A station file takes precedence over the combined one.
+	if rows, _, _ := stationRows("SMKF1"); len(rows) != 3 {
+		t.Errorf("SMKF1: %d rows, want the 3 of its station file", len(rows))
+	}
+	if _, found, _ := stationRows("NOPE1"); found {
+		t.Error("NOPE1 found")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// stationStatus is one station's entry in /status: the file served for it,
+// the provenance go-ingest recorded there and, from go-ingest's
+// fetch_status.json, its latest fetch attempt and success. Stale is set
+// when the latest attempt failed, so the file is older than it should be.
+// Provenance fields are empty for files written before it was captured.
+type stationStatus struct {
+	Station     string     `json:"station"`
+	Path        string     `json:"path,omitempty"`
+	SourceURL   string     `json:"source_url,omitempty"`
+	FetchedAt   string     `json:"fetched_at,omitempty"`
+	ToolVersion string     `json:"tool_version,omitempty"`
+	BatchID     string     `json:"batch_id,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Stale       bool       `json:"stale"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// readFetchStatus reads the fetch_status.json go-ingest wrote to the first
+// data directory that has one; it is nil if none does.
+func readFetchStatus() map[string]metrow.FetchStatus {
+	for _, d := range dataDirs() {
+		b, err := readObject(joinPath(d, metrow.FetchStatusName))
+		if err != nil {
+			continue
+		}
+		var out map[string]metrow.FetchStatus
+		if err := json.Unmarshal(b, &out); err != nil {
+			log.Printf("WARN  %s in %s: %v", metrow.FetchStatusName, d, err)
+			continue
+		}
+		return out
+	}
+	return nil
+}
+
+// withFetchStatus fills in st's fetch fields from fs.
+func (st *stationStatus) withFetchStatus(fs metrow.FetchStatus) {
+	at := fs.LastAttempt
+	st.LastAttempt, st.LastSuccess, st.LastError = &at, fs.LastSuccess, fs.LastError
+	st.Stale = fs.Stale()
+}
+
+// readStatus reads the provenance metadata of the parquet file at path.
+func readStatus(station, path string) stationStatus {
+	st := stationStatus{Station: station, Path: path}
+	pf, f, err := openParquetFile(path)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	defer f.Close()
+	st.SourceURL, _ = pf.Lookup(metrow.SourceURLKey)
+	st.FetchedAt, _ = pf.Lookup(metrow.FetchedAtKey)
+	st.ToolVersion, _ = pf.Lookup(metrow.ToolVersionKey)
+	st.BatchID, _ = pf.Lookup(metrow.BatchIDKey)
+	return st
+}
+
+// statusHandler serves GET /status: for every station, the newest file,
+// where and when its data was fetched, and whether go-ingest is failing to
+// refresh it. Stations go-ingest tried but never wrote are listed too,
+// without a path.
+func statusHandler(w http.ResponseWriter, _ *http.Request) {
+	fetches := readFetchStatus()
+	out := []stationStatus{}
+	seen := make(map[string]bool)
+	for _, g := range findShards(dataDirs(), filenameTemplate()) {
+		st := readStatus(g.Station, g.Newest())
+		if fs, ok := fetches[g.Station]; ok {
+			st.withFetchStatus(fs)
+		}
+		seen[g.Station] = true
+		out = append(out, st)
+	}
+	for station, fs := range fetches {
+		if seen[station] {
+			continue
+		}
+		st := stationStatus{Station: station}
+		st.withFetchStatus(fs)
+		out = append(out, st)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Station < out[j].Station })
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("ERROR encode /status: %v", err)
+	}
+}
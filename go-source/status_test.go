@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestStatus(t *testing.T) {
+	dir := useDataDir(t)
+	f, err := os.Create(filepath.Join(dir, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := parquet.NewGenericWriter[metrow.MetRow](f,
+		parquet.KeyValueMetadata(metrow.SourceURLKey, "https://www.ndbc.noaa.gov/data/realtime2/SANF1.txt"),
+		parquet.KeyValueMetadata(metrow.FetchedAtKey, "2024-06-10T07:30:00Z"),
+		parquet.KeyValueMetadata(metrow.ToolVersionKey, "v1.2.3"),
+		parquet.KeyValueMetadata(metrow.BatchIDKey, "5c0e8a52-53b4-4c59-9b5e-1f1d2f6d6f01"))
+	if _, err := w.Write(hourlyRows("SANF1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	writeStation(t, dir, "SMKF1", hourlyRows("SMKF1", 1))
+
+	rec := httptest.NewRecorder()
+	statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var got []stationStatus
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []stationStatus{
+		{Station: "SANF1", Path: filepath.Join(dir, "SANF1_latest.parquet"),
+			SourceURL: "https://www.ndbc.noaa.gov/data/realtime2/SANF1.txt", FetchedAt: "2024-06-10T07:30:00Z", ToolVersion: "v1.2.3",
+			BatchID: "5c0e8a52-53b4-4c59-9b5e-1f1d2f6d6f01"},
+		// Written before provenance was recorded.
+		{Station: "SMKF1", Path: filepath.Join(dir, "SMKF1_latest.parquet")},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("/status = %+v, want %+v", got, want)
+	}
+}
+
+func TestStatusFetchStatus(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 1))
+	ok := time.Date(2024, 6, 10, 7, 0, 0, 0, time.UTC)
+	failed := ok.Add(time.Hour)
+	b, _ := json.Marshal(map[string]metrow.FetchStatus{
+		"SANF1": {LastAttempt: ok, LastSuccess: &ok},
+		"SMKF1": {LastAttempt: failed, LastError: "404 Not Found"},
+	})
+	if err := os.WriteFile(filepath.Join(dir, metrow.FetchStatusName), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var got []stationStatus
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("/status = %+v, want 2 stations", got)
+	}
+	if s := got[0]; s.Station != "SANF1" || s.Path == "" || s.Stale || s.LastSuccess == nil || !s.LastSuccess.Equal(ok) {
+		t.Errorf("fresh station = %+v", s)
+	}
+	// Tried but never written: listed without a path, and stale.
+	if s := got[1]; s.Station != "SMKF1" || s.Path != "" || !s.Stale || s.LastError != "404 Not Found" || !s.LastAttempt.Equal(failed) {
+		t.Errorf("failing station = %+v", s)
+	}
+}
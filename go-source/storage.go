@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/internal/objstore"
+)
+
+// s3Scheme marks a DATA_DIR entry (and every file path derived from it) as
+// living in an S3-compatible bucket rather than on local disk.
+const s3Scheme = "s3://"
+
+// storageTimeout bounds a single list or get against object storage.
+const storageTimeout = 2 * time.Minute
+
+var (
+	s3Mu      sync.Mutex
+	s3Clients = make(map[string]*objstore.Client)
+)
+
+// splitS3 splits s3://bucket/key into bucket and key.
+func splitS3(p string) (bucket, key string) {
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(p, s3Scheme), "/")
+	return bucket, key
+}
+
+// s3Client returns the cached client for bucket, building it from the
+// environment on first use.
+func s3Client(bucket string) (*objstore.Client, error) {
+	s3Mu.Lock()
+	defer s3Mu.Unlock()
+	if c, ok := s3Clients[bucket]; ok {
+		return c, nil
+	}
+	c, err := objstore.NewFromEnv(bucket)
+	if err != nil {
+		return nil, err
+	}
+	s3Clients[bucket] = c
+	return c, nil
+}
+
+// storedFile is one station file found in a data directory.
+type storedFile struct {
+	Path    string
+	ModTime time.Time
+}
+
+// gzSuffix marks a gzip-compressed station file, e.g.
+// SANF1_latest.parquet.gz. Such files are listed and read like plain ones.
+const gzSuffix = ".gz"
+
+// listStored returns the station files (per FILENAME_TEMPLATE, optionally
+// gzipped) directly inside dir, which is either a local directory or an
+// s3://bucket/prefix URL.
+func listStored(dir string) ([]storedFile, error) {
+	return listTemplate(dir, filenameTemplate())
+}
+
+// listTemplate is listStored for files named by tmpl.
+func listTemplate(dir string, tmpl metrow.FilenameTemplate) ([]storedFile, error) {
+	if !strings.HasPrefix(dir, s3Scheme) {
+		matches, err := filepath.Glob(filepath.Join(dir, tmpl.Glob()))
+		if err != nil {
+			return nil, err
+		}
+		gz, err := filepath.Glob(filepath.Join(dir, tmpl.Glob()+gzSuffix))
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, gz...)
+		out := make([]storedFile, 0, len(matches))
+		for _, m := range matches {
+			if _, ok := tmpl.Station(strings.TrimSuffix(m, gzSuffix)); !ok {
+				continue
+			}
+			st, err := os.Stat(m)
+			if err != nil {
+				continue // removed between glob and stat
+			}
+			out = append(out, storedFile{Path: m, ModTime: st.ModTime()})
+		}
+		return out, nil
+	}
+	bucket, prefix := splitS3(dir)
+	c, err := s3Client(bucket)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	objs, err := c.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var out []storedFile
+	for _, o := range objs {
+		rest := strings.TrimPrefix(o.Key, prefix)
+		if strings.Contains(rest, "/") {
+			continue
+		}
+		if _, ok := tmpl.Station(strings.TrimSuffix(rest, gzSuffix)); !ok {
+			continue
+		}
+		out = append(out, storedFile{Path: s3Scheme + path.Join(bucket, o.Key), ModTime: o.LastModified})
+	}
+	return out, nil
+}
+
+// listParquet is listStored without the modification times.
+func listParquet(dir string) ([]string, error) {
+	files, err := listStored(dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f.Path
+	}
+	return out, nil
+}
+
+// parquetSource is what the parquet reader needs: random access plus a size.
+type parquetSource interface {
+	io.ReaderAt
+	io.Closer
+}
+
+type bytesSource struct{ *bytes.Reader }
+
+func (bytesSource) Close() error { return nil }
+
+// openSource opens a local file or downloads an s3:// object into memory.
+// Station files are small, so buffering the whole object is simpler than
+// issuing range reads for every page. A .gz file is decompressed into
+// memory, since the parquet reader needs random access.
+func openSource(p string) (parquetSource, int64, error) {
+	if strings.HasSuffix(p, gzSuffix) {
+		return openGzip(p)
+	}
+	if !strings.HasPrefix(p, s3Scheme) {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, 0, err
+		}
+		st, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, st.Size(), nil
+	}
+	bucket, key := splitS3(p)
+	c, err := s3Client(bucket)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	data, err := c.Get(ctx, key)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get %s: %w", p, err)
+	}
+	return bytesSource{bytes.NewReader(data)}, int64(len(data)), nil
+}
+
+// openGzip reads and decompresses the gzipped file p.
+func openGzip(p string) (parquetSource, int64, error) {
+	data, err := readObject(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("gunzip %s: %w", p, err)
+	}
+	defer zr.Close()
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gunzip %s: %w", p, err)
+	}
+	return bytesSource{bytes.NewReader(b)}, int64(len(b)), nil
+}
+
+// readObject returns the whole content of a local file or s3:// object.
+func readObject(p string) ([]byte, error) {
+	if !strings.HasPrefix(p, s3Scheme) {
+		return os.ReadFile(p)
+	}
+	bucket, key := splitS3(p)
+	c, err := s3Client(bucket)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	return c.Get(ctx, key)
+}
+
+// joinPath joins name onto a local directory or s3:// URL.
+func joinPath(dir, name string) string {
+	if strings.HasPrefix(dir, s3Scheme) {
+		return s3Scheme + path.Join(strings.TrimPrefix(dir, s3Scheme), name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// missingDirs returns the local entries of dirs that do not exist. s3://
+// entries are never reported; a bucket prefix exists as soon as an object
+// is written under it.
+func missingDirs(dirs []string) []string {
+	var out []string
+	for _, d := range dirs {
+		if strings.HasPrefix(d, s3Scheme) {
+			continue
+		}
+		if _, err := os.Stat(d); errors.Is(err, fs.ErrNotExist) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
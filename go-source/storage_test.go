@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDataDirsS3(t *testing.T) {
+	t.Setenv("DATA_DIR", "/hot: s3://buoys/archive :/cold")
+	want := []string{"/hot", "s3://buoys/archive", "/cold"}
+	if got := dataDirs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dataDirs() = %q, want %q", got, want)
+	}
+}
+
+// fakeBucket serves ListObjectsV2 and GET for the objects in files,
+// keyed by "bucket/key".
+func fakeBucket(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/")
+		if r.URL.Query().Get("list-type") == "2" {
+			bucket := strings.TrimSuffix(p, "/")
+			prefix := r.URL.Query().Get("prefix")
+			fmt.Fprint(w, "<ListBucketResult>")
+			for k, b := range files {
+				if key := strings.TrimPrefix(k, bucket+"/"); key != k && strings.HasPrefix(key, prefix) {
+					fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(b))
+				}
+			}
+			fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+			return
+		}
+		b, ok := files[p]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("S3_ENDPOINT", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AK")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SK")
+	return srv
+}
+
+func TestReadParquetFromS3(t *testing.T) {
+	local := t.TempDir()
+	writeStation(t, local, "SANF1", hourlyRows("SANF1", 3))
+	data, err := os.ReadFile(filepath.Join(local, "SANF1_latest.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeBucket(t, map[string][]byte{
+		"s3read/ndbc/SANF1_latest.parquet":     data,
+		"s3read/ndbc/old/OLDF1_latest.parquet": data,
+		"s3read/ndbc/notes.txt":                []byte("x"),
+	})
+
+	files := findParquet([]string{"s3://s3read/ndbc"})
+	if want := []string{"s3://s3read/ndbc/SANF1_latest.parquet"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("findParquet = %q, want %q", files, want)
+	}
+	rows, err := readParquet(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].StationID != "SANF1" {
+		t.Errorf("read %d rows (%+v)", len(rows), rows)
+	}
+	if _, err := readParquet("s3://s3read/ndbc/MISSING_latest.parquet"); err == nil {
+		t.Error("missing object should fail")
+	}
+}
+
+func TestGzippedStationFiles(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 3))
+	plain := filepath.Join(dir, "SANF1_latest.parquet")
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+	if err := os.WriteFile(plain+gzSuffix, gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "BADF1_latest.parquet.gz"), []byte("not gzip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	streamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if got := streamStations(t, rec.Body); len(got) != 1 || got["SANF1"] != 3 {
+		t.Errorf("streamed %v, want SANF1's 3 rows and the corrupt file skipped", got)
+	}
+	rec = httptest.NewRecorder()
+	parquetHandler(rec, httptest.NewRequest(http.MethodGet, "/parquet?station=SANF1", nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Errorf("/parquet: status %d, %d bytes; want the %d decompressed bytes", rec.Code, rec.Body.Len(), len(data))
+	}
+
+	// The same holds for objects in S3.
+	fakeBucket(t, map[string][]byte{"s3gz/ndbc/SANF1_latest.parquet.gz": gz.Bytes()})
+	rows, err := readParquet("s3://s3gz/ndbc/SANF1_latest.parquet.gz")
+	if err != nil || len(rows) != 3 {
+		t.Errorf("s3 .gz: %d rows, err %v", len(rows), err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"example.com/arrow-buoys/internal/metrow"
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// fieldStats are the aggregates /summary reports. Min, Max, and Mean are
+// null when the field has no values, as are the requested Percentiles,
+// keyed "p50", "p90", ...
+type fieldStats struct {
+	Station     string              `json:"station"`
+	Field       string              `json:"field"`
+	Count       int                 `json:"count"`
+	Min         *float64            `json:"min"`
+	Max         *float64            `json:"max"`
+	Mean        *float64            `json:"mean"`
+	Percentiles map[string]*float64 `json:"percentiles,omitempty"`
+}
+
+// summarize aggregates column c over rows, skipping nulls.
+func summarize(rows []metrow.MetRow, c metrow.Column) fieldStats {
+	st := fieldStats{Field: c.Name}
+	lo, hi, sum := math.Inf(1), math.Inf(-1), 0.0
+	for i := range rows {
+		v, ok := c.Float(&rows[i])
+		if !ok {
+			continue
+		}
+		st.Count++
+		lo = min(lo, v)
+		hi = max(hi, v)
+		sum += v
+	}
+	if st.Count > 0 {
+		mean := sum / float64(st.Count)
+		st.Min, st.Max, st.Mean = &lo, &hi, &mean
+	}
+	return st
+}
+
+// parsePercentiles parses the ?percentiles= list, e.g. "50,90,95"; each
+// must be a number within [0, 100] (NaN and ±Inf are not).
+func parsePercentiles(v string) ([]float64, error) {
+	var out []float64
+	for _, f := range strings.Split(v, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || math.IsNaN(p) || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q (want 0-100)", f)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// percentiles returns each of ps over column c of rows, skipping nulls,
+// interpolating linearly between the closest ranks of the sorted values.
+func percentiles(rows []metrow.MetRow, c metrow.Column, ps []float64) map[string]*float64 {
+	var vals []float64
+	for i := range rows {
+		if v, ok := c.Float(&rows[i]); ok {
+			vals = append(vals, v)
+		}
+	}
+	sort.Float64s(vals)
+	out := make(map[string]*float64, len(ps))
+	for _, p := range ps {
+		key := "p" + strconv.FormatFloat(p, 'f', -1, 64)
+		if len(vals) == 0 {
+			out[key] = nil
+			continue
+		}
+		rank := p / 100 * float64(len(vals)-1)
+		lo := int(math.Floor(rank))
+		v := vals[lo]
+		if lo+1 < len(vals) {
+			v += (rank - float64(lo)) * (vals[lo+1] - vals[lo])
+		}
+		out[key] = &v
+	}
+	return out
+}
+
+// stationRows returns the combined shards /stream would serve for station.
+// Without a station file, the station's rows are taken from a combined
+// file (COMBINED_OUTPUT). found is false when neither has the station.
+func stationRows(station string) (rows []metrow.MetRow, found bool, err error) {
+	groups := findShards(dataDirs(), filenameTemplate())
+	for _, g := range groups {
+		if strings.EqualFold(g.Station, station) && !g.Combined() {
+			rows, err = readShards(g)
+			return rows, true, err
+		}
+	}
+	for _, g := range groups {
+		if !g.Combined() {
+			continue
+		}
+		all, err := readShards(g)
+		if err != nil {
+			return nil, true, err
+		}
+		if rows, ok := splitStations(g, all)[strings.ToUpper(station)]; ok {
+			return rows, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// summaryHandler serves GET /summary?station=SANF1&field=wtmp_c: count,
+// min, max, and mean of one measurement column over the station's rows,
+// plus any ?percentiles=50,90,95.
+func summaryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	station := strings.ToUpper(q.Get("station"))
+	if station == "" {
+		http.Error(w, "station is required", http.StatusBadRequest)
+		return
+	}
+	c, ok := metrow.LookupColumn(q.Get("field"))
+	if !ok || !c.Numeric() {
+		http.Error(w, "unknown field "+q.Get("field"), http.StatusBadRequest)
+		return
+	}
+	var ps []float64
+	if v := q.Get("percentiles"); v != "" {
+		var err error
+		if ps, err = parsePercentiles(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	rows, ok, err := stationRows(station)
+	if !ok {
+		http.Error(w, "no data for station "+station, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("WARN readParquet %s: %v", station, err)
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return
+	}
+
+	st := summarize(rows, c)
+	st.Station = station
+	if len(ps) > 0 {
+		st.Percentiles = percentiles(rows, c, ps)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		log.Printf("ERROR encode /summary: %v", err)
+	}
+}
+
+// columnQC counts how well one column is populated.
+type columnQC struct {
+	Total   int `json:"total"`
+	Present int `json:"present"`
+	Null    int `json:"null"`
+	// Sentinel counts present values equal to an NDBC missing marker
+	// (99, 999, 9999) that slipped through parsing.
+	Sentinel int `json:"sentinel"`
+}
+
+type qcResponse struct {
+	Station string              `json:"station"`
+	Rows    int                 `json:"rows"`
+	Columns map[string]columnQC `json:"columns"`
+}
+
+// columnCounts tallies present, null, and sentinel-like values per column.
+func columnCounts(rows []metrow.MetRow, cols []metrow.Column) map[string]columnQC {
+	out := make(map[string]columnQC, len(cols))
+	for _, c := range cols {
+		if !c.Nullable() {
+			continue
+		}
+		q := columnQC{Total: len(rows)}
+		for i := range rows {
+			if c.IsNull(&rows[i]) {
+				q.Null++
+				continue
+			}
+			q.Present++
+			if v, ok := c.Float(&rows[i]); ok && c.Numeric() && slices.Contains(ndbc.DefaultSentinels, v) {
+				q.Sentinel++
+			}
+		}
+		out[c.Name] = q
+	}
+	return out
+}
+
+// qcHandler serves GET /qc?station=SANF1: per-column total, present, null,
+// and sentinel-like counts, to spot sensors that have gone dark.
+func qcHandler(w http.ResponseWriter, r *http.Request) {
+	station := strings.ToUpper(r.URL.Query().Get("station"))
+	if station == "" {
+		http.Error(w, "station is required", http.StatusBadRequest)
+		return
+	}
+	rows, ok, err := stationRows(station)
+	if !ok {
+		http.Error(w, "no data for station "+station, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("WARN readParquet %s: %v", station, err)
+		http.Error(w, "read failed", http.StatusInternalServerError)
+		return
+	}
+
+	resp := qcResponse{Station: station, Rows: len(rows), Columns: columnCounts(rows, selectedColumns())}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("ERROR encode /qc: %v", err)
+	}
+}
+
+// readManifest decodes the manifest.json go-ingest wrote to the first data
+// directory that has one.
+func readManifest() (metrow.Manifest, bool) {
+	var m metrow.Manifest
+	for _, d := range dataDirs() {
+		b, err := readObject(joinPath(d, metrow.ManifestName))
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(b, &m); err != nil {
+			log.Printf("WARN  %s in %s: %v", metrow.ManifestName, d, err)
+			continue
+		}
+		return m, true
+	}
+	return m, false
+}
+
+// manifestHandler serves the manifest.json go-ingest wrote to the first
+// data directory that has one.
+func manifestHandler(w http.ResponseWriter, _ *http.Request) {
+	for _, d := range dataDirs() {
+		b, err := readObject(joinPath(d, metrow.ManifestName))
+		if err != nil {
+			continue
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+		return
+	}
+	http.Error(w, "no manifest", http.StatusNotFound)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestSummary(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718000000, WTMPC: f64(26.5)},
+		{StationID: "SANF1", Time: 1718003600},
+		{StationID: "SANF1", Time: 1718007200, WTMPC: f64(24.0)},
+		{StationID: "SANF1", Time: 1718010800, WTMPC: f64(28.0)},
+	})
+
+	w := httptest.NewRecorder()
+	summaryHandler(w, httptest.NewRequest(http.MethodGet, "/summary?station=sanf1&field=wtmp_c", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var st fieldStats
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Count != 3 || *st.Min != 24 || *st.Max != 28 || *st.Mean != 26.166666666666668 {
+		t.Errorf("count %d min %v max %v mean %v, want 3 24 28 26.17", st.Count, *st.Min, *st.Max, *st.Mean)
+	}
+
+	// A field with no values at all: count 0, null aggregates.
+	w = httptest.NewRecorder()
+	summaryHandler(w, httptest.NewRequest(http.MethodGet, "/summary?station=SANF1&field=dewp_c", nil))
+	st = fieldStats{}
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || st.Count != 0 || st.Min != nil || st.Mean != nil {
+		t.Errorf("all-null field: %+v, err %v", st, err)
+	}
+
+	w = httptest.NewRecorder()
+	summaryHandler(w, httptest.NewRequest(http.MethodGet, "/summary?station=SANF1&field=nope", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown field: status %d, want 400", w.Code)
+	}
+}
+
+func TestQC(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: f64(5), PREShPa: f64(9999)},
+		{StationID: "SANF1", Time: 1718003600, WSPDmS: f64(99)},
+		{StationID: "SANF1", Time: 1718007200},
+	})
+
+	w := httptest.NewRecorder()
+	qcHandler(w, httptest.NewRequest(http.MethodGet, "/qc?station=sanf1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp qcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Station != "SANF1" || resp.Rows != 3 {
+		t.Errorf("station %q rows %d", resp.Station, resp.Rows)
+	}
+	for name, want := range map[string]columnQC{
+		"wspd_ms":  {Total: 3, Present: 2, Null: 1, Sentinel: 1},
+		"pres_hpa": {Total: 3, Present: 1, Null: 2, Sentinel: 1},
+		"dewp_c":   {Total: 3, Null: 3},
+	} {
+		if got := resp.Columns[name]; got != want {
+			t.Errorf("%s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		url  string
+		code int
+	}{
+		{"/qc", http.StatusBadRequest},
+		{"/qc?station=NOPE1", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		qcHandler(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.url, w.Code, tt.code)
+		}
+	}
+}
+
+func TestManifestHandler(t *testing.T) {
+	hot, archive := t.TempDir(), t.TempDir()
+	t.Setenv("DATA_DIR", hot+":"+archive)
+
+	w := httptest.NewRecorder()
+	manifestHandler(w, httptest.NewRequest(http.MethodGet, "/manifest", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("no manifest: status %d, want 404", w.Code)
+	}
+
+	want := `{"cycle":"2024-06-10T08:00:00Z","files":[]}`
+	if err := os.WriteFile(filepath.Join(archive, metrow.ManifestName), []byte(want), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	manifestHandler(w, httptest.NewRequest(http.MethodGet, "/manifest", nil))
+	if w.Code != http.StatusOK || w.Body.String() != want || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("status %d, type %q, body %q", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	c, _ := metrow.LookupColumn("wspd_ms")
+	var rows []metrow.MetRow
+	for i := 1; i <= 11; i++ {
+		rows = append(rows, metrow.MetRow{Time: int64(i), WSPDmS: f64(float64(i * 10))})
+	}
+	// Nulls must not shift the ranks.
+	rows = append(rows, metrow.MetRow{Time: 12}, metrow.MetRow{Time: 13})
+
+	got := percentiles(rows, c, []float64{0, 50, 90, 95, 100})
+	want := map[string]float64{"p0": 10, "p50": 60, "p90": 100, "p95": 105, "p100": 110}
+	for k, w := range want {
+		if got[k] == nil || *got[k] != w {
+			t.Errorf("%s = %v, want %g", k, got[k], w)
+		}
+	}
+
+	if got := percentiles([]metrow.MetRow{{Time: 1}}, c, []float64{50}); got["p50"] != nil {
+		t.Errorf("all-null p50 = %v, want null", *got["p50"])
+	}
+}
+
+func TestSummaryPercentilesValidation(t *testing.T) {
+	dir := useDataDir(t)
+	writeStation(t, dir, "SANF1", []metrow.MetRow{
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: f64(5)},
+		{StationID: "SANF1", Time: 1718003600, WSPDmS: f64(7)},
+	})
+	for _, q := range []string{"NaN", "Inf", "-Inf", "-1", "101", "50,x"} {
+		w := httptest.NewRecorder()
+		summaryHandler(w, httptest.NewRequest(http.MethodGet, "/summary?station=SANF1&field=wspd_ms&percentiles="+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("percentiles=%s: status %d, want 400", q, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	summaryHandler(w, httptest.NewRequest(http.MethodGet, "/summary?station=SANF1&field=wspd_ms&percentiles=50", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var st fieldStats
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Count != 2 || st.Percentiles["p50"] == nil || *st.Percentiles["p50"] != 6 {
+		t.Errorf("summary = %+v", st)
+	}
+}
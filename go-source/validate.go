@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// runValidate checks one parquet file against the expected schema and
+// prints its row count, time range, and per-column null counts. It returns
+// the process exit code: 0 when the file is usable, 1 otherwise.
+func runValidate(path string, cols []metrow.Column) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "validate: FILE is required")
+		return 2
+	}
+	fmt.Printf("file:    %s\n", path)
+
+	problems, err := schemaMismatches(path, cols)
+	if err != nil {
+		fmt.Printf("error:   %v\n", err)
+		return 1
+	}
+	for _, p := range problems {
+		fmt.Printf("schema:  %s\n", p)
+	}
+
+	rows, err := readParquet(path)
+	if err != nil {
+		fmt.Printf("error:   read: %v\n", err)
+		return 1
+	}
+	fmt.Printf("rows:    %d\n", len(rows))
+	if len(rows) > 0 {
+		lo, hi := rows[0].Time, rows[0].Time
+		for _, r := range rows[1:] {
+			lo = min(lo, r.Time)
+			hi = max(hi, r.Time)
+		}
+		fmt.Printf("time:    %s .. %s\n",
+			time.Unix(lo, 0).UTC().Format(time.RFC3339), time.Unix(hi, 0).UTC().Format(time.RFC3339))
+	}
+
+	fmt.Println("nulls:")
+	for _, c := range cols {
+		if !c.Nullable() {
+			continue
+		}
+		n := 0
+		for i := range rows {
+			if c.IsNull(&rows[i]) {
+				n++
+			}
+		}
+		fmt.Printf("  %-12s %d/%d\n", c.Name, n, len(rows))
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("FAIL: %d schema problem(s)\n", len(problems))
+		return 1
+	}
+	fmt.Println("OK")
+	return 0
+}
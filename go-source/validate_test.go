@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "SANF1_latest.parquet")
+	writeParquetFile(t, good, hourlyRows("SANF1", 3))
+	bad := filepath.Join(dir, "OLDF1_latest.parquet")
+	writeParquetFile(t, bad, []noPresRow{{StationID: "OLDF1", Time: 1718000000}})
+
+	cols := metrow.DefaultColumns()
+	tests := []struct {
+		name, path string
+		want       int
+	}{
+		{"valid", good, 0},
+		{"missing column", bad, 1},
+		{"unreadable", filepath.Join(dir, "nope.parquet"), 1},
+		{"no FILE", "", 2},
+	}
+	for _, tt := range tests {
+		if got := runValidate(tt.path, cols); got != tt.want {
+			t.Errorf("%s: exit %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// wrongTypeRow stores wdir_deg as text, which MetRow cannot read.
+type wrongTypeRow struct {
+	StationID string `parquet:"station_id"`
+	Time      int64  `parquet:"time"`
+	WDIRDeg   string `parquet:"wdir_deg"`
+}
+
+func TestValidateAll(t *testing.T) {
+	dir := useDataDir(t)
+	t.Setenv("FILENAME_TEMPLATE", "{station}_{date}.parquet")
+	writeParquetFile(t, filepath.Join(dir, "SANF1_20240610.parquet"), hourlyRows("SANF1", 3))
+	// A missing measurement column reads as null, so it is compatible.
+	writeParquetFile(t, filepath.Join(dir, "OLDF1_20240610.parquet"), []noPresRow{{StationID: "OLDF1", Time: 1718000000}})
+	writeParquetFile(t, filepath.Join(dir, "SANF1_20240611.parquet"), []wrongTypeRow{{StationID: "SANF1", Time: 1718000000, WDIRDeg: "N"}})
+	if err := os.WriteFile(filepath.Join(dir, "SMKF1_20240611.parquet"), []byte("not parquet"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	validateAllHandler(rec, httptest.NewRequest(http.MethodGet, "/validate-all", nil))
+	var got validateAllResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Checked != 4 || len(got.Incompatible) != 2 {
+		t.Fatalf("/validate-all = %+v, want 4 checked and 2 incompatible", got)
+	}
+	bad := got.Incompatible[0]
+	if filepath.Base(bad.Path) != "SANF1_20240611.parquet" || len(bad.Problems) != 1 || !strings.Contains(bad.Problems[0], `"wdir_deg"`) {
+		t.Errorf("wrong-type file reported as %+v", bad)
+	}
+	if filepath.Base(got.Incompatible[1].Path) != "SMKF1_20240611.parquet" {
+		t.Errorf("garbage file reported as %+v", got.Incompatible[1])
+	}
+}
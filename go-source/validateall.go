@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// fileProblems is one incompatible file in the /validate-all report.
+type fileProblems struct {
+	Path     string   `json:"path"`
+	Problems []string `json:"problems"`
+}
+
+type validateAllResponse struct {
+	Checked      int            `json:"checked"`
+	Incompatible []fileProblems `json:"incompatible"`
+}
+
+// incompatibilities lists what would stop the parquet file at path from
+// being read back into MetRow: an unreadable file, a missing station_id or
+// time, or a known column of the wrong type or nullability. Unlike
+// schemaMismatches it ignores the COLUMNS selection: missing measurement
+// columns read as null and unknown ones are skipped, so neither is a
+// problem for an archive.
+func incompatibilities(path string, want map[string]parquet.Field) []string {
+	pf, f, err := openParquetFile(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer f.Close()
+
+	var problems []string
+	have := make(map[string]bool)
+	for _, got := range pf.Schema().Fields() {
+		have[got.Name()] = true
+		w, ok := want[got.Name()]
+		if !ok {
+			continue
+		}
+		if !compatibleKinds(got.Type().Kind(), w.Type().Kind()) {
+			problems = append(problems, fmt.Sprintf("column %q is %s, want %s", got.Name(), got.Type(), w.Type()))
+		}
+		if got.Optional() && !w.Optional() {
+			problems = append(problems, fmt.Sprintf("column %q is optional, want required", got.Name()))
+		}
+	}
+	for _, name := range []string{"station_id", "time"} {
+		if !have[name] {
+			problems = append(problems, fmt.Sprintf("missing column %q", name))
+		}
+	}
+	return problems
+}
+
+// validateAllHandler serves GET /validate-all: it checks every station
+// file in DATA_DIR, history shards included, against the MetRow schema and
+// lists the ones /stream could not read, instead of failing part-way
+// through a stream.
+func validateAllHandler(w http.ResponseWriter, _ *http.Request) {
+	want := make(map[string]parquet.Field)
+	for _, f := range metrow.ParquetSchema(metrow.Columns).Fields() {
+		want[f.Name()] = f
+	}
+	resp := validateAllResponse{Incompatible: []fileProblems{}}
+	for _, g := range findShards(dataDirs(), filenameTemplate()) {
+		for _, p := range g.Paths {
+			resp.Checked++
+			if problems := incompatibilities(p, want); len(problems) > 0 {
+				resp.Incompatible = append(resp.Incompatible, fileProblems{Path: p, Problems: problems})
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("ERROR encode /validate-all: %v", err)
+	}
+}
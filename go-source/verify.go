@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// maxVerifyDiffs caps how many differing rows are printed per station.
+const maxVerifyDiffs = 5
+
+// readStream fetches an Arrow IPC stream from url and decodes it into rows
+// grouped by station. It also returns the columns the stream carried.
+func readStream(url string) (map[string][]metrow.MetRow, []metrow.Column, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token := metrow.Getenv("AUTH_TOKEN", ""); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("GET %s: HTTP %d", url, resp.StatusCode)
+	}
+
+	rdr, err := ipc.NewReader(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ipc reader: %w", err)
+	}
+	defer rdr.Release()
+	if err := metrow.CheckRecordSchema(rdr.Schema()); err != nil {
+		return nil, nil, fmt.Errorf("stream schema: %w", err)
+	}
+	var cols []metrow.Column
+	for _, f := range rdr.Schema().Fields() {
+		if c, ok := metrow.LookupColumn(f.Name); ok {
+			cols = append(cols, c)
+		}
+	}
+
+	byStation := make(map[string][]metrow.MetRow)
+	for rdr.Next() {
+		rows, err := metrow.RecordToRows(rdr.Record())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rows {
+			byStation[r.StationID] = append(byStation[r.StationID], r)
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, nil, fmt.Errorf("ipc read: %w", err)
+	}
+	return byStation, cols, nil
+}
+
+// rowDiff describes how got differs from want in station_id, time, and
+// cols, or returns "" when they match.
+func rowDiff(got, want *metrow.MetRow, cols []metrow.Column) string {
+	if got.StationID != want.StationID || got.Time != want.Time {
+		return fmt.Sprintf("key %s@%d, want %s@%d", got.StationID, got.Time, want.StationID, want.Time)
+	}
+	for _, c := range cols {
+		if c.Str != nil {
+			g, w := c.Str(got), c.Str(want)
+			if (g == nil) != (w == nil) || (g != nil && *g != *w) {
+				return fmt.Sprintf("%s: %s = %s, want %s", time.Unix(got.Time, 0).UTC().Format(time.RFC3339), c.Name, fmtPtr(g), fmtPtr(w))
+			}
+			continue
+		}
+		g, gok := c.Float(got)
+		w, wok := c.Float(want)
+		if gok != wok || g != w {
+			return fmt.Sprintf("%s: %s = %s, want %s", time.Unix(got.Time, 0).UTC().Format(time.RFC3339), c.Name, fmtValue(g, gok), fmtValue(w, wok))
+		}
+	}
+	return ""
+}
+
+func fmtPtr(p *string) string {
+	if p == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", *p)
+}
+
+func fmtValue(v float64, ok bool) string {
+	if !ok {
+		return "null"
+	}
+	return fmtFloat(v)
+}
+
+// runVerify is the round-trip self-test (MODE=verify): it decodes the
+// Arrow stream at url back into rows and diffs them, station by station,
+// against the parquet files in DATA_DIR. It returns the process exit code:
+// 0 when every station matches, 1 otherwise.
+func runVerify(url string) int {
+	fmt.Printf("stream:  %s\n", url)
+	got, cols, err := readStream(url)
+	if err != nil {
+		fmt.Printf("error:   %v\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, g := range findShards(dataDirs(), filenameTemplate()) {
+		all, err := readShards(g)
+		if err != nil {
+			fmt.Printf("error:   %s: read: %v\n", g.Station, err)
+			failed++
+			continue
+		}
+		byStation := splitStations(g, all)
+		stations := make([]string, 0, len(byStation))
+		for st := range byStation {
+			stations = append(stations, st)
+		}
+		sort.Strings(stations)
+		for _, st := range stations {
+			if !verifyStation(st, got[st], byStation[st], cols) {
+				failed++
+			}
+			delete(got, st)
+		}
+	}
+	for station, rows := range got {
+		fmt.Printf("FAIL     %s: %d rows streamed, no parquet file\n", station, len(rows))
+		failed++
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL: %d station(s) differ\n", failed)
+		return 1
+	}
+	fmt.Println("OK")
+	return 0
+}
+
+// verifyStation diffs the rows streamed for station against those read
+// from its parquet files, printing the outcome, and reports whether they
+// match.
+func verifyStation(station string, rows, want []metrow.MetRow, cols []metrow.Column) bool {
+	if len(want) == 0 && len(rows) == 0 {
+		return true
+	}
+	if len(rows) != len(want) {
+		fmt.Printf("FAIL     %s: %d rows streamed, %d in parquet\n", station, len(rows), len(want))
+		return false
+	}
+	var diffs []string
+	for i := range rows {
+		if d := rowDiff(&rows[i], &want[i], cols); d != "" {
+			diffs = append(diffs, d)
+		}
+	}
+	if len(diffs) == 0 {
+		fmt.Printf("ok       %s: %d rows\n", station, len(rows))
+		return true
+	}
+	fmt.Printf("FAIL     %s: %d of %d rows differ\n", station, len(diffs), len(rows))
+	for _, d := range diffs[:min(len(diffs), maxVerifyDiffs)] {
+		fmt.Printf("           %s\n", d)
+	}
+	return false
+}
+
+// verifyURL returns STREAM_URL, defaulting to this instance's /stream.
+func verifyURL() string {
+	return metrow.Getenv("STREAM_URL", "http://localhost:"+metrow.Getenv("ARROW_PORT", "8080")+"/stream")
+}
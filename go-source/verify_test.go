@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	dir := useDataDir(t)
+	t.Setenv("COLUMNS", "wspd_ms,qc_flags,wdir_card")
+	writeStation(t, dir, "SANF1", hourlyRows("SANF1", 3))
+
+	srv := httptest.NewServer(http.HandlerFunc(streamHandler))
+	defer srv.Close()
+	if code := runVerify(srv.URL); code != 0 {
+		t.Errorf("verify against /stream exited %d, want 0", code)
+	}
+
+	// A stream that disagrees with the parquet file fails the check.
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cols := selectedColumns()
+		schema := metrow.SchemaFor(cols)
+		rows := hourlyRows("SANF1", 3)
+		*rows[1].WSPDmS = 42
+		rec := rowsToRecord(memory.DefaultAllocator, schema, cols, rows)
+		defer rec.Release()
+		wr := ipc.NewWriter(w, ipc.WithSchema(schema))
+		wr.Write(rec)
+		wr.Close()
+	}))
+	defer bad.Close()
+	if code := runVerify(bad.URL); code != 1 {
+		t.Errorf("verify against a differing stream exited %d, want 1", code)
+	}
+}
@@ -0,0 +1,41 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeFiresWaitersInOrder(t *testing.T) {
+	start := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	soon, later := f.After(time.Minute), f.After(time.Hour)
+	if f.Waiters() != 2 {
+		t.Fatalf("Waiters = %d, want 2", f.Waiters())
+	}
+
+	f.Advance(30 * time.Second)
+	select {
+	case <-soon:
+		t.Fatal("1m timer fired after 30s")
+	default:
+	}
+	f.Advance(30 * time.Second)
+	if got := <-soon; !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("1m timer fired at %s", got)
+	}
+	if f.Waiters() != 1 {
+		t.Errorf("Waiters = %d after the first fired, want 1", f.Waiters())
+	}
+
+	f.Set(start.Add(2 * time.Hour))
+	<-later
+	if !f.Now().Equal(start.Add(2*time.Hour)) || f.Waiters() != 0 {
+		t.Errorf("Now %s, %d waiters", f.Now(), f.Waiters())
+	}
+
+	select {
+	case <-f.After(0):
+	default:
+		t.Error("After(0) did not fire immediately")
+	}
+}
@@ -0,0 +1,139 @@
+package metrow
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Settings are looked up by their env var name (e.g. STATIONS) in three
+// layers: command-line flags override environment variables, which override
+// the CONFIG_FILE, which overrides the caller's default.
+var (
+	fileSettings = map[string]string{}
+	flagSettings = map[string]string{}
+)
+
+// Getenv returns setting key from the flag, env, or config-file layer, or
+// def when none sets it to a non-empty value.
+func Getenv(key, def string) string {
+	if v := flagSettings[key]; v != "" {
+		return v
+	}
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	if v := fileSettings[key]; v != "" {
+		return v
+	}
+	return def
+}
+
+// LoadSettings reads CONFIG_FILE (if set) and parses args (typically
+// os.Args[1:]) as flags, one per known key: STATIONS becomes -stations,
+// DATA_DIR -data-dir. Unknown keys in the file and unknown flags are errors.
+func LoadSettings(name string, known []string, args []string) error {
+	isKnown := make(map[string]bool, len(known))
+	for _, k := range known {
+		isKnown[k] = true
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		vals, err := parseConfigFile(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		var unknown []string
+		for k := range vals {
+			if !isKnown[k] {
+				unknown = append(unknown, strings.ToLower(k))
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("%s: unknown keys: %s", path, strings.Join(unknown, ", "))
+		}
+		fileSettings = vals
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	for _, k := range known {
+		k := k
+		fs.Func(flagName(k), "overrides "+k, func(v string) error {
+			flagSettings[k] = v
+			return nil
+		})
+	}
+	return fs.Parse(args)
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// parseConfigFile reads the flat subset of YAML and TOML the services need:
+// one "key: value" or "key = value" per line, # comments, optional quotes,
+// and ["a", "b"] or [a, b] lists (joined with commas). Keys are matched
+// case-insensitively against env var names, with '-' treated as '_'.
+func parseConfigFile(b []byte) (map[string]string, error) {
+	out := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+		i := strings.IndexAny(line, ":=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: want key: value or key = value", n)
+		}
+		key := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(line[:i]), "-", "_"))
+		val := strings.TrimSpace(line[i+1:])
+		if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+			var items []string
+			for _, it := range strings.Split(val[1:len(val)-1], ",") {
+				if it = unquote(strings.TrimSpace(it)); it != "" {
+					items = append(items, it)
+				}
+			}
+			val = strings.Join(items, ",")
+		} else {
+			val = unquote(val)
+		}
+		out[key] = val
+	}
+	return out, sc.Err()
+}
+
+// stripComment drops a # comment that is not inside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package metrow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resetSettings clears the flag and config-file layers after the test.
+func resetSettings(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		fileSettings = map[string]string{}
+		flagSettings = map[string]string{}
+	})
+}
+
+func TestParseConfigFile(t *testing.T) {
+	yaml := `---
+# go-ingest
+stations: [SANF1, "SMKF1", 'LONF1']
+data-dir: "/data # not a comment"
+Refresh_Minutes: 30  # trailing comment
+`
+	toml := `stations = ["SANF1", "SMKF1", "LONF1"]
+data_dir = "/data # not a comment"
+refresh_minutes = 30
+`
+	want := map[string]string{"STATIONS": "SANF1,SMKF1,LONF1", "DATA_DIR": "/data # not a comment", "REFRESH_MINUTES": "30"}
+	for name, src := range map[string]string{"yaml": yaml, "toml": toml} {
+		got, err := parseConfigFile([]byte(src))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", name, k, got[k], v)
+			}
+		}
+	}
+	if _, err := parseConfigFile([]byte("stations\n")); err == nil {
+		t.Error("line without a separator accepted")
+	}
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	resetSettings(t)
+	cfg := filepath.Join(t.TempDir(), "ingest.yaml")
+	if err := os.WriteFile(cfg, []byte("stations: [FILE1]\ndata_dir: /file\nstorage: local\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", cfg)
+	t.Setenv("STATIONS", "ENV1")
+	t.Setenv("DATA_DIR", "/env")
+	t.Setenv("STORAGE", "")
+	known := []string{"STATIONS", "DATA_DIR", "STORAGE", "COLUMNS"}
+	if err := LoadSettings("test", known, []string{"-stations", "FLAG1"}); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"STATIONS": "FLAG1",   // flag beats env and file
+		"DATA_DIR": "/env",    // env beats file
+		"STORAGE":  "local",   // file beats default
+		"COLUMNS":  "default", // nothing set
+	} {
+		if got := Getenv(key, "default"); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadSettingsRejectsUnknown(t *testing.T) {
+	resetSettings(t)
+	cfg := filepath.Join(t.TempDir(), "bad.toml")
+	if err := os.WriteFile(cfg, []byte("stations = SANF1\nstatoins = SMKF1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", cfg)
+	err := LoadSettings("test", []string{"STATIONS"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "statoins") {
+		t.Errorf("unknown file key: err = %v", err)
+	}
+
+	t.Setenv("CONFIG_FILE", "")
+	if err := LoadSettings("test", []string{"STATIONS"}, []string{"-data-dir", "/x"}); err == nil {
+		t.Error("unknown flag accepted")
+	}
+}
@@ -0,0 +1,68 @@
+package metrow
+
+import (
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+)
+
+// DartDataset is the {dataset} value for DART water-column files.
+const DartDataset = "dart"
+
+// DefaultDartFilenameTemplate is the DART file name used when
+// DART_FILENAME_TEMPLATE is unset.
+const DefaultDartFilenameTemplate = "{station}_{dataset}.parquet"
+
+// DartRow is one DART (tsunameter) water-column height reading.
+type DartRow struct {
+	StationID string `parquet:"station_id" json:"station_id"`
+	Time      int64  `parquet:"time" json:"time"`
+	// HeightM is the water-column height in metres; nil when missing.
+	HeightM *float64 `parquet:"height_m" json:"height_m"`
+	// Type is the NDBC measurement type: 1 = 15-minute, 2 = 1-minute,
+	// 3 = 15-second; 0 when the row carries none.
+	Type int32 `parquet:"type" json:"type"`
+	// Event is set for the 1-minute and 15-second readings the station
+	// only reports while in tsunami event mode.
+	Event bool `parquet:"event" json:"event"`
+}
+
+// DartSchema returns the Arrow schema matching DartRow.
+func DartSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "station_id", Type: arrow.BinaryTypes.String,
+			Metadata: fieldMetadata("", "NDBC station ID")},
+		{Name: "time", Type: TimestampType,
+			Metadata: fieldMetadata("s", "observation time (UTC)")},
+		{Name: "height_m", Type: arrow.PrimitiveTypes.Float64, Nullable: true,
+			Metadata: fieldMetadata("m", "water column height")},
+		{Name: "type", Type: arrow.PrimitiveTypes.Int32,
+			Metadata: fieldMetadata("", "measurement type: 1 = 15-min, 2 = 1-min, 3 = 15-s")},
+		{Name: "event", Type: arrow.FixedWidthTypes.Boolean,
+			Metadata: fieldMetadata("", "reported in tsunami event mode")},
+	}, nil)
+}
+
+// DartRecord builds one Arrow record with DartSchema from rows.
+func DartRecord(mem memory.Allocator, rows []DartRow) arrow.Record {
+	b := array.NewRecordBuilder(mem, DartSchema())
+	defer b.Release()
+
+	sb := b.Field(0).(*array.StringBuilder)
+	tb := b.Field(1).(*array.TimestampBuilder)
+	hb := b.Field(2).(*array.Float64Builder)
+	yb := b.Field(3).(*array.Int32Builder)
+	eb := b.Field(4).(*array.BooleanBuilder)
+	for _, r := range rows {
+		sb.Append(r.StationID)
+		tb.Append(arrow.Timestamp(r.Time))
+		if r.HeightM != nil {
+			hb.Append(*r.HeightM)
+		} else {
+			hb.AppendNull()
+		}
+		yb.Append(r.Type)
+		eb.Append(r.Event)
+	}
+	return b.NewRecord()
+}
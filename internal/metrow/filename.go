@@ -0,0 +1,158 @@
+package metrow
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultFilenameTemplate is the station file name used when
+// FILENAME_TEMPLATE is unset.
+const DefaultFilenameTemplate = "{station}_latest.parquet"
+
+// Dataset is the NDBC product the {dataset} placeholder expands to.
+const Dataset = "stdmet"
+
+// FilenameTemplate names station files. go-ingest expands it to write a
+// file and go-source uses it to find files and recover their station.
+//
+// Placeholders: {station} (upper-case station ID, required), {date} (UTC
+// write date, YYYYMMDD) and {dataset}.
+type FilenameTemplate struct {
+	raw     string
+	re      *regexp.Regexp
+	dataset string
+}
+
+var placeholderRE = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ParseFilenameTemplate validates s; an empty s yields the default.
+func ParseFilenameTemplate(s string) (FilenameTemplate, error) {
+	if s == "" {
+		s = DefaultFilenameTemplate
+	}
+	return parseTemplate(s, Dataset)
+}
+
+// ForDataset returns t with {dataset} standing for name instead of Dataset.
+func (t FilenameTemplate) ForDataset(name string) FilenameTemplate {
+	nt, err := parseTemplate(t.raw, name)
+	if err != nil {
+		panic(err) // t.raw already parsed
+	}
+	return nt
+}
+
+func parseTemplate(s, dataset string) (FilenameTemplate, error) {
+	if strings.Contains(s, "/") {
+		return FilenameTemplate{}, fmt.Errorf("filename template %q must not contain '/'", s)
+	}
+	if !strings.Contains(s, "{station}") {
+		return FilenameTemplate{}, fmt.Errorf("filename template %q has no {station}", s)
+	}
+
+	var pat strings.Builder
+	pat.WriteString("^")
+	last := 0
+	for _, loc := range placeholderRE.FindAllStringIndex(s, -1) {
+		pat.WriteString(regexp.QuoteMeta(s[last:loc[0]]))
+		switch ph := s[loc[0]:loc[1]]; ph {
+		case "{station}":
+			pat.WriteString(`(?P<station>[^/]+?)`)
+		case "{date}":
+			pat.WriteString(`\d{8}`)
+		case "{dataset}":
+			pat.WriteString(regexp.QuoteMeta(dataset))
+		default:
+			return FilenameTemplate{}, fmt.Errorf("filename template %q: unknown placeholder %s", s, ph)
+		}
+		last = loc[1]
+	}
+	pat.WriteString(regexp.QuoteMeta(s[last:]))
+	pat.WriteString("$")
+	return FilenameTemplate{raw: s, re: regexp.MustCompile(pat.String()), dataset: dataset}, nil
+}
+
+func (t FilenameTemplate) String() string { return t.raw }
+
+// CombinedStation is what {station} stands for in the name of the single
+// multi-station file go-ingest writes with COMBINED_OUTPUT, e.g.
+// all_latest.parquet. Its rows carry their own station_id.
+const CombinedStation = "all"
+
+// Expand returns the file name for station written at now.
+func (t FilenameTemplate) Expand(station string, now time.Time) string {
+	return t.expand(strings.ToUpper(station), now)
+}
+
+// ExpandCombined returns the name of the combined multi-station file
+// written at now.
+func (t FilenameTemplate) ExpandCombined(now time.Time) string {
+	return t.expand(CombinedStation, now)
+}
+
+func (t FilenameTemplate) expand(station string, now time.Time) string {
+	return strings.NewReplacer(
+		"{station}", station,
+		"{date}", now.UTC().Format("20060102"),
+		"{dataset}", t.dataset,
+	).Replace(t.raw)
+}
+
+// Glob returns a filepath.Match pattern matching every expansion.
+func (t FilenameTemplate) Glob() string {
+	return placeholderRE.ReplaceAllString(strings.ReplaceAll(t.raw, "{dataset}", t.dataset), "*")
+}
+
+// Station returns the station a file (base name or path) was written for,
+// and false when the name does not match the template.
+func (t FilenameTemplate) Station(name string) (string, bool) {
+	m := t.re.FindStringSubmatch(path.Base(name))
+	if m == nil {
+		return "", false
+	}
+	return m[t.re.SubexpIndex("station")], true
+}
+
+// ManifestName is the file go-ingest writes next to the station files
+// after every cycle.
+const ManifestName = "manifest.json"
+
+// Manifest lists the station files written in one go-ingest cycle.
+// BatchID is the cycle's UUID, as stamped (BatchIDKey) into the files it
+// wrote.
+type Manifest struct {
+	Cycle   time.Time       `json:"cycle"`
+	BatchID string          `json:"batch_id,omitempty"`
+	Files   []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one station file in a Manifest. Path is relative
+// to the manifest's directory.
+type ManifestEntry struct {
+	Station string    `json:"station"`
+	Path    string    `json:"path"`
+	Rows    int       `json:"rows"`
+	Newest  time.Time `json:"newest"`
+}
+
+// FetchStatusName is the file go-ingest keeps next to the station files
+// with every station's latest fetch attempt and success, across cycles
+// and restarts.
+const FetchStatusName = "fetch_status.json"
+
+// FetchStatus records when a station was last fetched and when a fetch last
+// succeeded. LastError is the error of the latest attempt, if it failed.
+type FetchStatus struct {
+	LastAttempt time.Time  `json:"last_attempt"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Stale reports whether the latest attempt failed, so the station's file
+// holds data from an earlier fetch (or there is none).
+func (s FetchStatus) Stale() bool {
+	return s.LastSuccess == nil || s.LastSuccess.Before(s.LastAttempt)
+}
@@ -0,0 +1,80 @@
+package metrow
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilenameTemplate(t *testing.T) {
+	now := time.Date(2024, 6, 10, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	tests := []struct {
+		raw, expand, glob string
+	}{
+		{"", "SANF1_latest.parquet", "*_latest.parquet"},
+		{"{dataset}/{station}.parquet", "", ""},
+		{"{station}_{date}.parquet", "SANF1_20240611.parquet", "*_*.parquet"},
+		{"{dataset}-{station}.parquet", "stdmet-SANF1.parquet", "stdmet-*.parquet"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseFilenameTemplate(tt.raw)
+		if tt.expand == "" {
+			if err == nil {
+				t.Errorf("%q: want error", tt.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.raw, err)
+		}
+		name := tmpl.Expand("sanf1", now)
+		if name != tt.expand {
+			t.Errorf("%q: Expand = %q, want %q", tt.raw, name, tt.expand)
+		}
+		if got := tmpl.Glob(); got != tt.glob {
+			t.Errorf("%q: Glob = %q, want %q", tt.raw, got, tt.glob)
+		}
+		if ok, _ := filepath.Match(tmpl.Glob(), name); !ok {
+			t.Errorf("%q: Glob does not match %q", tt.raw, name)
+		}
+		if st, ok := tmpl.Station("/data/" + name); !ok || st != "SANF1" {
+			t.Errorf("%q: Station(%q) = %q, %v", tt.raw, name, st, ok)
+		}
+		if _, ok := tmpl.Station("notes.txt"); ok {
+			t.Errorf("%q: Station matched an unrelated file", tt.raw)
+		}
+	}
+}
+
+func TestFilenameTemplateErrors(t *testing.T) {
+	for _, raw := range []string{"latest.parquet", "{station}/{date}.parquet", "{station}_{hour}.parquet"} {
+		if _, err := ParseFilenameTemplate(raw); err == nil {
+			t.Errorf("%q: want error", raw)
+		}
+	}
+}
+
+func TestFilenameTemplateForDataset(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	stdmet, err := ParseFilenameTemplate("{station}_{dataset}.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dart := stdmet.ForDataset(DartDataset)
+	if got := dart.Expand("21413", now); got != "21413_dart.parquet" {
+		t.Errorf("Expand = %q", got)
+	}
+	if got := dart.Glob(); got != "*_dart.parquet" {
+		t.Errorf("Glob = %q", got)
+	}
+	// Each template only claims its own dataset's files.
+	if _, ok := dart.Station("SANF1_stdmet.parquet"); ok {
+		t.Error("DART template matched a stdmet file")
+	}
+	if _, ok := stdmet.Station("21413_dart.parquet"); ok {
+		t.Error("stdmet template matched a DART file")
+	}
+	if st, ok := dart.Station("/data/21413_dart.parquet"); !ok || st != "21413" {
+		t.Errorf("Station = %q, %v", st, ok)
+	}
+}
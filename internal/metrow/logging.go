@@ -0,0 +1,76 @@
+package metrow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logPrefixes maps the first word of a standard-logger line to a level.
+// Level words (INFO, WARN, ...) are stripped from the message; the action
+// words (WROTE, SENT, ...) stay, since they say what happened.
+var logPrefixes = map[string]struct {
+	level slog.Level
+	strip bool
+}{
+	"DEBUG":     {slog.LevelDebug, true},
+	"INFO":      {slog.LevelInfo, true},
+	"WARN":      {slog.LevelWarn, true},
+	"ERROR":     {slog.LevelError, true},
+	"WROTE":     {slog.LevelInfo, false},
+	"SENT":      {slog.LevelInfo, false},
+	"UNCHANGED": {slog.LevelInfo, false},
+	"SKIP":      {slog.LevelWarn, false},
+}
+
+// ParseLogLevel parses debug, info, warn (or warning), or error.
+func ParseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown level %q (want debug, info, warn, or error)", s)
+}
+
+// SetupLogging routes the standard logger through a slog text handler on
+// stderr that drops lines below LOG_LEVEL (default info). Each line's
+// prefix picks its level; unprefixed lines are info. An invalid LOG_LEVEL
+// is reported and info is used.
+func SetupLogging() error {
+	level, err := ParseLogLevel(Getenv("LOG_LEVEL", "info"))
+	h := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	log.SetFlags(0)
+	log.SetOutput(levelWriter{slog.New(h)})
+	return err
+}
+
+// levelWriter turns each line the standard logger writes into a slog
+// record at the level its prefix names.
+type levelWriter struct{ l *slog.Logger }
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	level, msg := lineLevel(strings.TrimRight(string(p), "\n"))
+	w.l.Log(context.Background(), level, msg)
+	return len(p), nil
+}
+
+func lineLevel(line string) (slog.Level, string) {
+	word, rest, _ := strings.Cut(line, " ")
+	p, ok := logPrefixes[word]
+	if !ok {
+		return slog.LevelInfo, line
+	}
+	if p.strip {
+		return p.level, strings.TrimLeft(rest, " ")
+	}
+	return p.level, line
+}
@@ -0,0 +1,50 @@
+package metrow
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"": slog.LevelInfo, "debug": slog.LevelDebug, " WARNING ": slog.LevelWarn, "Error": slog.LevelError,
+	} {
+		if got, err := ParseLogLevel(in); err != nil || got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLogLevel("loud"); err == nil {
+		t.Error("ParseLogLevel(loud) accepted")
+	}
+}
+
+func TestLevelWriterFilters(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})
+	l := log.New(levelWriter{slog.New(h)}, "", 0)
+	l.Printf("INFO  SANF1: 48 rows")
+	l.Printf("WROTE SANF1_latest.parquet (48 rows)")
+	l.Printf("Starting go-ingest")
+	l.Printf("WARN  SANF1: slow response")
+	l.Printf("SKIP  BAD01: not found")
+	l.Printf("ERROR storage: denied")
+
+	out := buf.String()
+	for _, dropped := range []string{"48 rows", "Starting"} {
+		if strings.Contains(out, dropped) {
+			t.Errorf("info line %q logged at LOG_LEVEL=warn:\n%s", dropped, out)
+		}
+	}
+	for _, want := range []string{
+		`level=WARN msg="SANF1: slow response"`,
+		`level=WARN msg="SKIP  BAD01: not found"`,
+		`level=ERROR msg="storage: denied"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %s:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Package metrow defines the observation row shared by go-ingest and
+// go-source: the Parquet struct, the selectable measurement columns, the
+// authoritative Arrow schema, and the settings lookup both binaries use.
+package metrow
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+)
+
+// MetRow is the schema for each observation row written to Parquet by
+// go-ingest and read back by go-source. The JSON tags deliberately omit
+// omitempty: a nil measurement encodes as null, so a genuine zero (e.g. a
+// north wind, WDIRDeg=0) is never confused with a missing value.
+type MetRow struct {
+	StationID string   `parquet:"station_id" json:"station_id"`
+	Time      int64    `parquet:"time" json:"time"`
+	WDIRDeg   *int32   `parquet:"wdir_deg" json:"wdir_deg"`
+	WSPDmS    *float64 `parquet:"wspd_ms" json:"wspd_ms"`
+	GUSTmS    *float64 `parquet:"gust_ms" json:"gust_ms"`
+	PREShPa   *float64 `parquet:"pres_hpa" json:"pres_hpa"`
+	ATMPC     *float64 `parquet:"atmp_c" json:"atmp_c"`
+	WTMPC     *float64 `parquet:"wtmp_c" json:"wtmp_c"`
+	DEWPC     *float64 `parquet:"dewp_c" json:"dewp_c"`
+	// MWDDeg is the mean wave direction (from, degrees true), for stations
+	// that report waves.
+	MWDDeg  *int32 `parquet:"mwd_deg" json:"mwd_deg"`
+	QCFlags uint32 `parquet:"qc_flags" json:"qc_flags"`
+	// Derived columns.
+	WindChillC *float64 `parquet:"windchill_c" json:"windchill_c"`
+	// SolarTime is local mean solar time at the station (epoch seconds of
+	// the wall-clock reading, no zone); nil when the longitude is unknown.
+	SolarTime *int64 `parquet:"solar_time" json:"solar_time"`
+	// WDIRCardinal is WDIRDeg as a 16-point compass label ("N", "NNE", ...).
+	WDIRCardinal *string `parquet:"wdir_card" json:"wdir_card"`
+	// TimeISO repeats Time as an RFC 3339 UTC string for consumers that
+	// cannot handle epoch integers; only set when requested (TIME_ISO).
+	TimeISO *string `parquet:"time_iso" json:"time_iso"`
+}
+
+// UnitsKey is the Parquet key/value metadata key holding the NDBC units line
+// as a JSON object mapping NDBC column names to units (e.g. "WSPD": "m/s").
+const UnitsKey = "ndbc_units"
+
+// ContentHashKey is the Parquet key/value metadata key holding the SHA-256
+// of a station file's logical content, used by go-ingest to skip rewriting
+// unchanged files.
+const ContentHashKey = "content_sha256"
+
+// SortedKey is the Parquet key/value metadata key go-ingest sets to "time"
+// when a file's rows are in ascending time order.
+const SortedKey = "sorted_by"
+
+// Provenance keys: Parquet key/value metadata recording the NDBC URL a
+// station file was fetched from, when (RFC 3339 UTC), the go-ingest
+// Version that wrote it, and the UUID of the ingest cycle (batch) that
+// wrote it. They change with every fetch, so they are left out of the
+// content hash.
+const (
+	SourceURLKey   = "source_url"
+	FetchedAtKey   = "fetched_at"
+	ToolVersionKey = "tool_version"
+	BatchIDKey     = "batch_id"
+)
+
+// ProvenanceKeys lists the provenance metadata keys.
+var ProvenanceKeys = []string{SourceURLKey, FetchedAtKey, ToolVersionKey, BatchIDKey}
+
+// Version identifies the build, recorded as ToolVersionKey. Release builds
+// set it with -ldflags "-X example.com/arrow-buoys/internal/metrow.Version=v1.2.3".
+var Version = "dev"
+
+// TimestampType is the Arrow type of the time column (epoch seconds, UTC).
+var TimestampType = &arrow.TimestampType{Unit: arrow.Second, TimeZone: "UTC"}
+
+// LocalTimestampType is the Arrow type of zone-less local timestamps such
+// as solar_time: the value is a wall-clock reading, not an instant.
+var LocalTimestampType = &arrow.TimestampType{Unit: arrow.Second}
+
+// Column describes one selectable column of MetRow (everything except
+// station_id and time, which are always present). Exactly one accessor is
+// set and it determines the column's type; TS columns are local timestamps.
+// OptIn columns are left out unless named explicitly (see SelectColumns).
+// Unit and Description are attached to the Arrow field as metadata.
+// Float32 stores an F64 column as float32 in Parquet and Arrow (see
+// WithPrecision).
+type Column struct {
+	Name        string
+	F64         func(*MetRow) *float64
+	Float32     bool
+	I32         func(*MetRow) *int32
+	U32         func(*MetRow) uint32
+	TS          func(*MetRow) *int64
+	Str         func(*MetRow) *string
+	OptIn       bool
+	Unit        string
+	Description string
+}
+
+// Columns lists every selectable column in schema order.
+var Columns = []Column{
+	{Name: "wdir_deg", I32: func(r *MetRow) *int32 { return r.WDIRDeg },
+		Unit: "degT", Description: "wind direction (from, degrees true)"},
+	{Name: "wspd_ms", F64: func(r *MetRow) *float64 { return r.WSPDmS },
+		Unit: "m/s", Description: "wind speed"},
+	{Name: "gust_ms", F64: func(r *MetRow) *float64 { return r.GUSTmS },
+		Unit: "m/s", Description: "peak gust speed"},
+	{Name: "pres_hpa", F64: func(r *MetRow) *float64 { return r.PREShPa },
+		Unit: "hPa", Description: "sea level pressure"},
+	{Name: "atmp_c", F64: func(r *MetRow) *float64 { return r.ATMPC },
+		Unit: "degC", Description: "air temperature"},
+	{Name: "wtmp_c", F64: func(r *MetRow) *float64 { return r.WTMPC },
+		Unit: "degC", Description: "sea surface temperature"},
+	{Name: "dewp_c", F64: func(r *MetRow) *float64 { return r.DEWPC },
+		Unit: "degC", Description: "dew point temperature"},
+	{Name: "mwd_deg", I32: func(r *MetRow) *int32 { return r.MWDDeg }, OptIn: true,
+		Unit: "degT", Description: "mean wave direction (from, degrees true)"},
+	{Name: "qc_flags", U32: func(r *MetRow) uint32 { return r.QCFlags }, OptIn: true,
+		Description: "bitmask of failed QC range checks"},
+	{Name: "windchill_c", F64: func(r *MetRow) *float64 { return r.WindChillC }, OptIn: true,
+		Unit: "degC", Description: "wind chill (derived)"},
+	{Name: "solar_time", TS: func(r *MetRow) *int64 { return r.SolarTime }, OptIn: true,
+		Unit: "s", Description: "local mean solar time (derived)"},
+	{Name: "wdir_card", Str: func(r *MetRow) *string { return r.WDIRCardinal }, OptIn: true,
+		Description: "16-point compass wind direction (derived)"},
+	{Name: "time_iso", Str: func(r *MetRow) *string { return r.TimeISO }, OptIn: true,
+		Description: "observation time, RFC 3339 UTC"},
+}
+
+// Nullable reports whether the column may hold nulls.
+func (c Column) Nullable() bool {
+	return c.U32 == nil
+}
+
+// Numeric reports whether the column is a measurement that can be
+// aggregated (not a bitmask or timestamp).
+func (c Column) Numeric() bool {
+	return c.F64 != nil || c.I32 != nil
+}
+
+// IsNull reports whether r has no value for the column.
+func (c Column) IsNull(r *MetRow) bool {
+	switch {
+	case c.I32 != nil:
+		return c.I32(r) == nil
+	case c.U32 != nil:
+		return false
+	case c.TS != nil:
+		return c.TS(r) == nil
+	case c.Str != nil:
+		return c.Str(r) == nil
+	default:
+		return c.F64(r) == nil
+	}
+}
+
+// Float returns r's value for the column as a float64, and false when it
+// is null.
+func (c Column) Float(r *MetRow) (float64, bool) {
+	switch {
+	case c.I32 != nil:
+		if p := c.I32(r); p != nil {
+			return float64(*p), true
+		}
+		return 0, false
+	case c.U32 != nil:
+		return float64(c.U32(r)), true
+	case c.TS != nil:
+		if p := c.TS(r); p != nil {
+			return float64(*p), true
+		}
+		return 0, false
+	case c.Str != nil:
+		return 0, false
+	default:
+		if p := c.F64(r); p != nil {
+			return *p, true
+		}
+		return 0, false
+	}
+}
+
+// LookupColumn returns the column called name.
+func LookupColumn(name string) (Column, bool) {
+	for _, c := range Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// ArrowType returns the Arrow data type of the column.
+func (c Column) ArrowType() arrow.DataType {
+	switch {
+	case c.I32 != nil:
+		return arrow.PrimitiveTypes.Int32
+	case c.U32 != nil:
+		return arrow.PrimitiveTypes.Uint32
+	case c.TS != nil:
+		return LocalTimestampType
+	case c.Str != nil:
+		return arrow.BinaryTypes.String
+	case c.Float32:
+		return arrow.PrimitiveTypes.Float32
+	default:
+		return arrow.PrimitiveTypes.Float64
+	}
+}
+
+// AppendArrow appends r's value for the column to b, which must be the
+// builder for ArrowType.
+func (c Column) AppendArrow(b array.Builder, r *MetRow) {
+	switch {
+	case c.I32 != nil:
+		if p := c.I32(r); p != nil {
+			b.(*array.Int32Builder).Append(*p)
+		} else {
+			b.AppendNull()
+		}
+	case c.U32 != nil:
+		b.(*array.Uint32Builder).Append(c.U32(r))
+	case c.TS != nil:
+		if p := c.TS(r); p != nil {
+			b.(*array.TimestampBuilder).Append(arrow.Timestamp(*p))
+		} else {
+			b.AppendNull()
+		}
+	case c.Str != nil:
+		if p := c.Str(r); p != nil {
+			b.(*array.StringBuilder).Append(*p)
+		} else {
+			b.AppendNull()
+		}
+	default:
+		p := c.F64(r)
+		switch {
+		case p == nil:
+			b.AppendNull()
+		case c.Float32:
+			b.(*array.Float32Builder).Append(float32(*p))
+		default:
+			b.(*array.Float64Builder).Append(*p)
+		}
+	}
+}
+
+// DefaultColumns returns every column that is not OptIn, in schema order.
+func DefaultColumns() []Column {
+	var out []Column
+	for _, c := range Columns {
+		if !c.OptIn {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// SetArrow sets r's value for the column from element i of a, which must
+// be an array of ArrowType (float columns may be either width). It is the
+// inverse of AppendArrow.
+func (c Column) SetArrow(r *MetRow, a arrow.Array, i int) {
+	null := a.IsNull(i)
+	switch p := fieldAddr(r, c.Name).(type) {
+	case **int32:
+		*p = nil
+		if !null {
+			v := a.(*array.Int32).Value(i)
+			*p = &v
+		}
+	case *uint32:
+		*p = a.(*array.Uint32).Value(i)
+	case **int64:
+		*p = nil
+		if !null {
+			v := int64(a.(*array.Timestamp).Value(i))
+			*p = &v
+		}
+	case **string:
+		*p = nil
+		if !null {
+			v := a.(*array.String).Value(i)
+			*p = &v
+		}
+	case **float64:
+		*p = nil
+		if null {
+			break
+		}
+		var v float64
+		if f32, ok := a.(*array.Float32); ok {
+			v = float64(f32.Value(i))
+		} else {
+			v = a.(*array.Float64).Value(i)
+		}
+		*p = &v
+	}
+}
+
+// fieldAddr returns a pointer to the MetRow field whose parquet tag is name.
+func fieldAddr(r *MetRow, name string) any {
+	return reflect.ValueOf(r).Elem().Field(fieldByTag[name]).Addr().Interface()
+}
+
+// SelectColumns resolves a comma-separated allow-list (the COLUMNS env var)
+// into columns, keeping schema order. An empty list selects DefaultColumns.
+func SelectColumns(csv string) ([]Column, error) {
+	if strings.TrimSpace(csv) == "" {
+		return DefaultColumns(), nil
+	}
+	want := make(map[string]bool)
+	for _, name := range strings.Split(csv, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "station_id" || name == "time" {
+			continue
+		}
+		want[name] = true
+	}
+	var out []Column
+	for _, c := range Columns {
+		if want[c.Name] {
+			out = append(out, c)
+			delete(want, c.Name)
+		}
+	}
+	for name := range want {
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+	return out, nil
+}
+
+// WithPrecision returns cols with their float measurement columns stored at
+// precision, "float64" (the default when empty) or "float32". Values of
+// float32 columns are rounded on write; nulls stay null.
+func WithPrecision(cols []Column, precision string) ([]Column, error) {
+	var f32 bool
+	switch strings.ToLower(strings.TrimSpace(precision)) {
+	case "", "float64":
+	case "float32":
+		f32 = true
+	default:
+		return cols, fmt.Errorf("unknown precision %q (want float64 or float32)", precision)
+	}
+	out := make([]Column, len(cols))
+	for i, c := range cols {
+		if c.F64 != nil {
+			c.Float32 = f32
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+// RoundRows rounds rows' values in the Float32 columns of cols to float32
+// precision, so freshly parsed rows compare equal to the same rows read
+// back from a float32 file.
+func RoundRows(cols []Column, rows []MetRow) {
+	for _, c := range cols {
+		if !c.Float32 {
+			continue
+		}
+		for i := range rows {
+			if p := c.F64(&rows[i]); p != nil {
+				*p = float64(float32(*p))
+			}
+		}
+	}
+}
+
+// SchemaFor returns the Arrow schema for station_id, time, and cols.
+func SchemaFor(cols []Column) *arrow.Schema {
+	return SchemaForUnit(cols, arrow.Second)
+}
+
+// SchemaForUnit is SchemaFor with the time column in unit u
+// (TIMESTAMP_UNIT).
+func SchemaForUnit(cols []Column, u arrow.TimeUnit) *arrow.Schema {
+	name, _ := unitInfo(u)
+	fields := []arrow.Field{
+		{Name: "station_id", Type: arrow.BinaryTypes.String, Nullable: false,
+			Metadata: fieldMetadata("", "NDBC station ID")},
+		{Name: "time", Type: TimestampTypeFor(u), Nullable: false,
+			Metadata: fieldMetadata(name, "observation time (UTC)")},
+	}
+	for _, c := range cols {
+		fields = append(fields, arrow.Field{Name: c.Name, Type: c.ArrowType(), Nullable: c.Nullable(),
+			Metadata: fieldMetadata(c.Unit, c.Description)})
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// fieldMetadata builds the "unit" and "description" field metadata, leaving
+// out empty values.
+func fieldMetadata(unit, description string) arrow.Metadata {
+	var keys, vals []string
+	if unit != "" {
+		keys, vals = append(keys, "unit"), append(vals, unit)
+	}
+	if description != "" {
+		keys, vals = append(keys, "description"), append(vals, description)
+	}
+	return arrow.NewMetadata(keys, vals)
+}
+
+// Schema returns the Arrow schema matching MetRow, field for field.
+func Schema() *arrow.Schema {
+	return SchemaFor(Columns)
+}
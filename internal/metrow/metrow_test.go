@@ -0,0 +1,136 @@
+package metrow
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+)
+
+func TestSchemaMatchesParquetTags(t *testing.T) {
+	rt := reflect.TypeOf(MetRow{})
+	fields := Schema().Fields()
+	if len(fields) != rt.NumField() {
+		t.Fatalf("Arrow schema has %d fields, MetRow has %d", len(fields), rt.NumField())
+	}
+	for i, f := range fields {
+		sf := rt.Field(i)
+		if tag := sf.Tag.Get("parquet"); tag != f.Name {
+			t.Errorf("field %d: Arrow %q, parquet tag %q (%s)", i, f.Name, tag, sf.Name)
+		}
+		if ptr := sf.Type.Kind() == reflect.Pointer; ptr != f.Nullable {
+			t.Errorf("%s: Arrow nullable=%t, struct field pointer=%t", f.Name, f.Nullable, ptr)
+		}
+	}
+}
+
+func names(cols []Column) []string {
+	var out []string
+	for _, c := range cols {
+		out = append(out, c.Name)
+	}
+	return out
+}
+
+func TestSelectColumns(t *testing.T) {
+	tests := []struct {
+		csv     string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{"wdir_deg", "wspd_ms", "gust_ms", "pres_hpa", "atmp_c", "wtmp_c", "dewp_c"}, false},
+		{" PRES_HPA , wspd_ms,time", []string{"wspd_ms", "pres_hpa"}, false},
+		{"wspd_ms,qc_flags", []string{"wspd_ms", "qc_flags"}, false},
+		{"solar_time,wspd_ms", []string{"wspd_ms", "solar_time"}, false},
+		{"wspd_ms,bogus", nil, true},
+	}
+	for _, tt := range tests {
+		cols, err := SelectColumns(tt.csv)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SelectColumns(%q) error = %v", tt.csv, err)
+			continue
+		}
+		if got := names(cols); !slices.Equal(got, tt.want) {
+			t.Errorf("SelectColumns(%q) = %v, want %v", tt.csv, got, tt.want)
+		}
+	}
+}
+
+func TestJSONKeepsNilAndZeroDistinct(t *testing.T) {
+	north := int32(0)
+	b, err := json.Marshal(MetRow{StationID: "SANF1", Time: 1718000000, WDIRDeg: &north})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := got["wdir_deg"]; !ok || v != 0.0 {
+		t.Errorf("wdir_deg = %v (present %v), want 0", v, ok)
+	}
+	if v, ok := got["wspd_ms"]; !ok || v != nil {
+		t.Errorf("wspd_ms = %v (present %v), want null", v, ok)
+	}
+
+	rt := reflect.TypeOf(MetRow{})
+	for i := 0; i < rt.NumField(); i++ {
+		if f := rt.Field(i); f.Tag.Get("json") != f.Tag.Get("parquet") {
+			t.Errorf("%s: json tag %q, parquet tag %q", f.Name, f.Tag.Get("json"), f.Tag.Get("parquet"))
+		}
+	}
+}
+
+func TestFieldMetadata(t *testing.T) {
+	for _, f := range Schema().Fields() {
+		if i := f.Metadata.FindKey("description"); i < 0 || f.Metadata.Values()[i] == "" {
+			t.Errorf("%s has no description", f.Name)
+		}
+	}
+	f, _ := Schema().FieldsByName("wspd_ms")
+	if i := f[0].Metadata.FindKey("unit"); i < 0 || f[0].Metadata.Values()[i] != "m/s" {
+		t.Errorf("wspd_ms metadata = %v, want unit m/s", f[0].Metadata)
+	}
+	// Columns without a unit carry no empty "unit" key.
+	f, _ = Schema().FieldsByName("wdir_card")
+	if f[0].Metadata.FindKey("unit") >= 0 {
+		t.Errorf("wdir_card metadata = %v, want no unit", f[0].Metadata)
+	}
+}
+
+func TestSetArrowInvertsAppendArrow(t *testing.T) {
+	dir, mwd := int32(120), int32(270)
+	wspd, chill := 5.5, -3.25
+	solar := int64(1718000000)
+	card := "ESE"
+	rows := []MetRow{
+		{WDIRDeg: &dir, WSPDmS: &wspd, MWDDeg: &mwd, QCFlags: 5, WindChillC: &chill, SolarTime: &solar, WDIRCardinal: &card},
+		{QCFlags: 0},
+	}
+	for _, c := range Columns {
+		b := array.NewBuilder(memory.DefaultAllocator, c.ArrowType())
+		for i := range rows {
+			c.AppendArrow(b, &rows[i])
+		}
+		a := b.NewArray()
+		b.Release()
+		for i := range rows {
+			var got MetRow
+			got.WSPDmS = &chill // SetArrow must clear a stale value on null
+			c.SetArrow(&got, a, i)
+			if g, w := c.IsNull(&got), c.IsNull(&rows[i]); g != w {
+				t.Errorf("%s row %d: null %t, want %t", c.Name, i, g, w)
+				continue
+			}
+			g, _ := c.Float(&got)
+			w, _ := c.Float(&rows[i])
+			if c.Str != nil && !c.IsNull(&got) && *c.Str(&got) != *c.Str(&rows[i]) || g != w {
+				t.Errorf("%s row %d: round trip changed the value", c.Name, i)
+			}
+		}
+		a.Release()
+	}
+}
@@ -0,0 +1,382 @@
+package metrow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	parquet "github.com/parquet-go/parquet-go"
+)
+
+// parquetNode returns the Parquet leaf node for the column.
+func (c Column) parquetNode() parquet.Node {
+	switch {
+	case c.I32 != nil:
+		return parquet.Optional(parquet.Int(32))
+	case c.U32 != nil:
+		return parquet.Uint(32)
+	case c.TS != nil:
+		return parquet.Optional(parquet.Int(64))
+	case c.Str != nil:
+		return parquet.Optional(parquet.String())
+	case c.Float32:
+		return parquet.Optional(parquet.Leaf(parquet.FloatType))
+	default:
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	}
+}
+
+// parquetValue returns r's value for the column at the given leaf index.
+func (c Column) parquetValue(r *MetRow, columnIndex int) parquet.Value {
+	switch {
+	case c.I32 != nil:
+		if p := c.I32(r); p != nil {
+			return parquet.Int32Value(*p).Level(0, 1, columnIndex)
+		}
+	case c.U32 != nil:
+		return parquet.Int32Value(int32(c.U32(r))).Level(0, 0, columnIndex)
+	case c.TS != nil:
+		if p := c.TS(r); p != nil {
+			return parquet.Int64Value(*p).Level(0, 1, columnIndex)
+		}
+	case c.Str != nil:
+		if p := c.Str(r); p != nil {
+			return parquet.ByteArrayValue([]byte(*p)).Level(0, 1, columnIndex)
+		}
+	default:
+		if p := c.F64(r); p != nil {
+			if c.Float32 {
+				return parquet.FloatValue(float32(*p)).Level(0, 1, columnIndex)
+			}
+			return parquet.DoubleValue(*p).Level(0, 1, columnIndex)
+		}
+	}
+	return parquet.NullValue().Level(0, 0, columnIndex)
+}
+
+// ParquetSchema builds the Parquet schema for station_id, time, and cols.
+// Column names match the MetRow struct tags, so files written with any
+// subset can still be read back into MetRow (absent columns read as nil).
+//
+// time carries the TIMESTAMP(isAdjustedToUTC=true, unit=MILLIS) logical
+// type so tools reading the file directly know it is an instant. Parquet
+// has no seconds unit, so the stored value is MetRow.Time * 1000; readers
+// undo that (see Reader).
+func ParquetSchema(cols []Column) *parquet.Schema {
+	return EncodedParquetSchema(cols, nil)
+}
+
+// EncodedParquetSchema is ParquetSchema with the columns in enc written in
+// the given encodings. enc must come from ParseParquetEncodings for cols.
+func EncodedParquetSchema(cols []Column, enc ParquetEncodings) *parquet.Schema {
+	g := parquet.Group{
+		"station_id": parquet.String(),
+		"time":       parquet.Timestamp(parquet.Millisecond),
+	}
+	for _, c := range cols {
+		g[c.Name] = c.parquetNode()
+	}
+	for name, e := range enc {
+		if n, ok := g[name]; ok {
+			g[name] = encoded(n, e)
+		}
+	}
+	return parquet.NewSchema("MetRow", g)
+}
+
+// Parquet column encodings selectable with PARQUET_ENCODING.
+const (
+	EncodingPlain      = "plain"
+	EncodingDictionary = "dictionary"
+	EncodingDelta      = "delta"
+)
+
+// ParquetEncodings maps column names, station_id and time included, to
+// the encoding they are written in. Unlisted columns keep parquet-go's
+// default.
+type ParquetEncodings map[string]string
+
+// ParseParquetEncodings parses PARQUET_ENCODING for cols. A bare encoding
+// ("dictionary") applies to every column whose physical type supports it.
+// Otherwise v is a comma-separated list of column=encoding pairs, and an
+// unknown column, or an encoding the column's type does not support (delta
+// on a float column), is an error.
+func ParseParquetEncodings(v string, cols []Column) (ParquetEncodings, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	kinds := map[string]parquet.Kind{"station_id": parquet.ByteArray, "time": parquet.Int64}
+	for _, c := range cols {
+		kinds[c.Name] = c.parquetNode().Type().Kind()
+	}
+	out := make(ParquetEncodings)
+	if !strings.Contains(v, "=") {
+		e := strings.ToLower(v)
+		if !knownEncoding(e) {
+			return nil, fmt.Errorf("unknown encoding %q (want plain, dictionary, or delta)", v)
+		}
+		for name, k := range kinds {
+			if encodingSupports(e, k) {
+				out[name] = e
+			}
+		}
+		return out, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, e, _ := strings.Cut(pair, "=")
+		name, e = strings.ToLower(strings.TrimSpace(name)), strings.ToLower(strings.TrimSpace(e))
+		k, ok := kinds[name]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("unknown column %q", name)
+		case !knownEncoding(e):
+			return nil, fmt.Errorf("unknown encoding %q for %s (want plain, dictionary, or delta)", e, name)
+		case !encodingSupports(e, k):
+			return nil, fmt.Errorf("%s encoding does not apply to %s (%s)", e, name, k)
+		}
+		out[name] = e
+	}
+	return out, nil
+}
+
+// ParseBloomFilterColumns parses BLOOM_FILTER_COLUMNS, a comma-separated
+// list of columns (station_id, time, or one of cols) to write Parquet bloom
+// filters for.
+func ParseBloomFilterColumns(v string, cols []Column) ([]string, error) {
+	known := map[string]bool{"station_id": true, "time": true}
+	for _, c := range cols {
+		known[c.Name] = true
+	}
+	var out []string
+	for _, name := range strings.Split(v, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func knownEncoding(e string) bool {
+	return e == EncodingPlain || e == EncodingDictionary || e == EncodingDelta
+}
+
+// encodingSupports reports whether encoding e can store values of kind k.
+// Parquet's delta encodings cover integers and byte arrays only.
+func encodingSupports(e string, k parquet.Kind) bool {
+	switch e {
+	case EncodingDictionary:
+		return k != parquet.Boolean
+	case EncodingDelta:
+		return k == parquet.Int32 || k == parquet.Int64 || k == parquet.ByteArray
+	}
+	return true
+}
+
+// encoded wraps node in encoding e, picking the delta variant that fits
+// its physical type.
+func encoded(node parquet.Node, e string) parquet.Node {
+	switch e {
+	case EncodingPlain:
+		return parquet.Encoded(node, &parquet.Plain)
+	case EncodingDictionary:
+		return parquet.Encoded(node, &parquet.RLEDictionary)
+	case EncodingDelta:
+		if node.Type().Kind() == parquet.ByteArray {
+			return parquet.Encoded(node, &parquet.DeltaByteArray)
+		}
+		return parquet.Encoded(node, &parquet.DeltaBinaryPacked)
+	}
+	return node
+}
+
+// ParquetRows converts rows into Parquet rows laid out for schema, which
+// must have been built by ParquetSchema from the same cols.
+func ParquetRows(schema *parquet.Schema, cols []Column, rows []MetRow) []parquet.Row {
+	index := make(map[string]int)
+	for i, path := range schema.Columns() {
+		index[path[0]] = i
+	}
+	stationIdx, timeIdx := index["station_id"], index["time"]
+
+	out := make([]parquet.Row, len(rows))
+	for i := range rows {
+		r := &rows[i]
+		row := make(parquet.Row, len(index))
+		row[stationIdx] = parquet.ByteArrayValue([]byte(r.StationID)).Level(0, 0, stationIdx)
+		row[timeIdx] = parquet.Int64Value(r.Time*1000).Level(0, 0, timeIdx)
+		for _, c := range cols {
+			ci := index[c.Name]
+			row[ci] = c.parquetValue(r, ci)
+		}
+		out[i] = row
+	}
+	return out
+}
+
+// timeScale returns what the stored time values of a file with schema s
+// must be divided by to get epoch seconds: 1 for files written before time
+// carried a logical type, else the ticks per second of its timestamp unit.
+func timeScale(s *parquet.Schema) int64 {
+	leaf, ok := s.Lookup("time")
+	if !ok {
+		return 1
+	}
+	lt := leaf.Node.Type().LogicalType()
+	if lt == nil || lt.Timestamp == nil {
+		return 1
+	}
+	switch u := lt.Timestamp.Unit; {
+	case u.Millis != nil:
+		return 1e3
+	case u.Micros != nil:
+		return 1e6
+	case u.Nanos != nil:
+		return 1e9
+	}
+	return 1
+}
+
+// fieldByTag maps each parquet struct tag of MetRow to its field index.
+var fieldByTag = func() map[string]int {
+	rt := reflect.TypeOf(MetRow{})
+	m := make(map[string]int, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		m[rt.Field(i).Tag.Get("parquet")] = i
+	}
+	return m
+}()
+
+// setField stores the non-null Parquet value v in field f of a MetRow.
+func setField(f reflect.Value, v parquet.Value) {
+	if f.Kind() == reflect.Pointer {
+		p := reflect.New(f.Type().Elem())
+		setField(p.Elem(), v)
+		f.Set(p)
+		return
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(string(v.ByteArray()))
+	case reflect.Int32:
+		f.SetInt(int64(v.Int32()))
+	case reflect.Int64:
+		f.SetInt(v.Int64())
+	case reflect.Uint32:
+		f.SetUint(uint64(v.Uint32()))
+	case reflect.Float64:
+		// PRECISION=float32 files store float columns as FLOAT.
+		if v.Kind() == parquet.Float {
+			f.SetFloat(float64(v.Float()))
+		} else {
+			f.SetFloat(v.Double())
+		}
+	}
+}
+
+// Reader reads MetRows from a Parquet file incrementally, matching
+// columns by name. Columns missing from the file (files written with a
+// COLUMNS subset) stay nil, or zero for qc_flags; unknown columns are
+// ignored. Time is returned in epoch seconds whether the file stores it as
+// a timestamp or, like older files, as plain seconds.
+//
+// parquet-go's GenericReader[MetRow] is not used because its schema
+// conversion fills missing optional columns with the definition level of
+// the file's first column, so a subset file whose first column is set
+// reads back every absent column as a non-nil zero.
+type Reader struct {
+	pr     *parquet.Reader
+	fields []int // MetRow field index per file column, -1 if unknown
+	scale  int64 // see timeScale
+	buf    []parquet.Row
+}
+
+// NewReader returns a Reader for the opened Parquet file f.
+func NewReader(f *parquet.File) *Reader {
+	pr := parquet.NewReader(f)
+	cols := pr.Schema().Columns()
+	fields := make([]int, len(cols))
+	for i, path := range cols {
+		fi, ok := fieldByTag[path[0]]
+		if !ok || len(path) != 1 {
+			fi = -1
+		}
+		fields[i] = fi
+	}
+	return &Reader{pr: pr, fields: fields, scale: timeScale(pr.Schema())}
+}
+
+// Read fills rows with up to len(rows) MetRows and returns how many it
+// read; like io.Reader it returns io.EOF once the file is exhausted.
+func (r *Reader) Read(rows []MetRow) (int, error) {
+	if cap(r.buf) < len(rows) {
+		r.buf = make([]parquet.Row, len(rows))
+	}
+	n, err := r.pr.ReadRows(r.buf[:len(rows)])
+	for i, row := range r.buf[:n] {
+		rows[i] = MetRow{}
+		rv := reflect.ValueOf(&rows[i]).Elem()
+		row.Range(func(col int, values []parquet.Value) bool {
+			if fi := r.fields[col]; fi >= 0 && len(values) == 1 && !values[0].IsNull() {
+				setField(rv.Field(fi), values[0])
+			}
+			return true
+		})
+		rows[i].Time /= r.scale
+	}
+	return n, err
+}
+
+// Close releases the underlying parquet reader.
+func (r *Reader) Close() error { return r.pr.Close() }
+
+// ReadParquet reads every row of a Parquet file into MetRows (see Reader).
+// r must report its size through a Size method or io.Seeker, as
+// *os.File and *bytes.Reader do.
+func ReadParquet(r io.ReaderAt) ([]MetRow, error) {
+	size, err := sizeOf(r)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, err
+	}
+	rd := NewReader(f)
+	defer rd.Close()
+
+	var all []MetRow
+	buf := make([]MetRow, 1024)
+	for {
+		n, err := rd.Read(buf)
+		all = append(all, buf[:n]...)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return all, err
+		}
+	}
+	return all, nil
+}
+
+// sizeOf returns the length of r for parquet.OpenFile.
+func sizeOf(r io.ReaderAt) (int64, error) {
+	switch f := r.(type) {
+	case interface{ Size() int64 }:
+		return f.Size(), nil
+	case io.Seeker:
+		return f.Seek(0, io.SeekEnd)
+	}
+	return 0, fmt.Errorf("parquet: cannot determine the size of %T", r)
+}
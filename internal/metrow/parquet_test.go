@@ -0,0 +1,316 @@
+package metrow
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+
+	parquet "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+func TestParquetSchemaMatchesMetRow(t *testing.T) {
+	got := ParquetSchema(Columns)
+	want := parquet.SchemaOf(MetRow{})
+	if len(got.Fields()) != len(want.Fields()) {
+		t.Fatalf("%d fields, MetRow has %d", len(got.Fields()), len(want.Fields()))
+	}
+	for _, w := range want.Fields() {
+		leaf, ok := got.Lookup(w.Name())
+		if !ok {
+			t.Errorf("column %q missing", w.Name())
+			continue
+		}
+		if leaf.Node.Type().Kind() != w.Type().Kind() || leaf.Node.Optional() != w.Optional() {
+			t.Errorf("column %q is %s (optional=%t), MetRow tag says %s (optional=%t)",
+				w.Name(), leaf.Node.Type(), leaf.Node.Optional(), w.Type(), w.Optional())
+		}
+	}
+}
+
+func TestParquetRowsSubsetRoundTrip(t *testing.T) {
+	cols, err := SelectColumns("wspd_ms,wdir_deg,qc_flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wspd, wdir, pres := 5.5, int32(270), 1013.0
+	in := []MetRow{
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: &wspd, WDIRDeg: &wdir, PREShPa: &pres, QCFlags: 8},
+		{StationID: "SANF1", Time: 1718003600},
+	}
+	schema := ParquetSchema(cols)
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(ParquetRows(schema, cols, in)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("read %d rows, want 2", len(out))
+	}
+	r := out[0]
+	if r.StationID != "SANF1" || r.Time != 1718000000 || r.WSPDmS == nil || *r.WSPDmS != wspd ||
+		r.WDIRDeg == nil || *r.WDIRDeg != wdir || r.QCFlags != 8 {
+		t.Errorf("row 0 = %+v", r)
+	}
+	if r.PREShPa != nil {
+		t.Errorf("unselected pres_hpa read back as %v", *r.PREShPa)
+	}
+	if out[1].WSPDmS != nil || out[1].WDIRDeg != nil {
+		t.Errorf("nulls not kept: %+v", out[1])
+	}
+}
+
+func TestReadParquetSubsetLeavesMissingNil(t *testing.T) {
+	// pres_hpa sorts first in the file and is set in row 0; absent columns
+	// must still read back nil.
+	cols, err := SelectColumns("pres_hpa,wspd_ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wspd, pres := 5.5, 1013.0
+	in := []MetRow{
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: &wspd, PREShPa: &pres},
+		{StationID: "SANF1", Time: 1718003600, WSPDmS: &wspd},
+	}
+	schema := ParquetSchema(cols)
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(ParquetRows(schema, cols, in)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("read %d rows, want 2", len(out))
+	}
+	r := out[0]
+	if r.StationID != "SANF1" || r.Time != 1718000000 || r.PREShPa == nil || *r.PREShPa != pres ||
+		r.WSPDmS == nil || *r.WSPDmS != wspd {
+		t.Errorf("row 0 = %+v", r)
+	}
+	for i, r := range out {
+		if r.WDIRDeg != nil || r.GUSTmS != nil || r.ATMPC != nil || r.WTMPC != nil || r.DEWPC != nil ||
+			r.WindChillC != nil || r.QCFlags != 0 {
+			t.Errorf("row %d: absent columns not nil: %+v", i, r)
+		}
+	}
+	if out[1].PREShPa != nil {
+		t.Errorf("row 1 pres_hpa = %v, want nil", *out[1].PREShPa)
+	}
+}
+
+func TestReadParquetTimeISO(t *testing.T) {
+	cols, err := SelectColumns("wspd_ms,time_iso")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iso := "2024-06-10T06:13:20Z"
+	in := []MetRow{
+		{StationID: "SANF1", Time: 1718000000, TimeISO: &iso},
+		{StationID: "SANF1", Time: 1718003600},
+	}
+	schema := ParquetSchema(cols)
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(ParquetRows(schema, cols, in)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].TimeISO == nil || *out[0].TimeISO != iso || out[1].TimeISO != nil {
+		t.Errorf("read back %+v", out)
+	}
+}
+
+func TestTimeColumnIsUTCTimestamp(t *testing.T) {
+	cols := DefaultColumns()
+	schema := ParquetSchema(cols)
+	leaf, _ := schema.Lookup("time")
+	lt := leaf.Node.Type().LogicalType()
+	if lt == nil || lt.Timestamp == nil || !lt.Timestamp.IsAdjustedToUTC || lt.Timestamp.Unit.Millis == nil {
+		t.Fatalf("time logical type = %v, want TIMESTAMP(UTC, MILLIS)", lt)
+	}
+
+	in := []MetRow{{StationID: "SANF1", Time: 1718000000}}
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(ParquetRows(schema, cols, in)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]parquet.Row, 1)
+	pr := parquet.NewReader(bytes.NewReader(buf.Bytes()))
+	if n, _ := pr.ReadRows(raw); n != 1 || raw[0][leaf.ColumnIndex].Int64() != 1718000000000 {
+		t.Errorf("stored time = %v, want epoch milliseconds", raw[0][leaf.ColumnIndex])
+	}
+	pr.Close()
+	if out, err := ReadParquet(bytes.NewReader(buf.Bytes())); err != nil || len(out) != 1 || out[0].Time != 1718000000 {
+		t.Errorf("ReadParquet = %+v, %v; want time in seconds", out, err)
+	}
+
+	// Files written before the logical type stored plain epoch seconds.
+	buf.Reset()
+	old := parquet.NewGenericWriter[MetRow](&buf)
+	if _, err := old.Write(in); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := ReadParquet(bytes.NewReader(buf.Bytes())); err != nil || len(out) != 1 || out[0].Time != 1718000000 {
+		t.Errorf("legacy file: ReadParquet = %+v, %v", out, err)
+	}
+}
+
+func TestReadParquetRejectsGarbage(t *testing.T) {
+	if _, err := ReadParquet(bytes.NewReader([]byte("not a parquet file"))); err == nil {
+		t.Error("ReadParquet accepted a non-parquet file")
+	}
+}
+
+func TestParquetFloat32RoundTrip(t *testing.T) {
+	cols, err := WithPrecision(DefaultColumns(), "float32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wspd, pres := 5.1, 1013.3
+	in := []MetRow{
+		{StationID: "SANF1", Time: 1718000000, WSPDmS: &wspd, PREShPa: &pres},
+		{StationID: "SANF1", Time: 1718003600},
+	}
+	schema := ParquetSchema(cols)
+	if leaf, _ := schema.Lookup("wspd_ms"); leaf.Node.Type().Kind() != parquet.Float {
+		t.Fatalf("wspd_ms stored as %s, want FLOAT", leaf.Node.Type())
+	}
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(ParquetRows(schema, cols, in)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("read %d rows, want 2", len(out))
+	}
+	for _, c := range []struct {
+		name string
+		got  *float64
+		want float64
+	}{{"wspd_ms", out[0].WSPDmS, wspd}, {"pres_hpa", out[0].PREShPa, pres}} {
+		name, got, want := c.name, c.got, c.want
+		if got == nil || math.Abs(*got-want) > 1e-4*math.Abs(want) {
+			t.Errorf("%s read back as %v, want %v within float32 precision", name, f64p(got), want)
+		}
+	}
+	if out[1].WSPDmS != nil || out[1].PREShPa != nil {
+		t.Errorf("nulls not kept: %+v", out[1])
+	}
+}
+
+func f64p(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func TestParseParquetEncodings(t *testing.T) {
+	cols := DefaultColumns()
+	enc, err := ParseParquetEncodings("Delta", cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Delta covers the integer and string columns, not the floats.
+	if enc["station_id"] != EncodingDelta || enc["time"] != EncodingDelta || enc["wdir_deg"] != EncodingDelta {
+		t.Errorf("bare delta = %v", enc)
+	}
+	if _, ok := enc["wspd_ms"]; ok {
+		t.Errorf("bare delta applied to float wspd_ms: %v", enc)
+	}
+	for _, bad := range []string{"zstd", "nope=plain", "wspd_ms=delta", "time=rle"} {
+		if _, err := ParseParquetEncodings(bad, cols); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if enc, err := ParseParquetEncodings(" ", cols); enc != nil || err != nil {
+		t.Errorf("empty = %v, %v", enc, err)
+	}
+
+	enc, err = ParseParquetEncodings("station_id=dictionary, TIME=delta,wspd_ms=plain", cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := EncodedParquetSchema(cols, enc)
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	if _, err := w.WriteRows(ParquetRows(schema, cols, []MetRow{{StationID: "SANF1", Time: 1718000000}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]format.Encoding{
+		"station_id": format.RLEDictionary,
+		"time":       format.DeltaBinaryPacked,
+		"wspd_ms":    format.Plain,
+	}
+	for _, c := range pf.Metadata().RowGroups[0].Columns {
+		name := c.MetaData.PathInSchema[0]
+		if e, ok := want[name]; ok && !slices.Contains(c.MetaData.Encoding, e) {
+			t.Errorf("%s written as %v, want %v", name, c.MetaData.Encoding, e)
+		}
+	}
+	if rows, err := ReadParquet(bytes.NewReader(buf.Bytes())); err != nil || len(rows) != 1 || rows[0].Time != 1718000000 {
+		t.Errorf("read back %+v, %v", rows, err)
+	}
+}
+
+func TestParseBloomFilterColumns(t *testing.T) {
+	cols := DefaultColumns()
+	got, err := ParseBloomFilterColumns(" Station_ID, wspd_ms,,", cols)
+	if err != nil || !slices.Equal(got, []string{"station_id", "wspd_ms"}) {
+		t.Errorf("= %q, %v", got, err)
+	}
+	if got, err := ParseBloomFilterColumns("", cols); got != nil || err != nil {
+		t.Errorf("empty = %q, %v", got, err)
+	}
+	// mwd_deg is opt-in, so not among the columns written.
+	for _, bad := range []string{"nope", "time,mwd_deg"} {
+		if _, err := ParseBloomFilterColumns(bad, cols); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
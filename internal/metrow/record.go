@@ -0,0 +1,97 @@
+package metrow
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+)
+
+// CheckRecordSchema reports whether schema can be read back into MetRows:
+// station_id and time must be present with the types SchemaFor gives them
+// (time in any unit), and every other field must be a known column of the
+// expected type. A nullable field for a non-nullable column (qc_flags) is
+// rejected, since a null would have nowhere to go.
+func CheckRecordSchema(schema *arrow.Schema) error {
+	seen := make(map[string]bool, schema.NumFields())
+	for _, f := range schema.Fields() {
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate column %q", f.Name)
+		}
+		seen[f.Name] = true
+		var want arrow.DataType
+		nullable := true
+		switch f.Name {
+		case "station_id":
+			want, nullable = arrow.BinaryTypes.String, false
+		case "time":
+			// Any unit will do (TIMESTAMP_UNIT); RecordToRows scales it.
+			want, nullable = TimestampType, false
+			if ts, ok := f.Type.(*arrow.TimestampType); ok {
+				want = TimestampTypeFor(ts.Unit)
+			}
+		default:
+			c, ok := LookupColumn(f.Name)
+			if !ok {
+				return fmt.Errorf("unknown column %q", f.Name)
+			}
+			// Float columns may come at either precision (PRECISION).
+			c.Float32 = c.F64 != nil && f.Type.ID() == arrow.FLOAT32
+			want, nullable = c.ArrowType(), c.Nullable()
+		}
+		if !arrow.TypeEqual(f.Type, want) {
+			return fmt.Errorf("column %q is %s, want %s", f.Name, f.Type, want)
+		}
+		if f.Nullable && !nullable {
+			return fmt.Errorf("column %q is nullable, want non-null", f.Name)
+		}
+	}
+	for _, name := range []string{"station_id", "time"} {
+		if !seen[name] {
+			return fmt.Errorf("missing column %q", name)
+		}
+	}
+	return nil
+}
+
+// RecordToRows converts a record with a SchemaFor layout back into MetRows;
+// it is the inverse of building a record with Column.AppendArrow. Fields
+// may come in any order, and columns the record lacks are left nil (zero
+// for qc_flags). The schema is checked with CheckRecordSchema first.
+func RecordToRows(rec arrow.Record) ([]MetRow, error) {
+	schema := rec.Schema()
+	if err := CheckRecordSchema(schema); err != nil {
+		return nil, err
+	}
+	var (
+		ids   *array.String
+		times *array.Timestamp
+		unit  arrow.TimeUnit
+		cols  []Column
+		arrs  []arrow.Array
+	)
+	for i, f := range schema.Fields() {
+		switch f.Name {
+		case "station_id":
+			ids = rec.Column(i).(*array.String)
+		case "time":
+			times = rec.Column(i).(*array.Timestamp)
+			unit = f.Type.(*arrow.TimestampType).Unit
+		default:
+			c, _ := LookupColumn(f.Name)
+			cols = append(cols, c)
+			arrs = append(arrs, rec.Column(i))
+		}
+	}
+
+	rows := make([]MetRow, rec.NumRows())
+	for i := range rows {
+		r := &rows[i]
+		r.StationID = ids.Value(i)
+		r.Time = FromTimestamp(times.Value(i), unit)
+		for j, c := range cols {
+			c.SetArrow(r, arrs[j], i)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,38 @@
+package metrow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow"
+)
+
+func TestCheckRecordSchema(t *testing.T) {
+	id := arrow.Field{Name: "station_id", Type: arrow.BinaryTypes.String}
+	tm := arrow.Field{Name: "time", Type: TimestampType}
+	wspd := arrow.Field{Name: "wspd_ms", Type: arrow.PrimitiveTypes.Float64, Nullable: true}
+	tests := []struct {
+		name   string
+		fields []arrow.Field
+		want   string // error substring, "" for success
+	}{
+		{"ok", []arrow.Field{wspd, tm, id}, ""},
+		{"missing time", []arrow.Field{id, wspd}, `missing column "time"`},
+		{"time in ms", []arrow.Field{id, {Name: "time", Type: TimestampTypeFor(arrow.Millisecond)}, wspd}, ""},
+		{"time not a timestamp", []arrow.Field{id, {Name: "time", Type: arrow.PrimitiveTypes.Int64}}, "time"},
+		{"duplicate", []arrow.Field{id, tm, wspd, wspd}, "duplicate"},
+		{"float32", []arrow.Field{id, tm, {Name: "wspd_ms", Type: arrow.PrimitiveTypes.Float32, Nullable: true}}, ""},
+		{"wrong type", []arrow.Field{id, tm, {Name: "wspd_ms", Type: arrow.PrimitiveTypes.Int32, Nullable: true}}, "is int32"},
+		{"nullable qc_flags", []arrow.Field{id, tm, {Name: "qc_flags", Type: arrow.PrimitiveTypes.Uint32, Nullable: true}}, "nullable"},
+		{"unknown", []arrow.Field{id, tm, {Name: "vis_nmi", Type: arrow.PrimitiveTypes.Float64}}, "unknown column"},
+	}
+	for _, tt := range tests {
+		err := CheckRecordSchema(arrow.NewSchema(tt.fields, nil))
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
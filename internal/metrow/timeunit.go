@@ -0,0 +1,63 @@
+package metrow
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v16/arrow"
+)
+
+// timeUnits are the Arrow units the time column can be served in
+// (TIMESTAMP_UNIT), by name, with how many make a second.
+var timeUnits = []struct {
+	name string
+	unit arrow.TimeUnit
+	per  int64
+}{
+	{"s", arrow.Second, 1},
+	{"ms", arrow.Millisecond, 1e3},
+	{"us", arrow.Microsecond, 1e6},
+	{"ns", arrow.Nanosecond, 1e9},
+}
+
+// ParseTimeUnit parses a TIMESTAMP_UNIT value: s, ms, us, or ns.
+func ParseTimeUnit(v string) (arrow.TimeUnit, error) {
+	for _, u := range timeUnits {
+		if v == u.name {
+			return u.unit, nil
+		}
+	}
+	return arrow.Second, fmt.Errorf("unknown timestamp unit %q (want s, ms, us, or ns)", v)
+}
+
+// unitInfo returns the timeUnits entry for u.
+func unitInfo(u arrow.TimeUnit) (name string, per int64) {
+	for _, t := range timeUnits {
+		if t.unit == u {
+			return t.name, t.per
+		}
+	}
+	return "s", 1
+}
+
+// TimestampTypeFor returns the Arrow type of the time column in unit u;
+// TimestampTypeFor(arrow.Second) is TimestampType.
+func TimestampTypeFor(u arrow.TimeUnit) *arrow.TimestampType {
+	if u == arrow.Second {
+		return TimestampType
+	}
+	return &arrow.TimestampType{Unit: u, TimeZone: "UTC"}
+}
+
+// ToTimestamp converts epoch seconds (MetRow.Time) to an Arrow timestamp in
+// unit u.
+func ToTimestamp(sec int64, u arrow.TimeUnit) arrow.Timestamp {
+	_, per := unitInfo(u)
+	return arrow.Timestamp(sec * per)
+}
+
+// FromTimestamp converts an Arrow timestamp in unit u back to epoch
+// seconds, dropping any fraction.
+func FromTimestamp(ts arrow.Timestamp, u arrow.TimeUnit) int64 {
+	_, per := unitInfo(u)
+	return int64(ts) / per
+}
@@ -0,0 +1,42 @@
+package metrow
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow"
+)
+
+func TestTimeUnits(t *testing.T) {
+	const sec = 1718000000
+	for _, tt := range []struct {
+		v    string
+		unit arrow.TimeUnit
+		ts   arrow.Timestamp
+	}{
+		{"s", arrow.Second, sec},
+		{"ms", arrow.Millisecond, sec * 1e3},
+		{"us", arrow.Microsecond, sec * 1e6},
+		{"ns", arrow.Nanosecond, sec * 1e9},
+	} {
+		u, err := ParseTimeUnit(tt.v)
+		if err != nil || u != tt.unit {
+			t.Errorf("ParseTimeUnit(%q) = %v, %v", tt.v, u, err)
+		}
+		if got := ToTimestamp(sec, u); got != tt.ts {
+			t.Errorf("%s: ToTimestamp = %d, want %d", tt.v, got, tt.ts)
+		}
+		if got := FromTimestamp(tt.ts+1, u); tt.v != "s" && got != sec {
+			t.Errorf("%s: FromTimestamp = %d, want %d (fraction dropped)", tt.v, got, sec)
+		}
+		f := SchemaForUnit(nil, u).Field(1)
+		if f.Type.(*arrow.TimestampType).Unit != u || f.Metadata.Values()[f.Metadata.FindKey("unit")] != tt.v {
+			t.Errorf("%s: time field %v", tt.v, f)
+		}
+	}
+	if _, err := ParseTimeUnit("min"); err == nil {
+		t.Error("ParseTimeUnit(min): no error")
+	}
+	if TimestampTypeFor(arrow.Second) != TimestampType {
+		t.Error("TimestampTypeFor(Second) is not TimestampType")
+	}
+}
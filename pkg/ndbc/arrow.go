@@ -0,0 +1,37 @@
+package ndbc
+
+import (
+	"github.com/apache/arrow/go/v16/arrow"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// SchemaFor returns the Arrow schema of records carrying cols: station_id
+// and time, then cols in order.
+func SchemaFor(cols []Column) *arrow.Schema { return metrow.SchemaFor(cols) }
+
+// RecordToRows converts an Arrow record as served by go-source's /stream
+// back into MetRows, keeping nulls as nil. It fails if the record's schema
+// does not match MetRow; see CheckRecordSchema.
+func RecordToRows(rec arrow.Record) ([]MetRow, error) {
+	return metrow.RecordToRows(rec)
+}
+
+// CheckRecordSchema reports whether records with schema can be converted
+// by RecordToRows.
+func CheckRecordSchema(schema *arrow.Schema) error {
+	return metrow.CheckRecordSchema(schema)
+}
+
+// SchemaForUnit is SchemaFor with the time column in unit u, as go-source
+// serves it under TIMESTAMP_UNIT. RecordToRows reads any unit back.
+func SchemaForUnit(cols []Column, u arrow.TimeUnit) *arrow.Schema {
+	return metrow.SchemaForUnit(cols, u)
+}
+
+// ParseTimeUnit parses a TIMESTAMP_UNIT value: s, ms, us, or ns.
+func ParseTimeUnit(v string) (arrow.TimeUnit, error) { return metrow.ParseTimeUnit(v) }
+
+// ToTimestamp converts epoch seconds (MetRow.Time) to an Arrow timestamp in
+// unit u.
+func ToTimestamp(sec int64, u arrow.TimeUnit) arrow.Timestamp { return metrow.ToTimestamp(sec, u) }
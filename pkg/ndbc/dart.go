@@ -0,0 +1,112 @@
+package ndbc
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// DartRow is one DART water-column height reading; see metrow.DartRow.
+type DartRow = metrow.DartRow
+
+// ParseDart parses an NDBC realtime2 DART file (<station>.dart):
+//
+//	#YY  MM DD hh mm ss T   HEIGHT
+//	#yr  mo dy hr mn  s -      m
+//	2024 05 01 12 15 00 1 5812.345
+//
+// T is the measurement type; types 2 and 3 are only reported in tsunami
+// event mode and set DartRow.Event. A height equal to a sentinel (9999.000)
+// is stored as nil. WithStation, WithMaxRows, WithYearPivot, WithSentinels,
+// WithFormat, WithUnits, WithWarnings and WithSentinelCounts apply; the
+// other options are specific to ParseStdMet.
+func ParseDart(body []byte, opts ...ParseOption) ([]DartRow, error) {
+	cfg := parseConfig{yearPivot: DefaultYearPivot, sentinels: DefaultSentinels}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	data, units, warnings, err := scan(body, cfg.format)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.units != nil {
+		*cfg.units = units
+	}
+	if cfg.warnings != nil {
+		*cfg.warnings = warnings
+	}
+	if cfg.maxRows > 0 && len(data) > cfg.maxRows {
+		data = data[:cfg.maxRows]
+	}
+
+	out := make([]DartRow, 0, len(data))
+	for _, d := range data {
+		cols, idx := d.cols, d.idx
+		yy := get(cols, idx, "YYYY")
+		if yy == "" {
+			yy = get(cols, idx, "YY")
+		}
+		month, _ := strconv.Atoi(get(cols, idx, "MM"))
+		day, _ := strconv.Atoi(get(cols, idx, "DD"))
+		hour, _ := strconv.Atoi(get(cols, idx, "HH"))
+		minute, _ := strconv.Atoi(get(cols, idx, minuteKey))
+		sec, _ := strconv.Atoi(get(cols, idx, "SS"))
+		t := time.Date(expandYear(yy, cfg.yearPivot), time.Month(month), day, hour, minute, sec, 0, time.UTC)
+
+		row := DartRow{
+			StationID: cfg.station,
+			Time:      t.Unix(),
+			HeightM:   cfg.atofP("HEIGHT", get(cols, idx, "HEIGHT")),
+		}
+		if typ, err := strconv.Atoi(get(cols, idx, "T")); err == nil {
+			row.Type = int32(typ)
+			row.Event = typ == 2 || typ == 3
+		}
+		out = append(out, row)
+	}
+	if cfg.sentinelsDst != nil {
+		*cfg.sentinelsDst = cfg.sentinelCounts
+	}
+	return out, nil
+}
+
+// WriteDartParquet encodes rows to w as one Parquet file with meta as
+// file-level key/value metadata.
+func WriteDartParquet(w io.Writer, rows []DartRow, meta map[string]string) error {
+	var opts []parquet.WriterOption
+	for k, v := range meta {
+		opts = append(opts, parquet.KeyValueMetadata(k, v))
+	}
+	pw := parquet.NewGenericWriter[DartRow](w, opts...)
+	if _, err := pw.Write(rows); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// ReadDartParquet reads every row of a DART Parquet file.
+func ReadDartParquet(r io.ReaderAt) ([]DartRow, error) {
+	pr := parquet.NewGenericReader[DartRow](r)
+	defer pr.Close()
+
+	var all []DartRow
+	buf := make([]DartRow, 1024)
+	for {
+		n, err := pr.Read(buf)
+		if n > 0 {
+			all = append(all, buf[:n]...)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return all, err
+		}
+	}
+	return all, nil
+}
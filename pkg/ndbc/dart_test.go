@@ -0,0 +1,67 @@
+package ndbc
+
+import (
+	"bytes"
+	"testing"
+)
+
+const dartSample = `#YY  MM DD hh mm ss T   HEIGHT
+#yr  mo dy hr mn  s -      m
+2024 05 01 12 15 30 3 5812.412
+2024 05 01 12 15 00 1 5812.345
+2024 05 01 12 00 00 1 9999.000
+`
+
+func TestParseDart(t *testing.T) {
+	var units map[string]string
+	rows, err := ParseDart([]byte(dartSample), WithStation("21413"), WithUnits(&units))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+	r := rows[0]
+	if r.StationID != "21413" || r.Time != unix(2024, 5, 1, 12, 15)+30 || r.Type != 3 || !r.Event ||
+		f64p(r.HeightM) != 5812.412 {
+		t.Errorf("row 0 = %+v", r)
+	}
+	if r := rows[1]; r.Type != 1 || r.Event || r.Time != unix(2024, 5, 1, 12, 15) {
+		t.Errorf("row 1 = %+v", r)
+	}
+	if rows[2].HeightM != nil {
+		t.Errorf("sentinel height parsed as %v, want nil", *rows[2].HeightM)
+	}
+	if units["HEIGHT"] != "m" {
+		t.Errorf("units = %v", units)
+	}
+
+	if rows, _ := ParseDart([]byte(dartSample), WithMaxRows(1)); len(rows) != 1 {
+		t.Errorf("WithMaxRows(1): %d rows", len(rows))
+	}
+}
+
+func TestDartParquetRoundTrip(t *testing.T) {
+	rows, err := ParseDart([]byte(dartSample), WithStation("21413"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteDartParquet(&buf, rows, map[string]string{UnitsKey: `{"HEIGHT":"m"}`}); err != nil {
+		t.Fatal(err)
+	}
+	back, err := ReadDartParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != len(rows) {
+		t.Fatalf("read %d rows, want %d", len(back), len(rows))
+	}
+	for i := range rows {
+		a, b := rows[i], back[i]
+		if a.StationID != b.StationID || a.Time != b.Time || a.Type != b.Type || a.Event != b.Event ||
+			f64p(a.HeightM) != f64p(b.HeightM) {
+			t.Errorf("row %d: wrote %+v, read %+v", i, a, b)
+		}
+	}
+}
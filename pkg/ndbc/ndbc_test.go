@@ -0,0 +1,233 @@
+package ndbc_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/pkg/ndbc"
+)
+
+// sample is an excerpt of a realtime2 SANF1.txt file, newest row first.
+const sample = `#YY  MM DD hh mm WDIR WSPD GST  WVHT   DPD   APD MWD   PRES  ATMP  WTMP  DEWP  VIS PTDY  TIDE
+#yr  mo dy hr mn degT m/s  m/s     m   sec   sec degT   hPa  degC  degC  degC  nmi  hPa    ft
+2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM
+2024 06 10 06 00 110  4.0  5.0    MM    MM    MM  MM   9999  24.8  26.0  20.1   MM   MM    MM
+2024 06 10 05 00  MM  3.5  4.5    MM    MM    MM  MM 1012.8  24.5  25.9  20.2   MM   MM    MM
+`
+
+// TestLibraryRoundTrip uses only the exported API, the way another
+// program embedding the parser would.
+func TestLibraryRoundTrip(t *testing.T) {
+	rows, err := ndbc.ParseStdMet([]byte(sample), ndbc.WithStation("sanf1"), ndbc.WithMaxRows(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2 (WithMaxRows)", len(rows))
+	}
+	r := rows[0]
+	if r.StationID != "SANF1" || r.Time != time.Date(2024, 6, 10, 7, 0, 0, 0, time.UTC).Unix() ||
+		r.WDIRDeg == nil || *r.WDIRDeg != 120 || r.WSPDmS == nil || *r.WSPDmS != 5 {
+		t.Errorf("row 0 = %+v", r)
+	}
+	if rows[1].PREShPa != nil {
+		t.Errorf("sentinel 9999 pressure parsed as %v, want nil", *rows[1].PREShPa)
+	}
+	if u := ndbc.Units([]byte(sample)); u["PRES"] != "hPa" {
+		t.Errorf("Units = %v", u)
+	}
+
+	cols, err := ndbc.SelectColumns("wspd_ms,pres_hpa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ndbc.SelectColumns("nope"); err == nil {
+		t.Error("SelectColumns accepted an unknown column")
+	}
+	var buf bytes.Buffer
+	if err := ndbc.WriteParquet(&buf, cols, rows, map[string]string{ndbc.UnitsKey: `{"PRES":"hPa"}`}); err != nil {
+		t.Fatal(err)
+	}
+	back, err := ndbc.ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 2 || back[0].StationID != "SANF1" || back[0].Time != r.Time ||
+		back[0].PREShPa == nil || *back[0].PREShPa != 1013.2 || back[0].WDIRDeg != nil {
+		t.Errorf("read back %+v, want wspd/pres only", back)
+	}
+
+	if c, ok := ndbc.LookupColumn("wtmp_c"); !ok || !c.Nullable() {
+		t.Errorf("LookupColumn(wtmp_c) = %+v, %v", c, ok)
+	}
+	if n := len(ndbc.DefaultColumns()); n == 0 || n >= len(ndbc.Columns) {
+		t.Errorf("DefaultColumns has %d of %d columns; opt-in ones should be excluded", n, len(ndbc.Columns))
+	}
+}
+
+// TestLibraryFloat32 writes a PRECISION=float32 file and reads it back.
+func TestLibraryFloat32(t *testing.T) {
+	rows, err := ndbc.ParseStdMet([]byte(sample), ndbc.WithStation("SANF1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := ndbc.WithPrecision(ndbc.DefaultColumns(), "float32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ndbc.WithPrecision(cols, "float16"); err == nil {
+		t.Error("WithPrecision accepted float16")
+	}
+	ndbc.RoundRows(cols, rows)
+	var buf bytes.Buffer
+	if err := ndbc.WriteParquet(&buf, cols, rows, nil); err != nil {
+		t.Fatal(err)
+	}
+	back, err := ndbc.ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 3 || back[0].PREShPa == nil || *back[0].PREShPa != *rows[0].PREShPa ||
+		back[1].PREShPa != nil || *back[2].WSPDmS != 3.5 {
+		t.Errorf("read back %+v, want the rounded rows", back)
+	}
+}
+
+// TestLibraryEncodings writes a file with chosen column encodings.
+func TestLibraryEncodings(t *testing.T) {
+	rows, err := ndbc.ParseStdMet([]byte(sample), ndbc.WithStation("SANF1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := ndbc.DefaultColumns()
+	if _, err := ndbc.ParseParquetEncodings("pres_hpa=delta", cols); err == nil {
+		t.Error("delta accepted for a float column")
+	}
+	enc, err := ndbc.ParseParquetEncodings("station_id=dictionary,time=delta", cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ndbc.WriteParquet(&buf, cols, rows, nil, ndbc.WithEncodings(enc)); err != nil {
+		t.Fatal(err)
+	}
+	back, err := ndbc.ReadParquet(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 3 || back[2].StationID != "SANF1" || back[2].Time != rows[2].Time {
+		t.Errorf("read back %+v", back)
+	}
+}
+
+// TestRecordRoundTrip builds an Arrow record the way go-source does and
+// reads it back with RecordToRows.
+func TestRecordRoundTrip(t *testing.T) {
+	rows, err := ndbc.ParseStdMet([]byte(sample), ndbc.WithStation("SANF1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, err := ndbc.SelectColumns("wdir_deg,pres_hpa,qc_flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := ndbc.SchemaFor(cols)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for i := range rows {
+		b.Field(0).(*array.StringBuilder).Append(rows[i].StationID)
+		b.Field(1).(*array.TimestampBuilder).Append(arrow.Timestamp(rows[i].Time))
+		for j, c := range cols {
+			c.AppendArrow(b.Field(j+2), &rows[i])
+		}
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	back, err := ndbc.RecordToRows(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 3 || back[0].Time != rows[0].Time || *back[0].WDIRDeg != 120 ||
+		back[1].PREShPa != nil || back[2].WDIRDeg != nil || back[0].WSPDmS != nil {
+		t.Errorf("RecordToRows = %+v", back)
+	}
+
+	bad := arrow.NewSchema([]arrow.Field{
+		{Name: "station_id", Type: arrow.BinaryTypes.String},
+		{Name: "time", Type: schema.Field(1).Type},
+		{Name: "wspd_kts", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	}, nil)
+	if err := ndbc.CheckRecordSchema(bad); err == nil {
+		t.Error("CheckRecordSchema accepted an unknown column")
+	}
+}
+
+// TestRecordTimestampUnit reads back a record whose time column is in
+// milliseconds, as go-source serves it with TIMESTAMP_UNIT=ms.
+func TestRecordTimestampUnit(t *testing.T) {
+	unit, err := ndbc.ParseTimeUnit("ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := ndbc.SchemaForUnit(nil, unit)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	const sec = 1718002800
+	b.Field(0).(*array.StringBuilder).Append("SANF1")
+	b.Field(1).(*array.TimestampBuilder).Append(ndbc.ToTimestamp(sec, unit))
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	if got := rec.Column(1).(*array.Timestamp).Value(0); got != sec*1000 {
+		t.Errorf("time = %d, want %d ms", got, sec*1000)
+	}
+	back, err := ndbc.RecordToRows(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) != 1 || back[0].Time != sec {
+		t.Errorf("RecordToRows = %+v, want time %d", back, sec)
+	}
+}
+
+// TestLibraryBloomFilters writes a file with a bloom filter on station_id
+// and probes it.
+func TestLibraryBloomFilters(t *testing.T) {
+	rows, err := ndbc.ParseStdMet([]byte(sample), ndbc.WithStation("SANF1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := ndbc.DefaultColumns()
+	bloom, err := ndbc.ParseBloomFilterColumns("station_id", cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ndbc.WriteParquet(&buf, cols, rows, nil, ndbc.WithBloomFilters(bloom)); err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := pf.Schema().Lookup("station_id")
+	filter := pf.RowGroups()[0].ColumnChunks()[leaf.ColumnIndex].BloomFilter()
+	if filter == nil {
+		t.Fatal("no bloom filter on station_id")
+	}
+	for station, want := range map[string]bool{"SANF1": true, "SMKF1": false} {
+		if got, err := filter.Check(parquet.ValueOf(station)); err != nil || got != want {
+			t.Errorf("Check(%s) = %v, %v; want %v", station, got, err, want)
+		}
+	}
+	if back, err := ndbc.ReadParquet(bytes.NewReader(buf.Bytes())); err != nil || len(back) != 3 {
+		t.Errorf("read back %d rows, %v", len(back), err)
+	}
+}
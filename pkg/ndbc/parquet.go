@@ -0,0 +1,126 @@
+package ndbc
+
+import (
+	"io"
+
+	parquet "github.com/parquet-go/parquet-go"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// Column is one selectable measurement column; see metrow.Column.
+type Column = metrow.Column
+
+// Columns lists every measurement column in schema order.
+var Columns = metrow.Columns
+
+// UnitsKey is the Parquet key/value metadata key holding the NDBC units
+// line as JSON.
+const UnitsKey = metrow.UnitsKey
+
+// Provenance metadata keys go-ingest writes: the NDBC URL a file was
+// fetched from, when (RFC 3339 UTC), the go-ingest version, and the UUID
+// of the ingest cycle that wrote it.
+const (
+	SourceURLKey   = metrow.SourceURLKey
+	FetchedAtKey   = metrow.FetchedAtKey
+	ToolVersionKey = metrow.ToolVersionKey
+	BatchIDKey     = metrow.BatchIDKey
+)
+
+// DefaultColumns returns every column that is not opt-in, in schema order.
+func DefaultColumns() []Column { return metrow.DefaultColumns() }
+
+// SelectColumns parses a comma-separated allow-list of column names; an
+// empty csv selects DefaultColumns.
+func SelectColumns(csv string) ([]Column, error) { return metrow.SelectColumns(csv) }
+
+// LookupColumn returns the column called name.
+func LookupColumn(name string) (Column, bool) { return metrow.LookupColumn(name) }
+
+// WithPrecision returns cols with their float measurement columns stored
+// at precision, "float64" (the default when empty) or "float32".
+func WithPrecision(cols []Column, precision string) ([]Column, error) {
+	return metrow.WithPrecision(cols, precision)
+}
+
+// RoundRows rounds rows' values in the float32 columns of cols to float32
+// precision, as they will read back from the file.
+func RoundRows(cols []Column, rows []MetRow) { metrow.RoundRows(cols, rows) }
+
+// ParquetEncodings maps column names to the encoding they are written in;
+// see ParseParquetEncodings.
+type ParquetEncodings = metrow.ParquetEncodings
+
+// ParseParquetEncodings parses a PARQUET_ENCODING value for cols: either a
+// bare encoding (plain, dictionary, or delta) applied to every column that
+// supports it, or comma-separated column=encoding pairs.
+func ParseParquetEncodings(v string, cols []Column) (ParquetEncodings, error) {
+	return metrow.ParseParquetEncodings(v, cols)
+}
+
+// ParseBloomFilterColumns parses a BLOOM_FILTER_COLUMNS value, a
+// comma-separated list of columns (station_id, time, or one of cols), for
+// WithBloomFilters.
+func ParseBloomFilterColumns(v string, cols []Column) ([]string, error) {
+	return metrow.ParseBloomFilterColumns(v, cols)
+}
+
+// WriteOption tunes WriteParquet.
+type WriteOption func(*writeConfig)
+
+type writeConfig struct {
+	encodings ParquetEncodings
+	bloom     []string
+}
+
+// bloomBitsPerValue sizes the bloom filters WithBloomFilters adds, for
+// about a 1% false-positive rate.
+const bloomBitsPerValue = 10
+
+// WithEncodings writes the columns in enc with the given encodings.
+func WithEncodings(enc ParquetEncodings) WriteOption {
+	return func(c *writeConfig) { c.encodings = enc }
+}
+
+// WithBloomFilters writes a split-block bloom filter for each named column
+// (see ParseBloomFilterColumns), so readers filtering on it can skip
+// row groups that cannot match.
+func WithBloomFilters(columns []string) WriteOption {
+	return func(c *writeConfig) { c.bloom = columns }
+}
+
+// WriteParquet encodes rows to w as one Parquet file, keeping only the
+// selected cols (plus station_id and time) and attaching meta as file-level
+// key/value metadata.
+func WriteParquet(w io.Writer, cols []Column, rows []MetRow, meta map[string]string, opts ...WriteOption) error {
+	var cfg writeConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	schema := metrow.EncodedParquetSchema(cols, cfg.encodings)
+	wopts := []parquet.WriterOption{schema}
+	for k, v := range meta {
+		wopts = append(wopts, parquet.KeyValueMetadata(k, v))
+	}
+	if len(cfg.bloom) > 0 {
+		filters := make([]parquet.BloomFilterColumn, len(cfg.bloom))
+		for i, name := range cfg.bloom {
+			filters[i] = parquet.SplitBlockFilter(bloomBitsPerValue, name)
+		}
+		wopts = append(wopts, parquet.BloomFilters(filters...))
+	}
+	pw := parquet.NewWriter(w, wopts...)
+	if _, err := pw.WriteRows(metrow.ParquetRows(schema, cols, rows)); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// ReadParquet reads every row of a Parquet file, matching columns by name.
+// Columns missing from the file are left nil (zero for qc_flags). Time is
+// returned in epoch seconds whether the file stores it as a timestamp or as
+// plain seconds.
+func ReadParquet(r io.ReaderAt) ([]MetRow, error) {
+	return metrow.ReadParquet(r)
+}
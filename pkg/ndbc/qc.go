@@ -0,0 +1,49 @@
+package ndbc
+
+// QC flag bits set in MetRow.QCFlags when a value falls outside its
+// physically plausible range. The suspect value itself is kept.
+const (
+	QCWDIR uint32 = 1 << iota
+	QCWSPD
+	QCGUST
+	QCPRES
+	QCATMP
+	QCWTMP
+	QCDEWP
+	QCMWD
+)
+
+func outsideF(p *float64, lo, hi float64) bool {
+	return p != nil && (*p < lo || *p > hi)
+}
+
+// QCFlags range-checks a parsed row and returns the bitmask of failed
+// fields. ParseStdMet stores it in each row when WithQCChecks is set.
+func QCFlags(r MetRow) uint32 {
+	var f uint32
+	if r.WDIRDeg != nil && (*r.WDIRDeg < 0 || *r.WDIRDeg > 360) {
+		f |= QCWDIR
+	}
+	if outsideF(r.WSPDmS, 0, 120) {
+		f |= QCWSPD
+	}
+	if outsideF(r.GUSTmS, 0, 150) {
+		f |= QCGUST
+	}
+	if outsideF(r.PREShPa, 800, 1100) {
+		f |= QCPRES
+	}
+	if outsideF(r.ATMPC, -60, 60) {
+		f |= QCATMP
+	}
+	if outsideF(r.WTMPC, -5, 40) {
+		f |= QCWTMP
+	}
+	if outsideF(r.DEWPC, -60, 40) {
+		f |= QCDEWP
+	}
+	if r.MWDDeg != nil && (*r.MWDDeg < 0 || *r.MWDDeg > 360) {
+		f |= QCMWD
+	}
+	return f
+}
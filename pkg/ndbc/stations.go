@@ -0,0 +1,64 @@
+package ndbc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ActiveStationsURL is NDBC's list of stations currently reporting.
+const ActiveStationsURL = "https://www.ndbc.noaa.gov/activestations.xml"
+
+// Station is one entry of NDBC's active station list.
+type Station struct {
+	ID      string
+	Name    string
+	Owner   string
+	Program string
+	// Type is the platform, e.g. "buoy", "fixed", "dart", "tao".
+	Type string
+	Lat  float64
+	Lon  float64
+	// Met reports whether the station has meteorological (stdmet) data;
+	// Dart whether it is a DART tsunameter.
+	Met  bool
+	Dart bool
+}
+
+type xmlStation struct {
+	ID    string  `xml:"id,attr"`
+	Lat   float64 `xml:"lat,attr"`
+	Lon   float64 `xml:"lon,attr"`
+	Name  string  `xml:"name,attr"`
+	Owner string  `xml:"owner,attr"`
+	Pgm   string  `xml:"pgm,attr"`
+	Type  string  `xml:"type,attr"`
+	Met   string  `xml:"met,attr"`
+	Dart  string  `xml:"dart,attr"`
+}
+
+// ParseActiveStations parses activestations.xml (see ActiveStationsURL).
+// Station IDs are upper-cased to match the realtime2 file names.
+func ParseActiveStations(body []byte) ([]Station, error) {
+	var doc struct {
+		Stations []xmlStation `xml:"station"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parse active stations: %w", err)
+	}
+	out := make([]Station, 0, len(doc.Stations))
+	for _, s := range doc.Stations {
+		out = append(out, Station{
+			ID:      strings.ToUpper(s.ID),
+			Name:    s.Name,
+			Owner:   s.Owner,
+			Program: s.Pgm,
+			Type:    s.Type,
+			Lat:     s.Lat,
+			Lon:     s.Lon,
+			Met:     s.Met == "y",
+			Dart:    s.Dart == "y",
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,24 @@
+package ndbc
+
+import "testing"
+
+func TestParseActiveStations(t *testing.T) {
+	body := `<stations>
+<station id="sanf1" lat="24.456" lon="-81.877" name="Sand Key, FL" owner="NDBC" pgm="NDBC Meteorological/Ocean" type="fixed" met="y" dart="n"/>
+<station id="21413" lat="30.528" lon="152.132" name="SOUTHEAST TOKYO" owner="NDBC" pgm="Tsunami" type="dart" met="n" dart="y"/>
+</stations>`
+	got, err := ParseActiveStations([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Station{
+		{ID: "SANF1", Name: "Sand Key, FL", Owner: "NDBC", Program: "NDBC Meteorological/Ocean", Type: "fixed", Lat: 24.456, Lon: -81.877, Met: true},
+		{ID: "21413", Name: "SOUTHEAST TOKYO", Owner: "NDBC", Program: "Tsunami", Type: "dart", Lat: 30.528, Lon: 152.132, Dart: true},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("ParseActiveStations = %+v, want %+v", got, want)
+	}
+	if _, err := ParseActiveStations([]byte("<html>")); err == nil {
+		t.Error("malformed XML accepted")
+	}
+}
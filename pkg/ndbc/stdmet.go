@@ -0,0 +1,720 @@
+// Package ndbc parses NDBC realtime2 standard meteorological text files and
+// reads and writes the resulting rows as Parquet. It is the library behind
+// go-ingest and go-source and can be imported by other programs.
+package ndbc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"example.com/arrow-buoys/internal/metrow"
+)
+
+// MetRow is one observation row; see metrow.MetRow.
+type MetRow = metrow.MetRow
+
+// DefaultYearPivot splits two-digit years: below it → 20xx, otherwise 19xx.
+const DefaultYearPivot = 70
+
+// DefaultSentinels are the NDBC missing-value markers.
+var DefaultSentinels = []float64{99, 999, 9999}
+
+// DedupMode selects which of several rows sharing a timestamp survives.
+type DedupMode int
+
+const (
+	// DedupFirst keeps the first row for each timestamp (the default).
+	DedupFirst DedupMode = iota
+	// DedupLast keeps the last row for each timestamp.
+	DedupLast
+	// DedupOff keeps every row.
+	DedupOff
+)
+
+// DirectionMode selects how directions outside [0,360] are handled, for
+// both wind (WDIR) and mean wave direction (MWD).
+type DirectionMode int
+
+const (
+	// DirectionKeep stores directions as reported (the default); out-of-range
+	// values can still be flagged by QC.
+	DirectionKeep DirectionMode = iota
+	// DirectionWrap wraps every direction into [0,360), so 370 → 10,
+	// -10 → 350 and 360 → 0.
+	DirectionWrap
+	// DirectionReject stores directions outside [0,360] as null.
+	DirectionReject
+)
+
+// Format selects how data lines are split into fields.
+type Format int
+
+const (
+	// FormatAuto splits on whitespace, but maps a line by column position
+	// when its field count does not match its header (the default).
+	FormatAuto Format = iota
+	// FormatWhitespace always splits on whitespace.
+	FormatWhitespace
+	// FormatFixed maps every line under a header by column position, as
+	// in legacy fixed-width archives where a blank field is just spaces.
+	FormatFixed
+)
+
+type parseConfig struct {
+	station   string
+	maxRows   int
+	yearPivot int
+	qcChecks  bool
+	sentinels []float64
+	dedup     DedupMode
+	direction DirectionMode
+	timeISO   bool
+	format    Format
+	truncHour bool
+	units     *map[string]string
+	warnings  *[]string
+	// sentinelCounts counts values nulled as sentinels by column; nil when
+	// not requested.
+	sentinelCounts map[string]int
+	sentinelsDst   *map[string]int
+}
+
+// ParseOption tunes ParseStdMet. The zero set of options parses every row
+// with DefaultYearPivot, DefaultSentinels and DedupFirst.
+type ParseOption func(*parseConfig)
+
+// Option is the former name of ParseOption.
+type Option = ParseOption
+
+// WithStation sets the StationID stored in every row (upper-cased).
+func WithStation(id string) ParseOption {
+	return func(c *parseConfig) { c.station = strings.ToUpper(id) }
+}
+
+// WithMaxRows caps the number of data rows parsed; 0 means no limit.
+func WithMaxRows(n int) ParseOption {
+	return func(c *parseConfig) { c.maxRows = n }
+}
+
+// WithYearPivot resolves two-digit years (see DefaultYearPivot).
+func WithYearPivot(pivot int) ParseOption {
+	return func(c *parseConfig) { c.yearPivot = pivot }
+}
+
+// WithQCChecks sets each row's QCFlags to the range checks it failed (see
+// QCFlags). Without it QCFlags stays 0.
+func WithQCChecks(on bool) ParseOption {
+	return func(c *parseConfig) { c.qcChecks = on }
+}
+
+// WithSentinels replaces the values treated as missing (DefaultSentinels).
+// With no values nothing is treated as a sentinel.
+func WithSentinels(vals ...float64) ParseOption {
+	return func(c *parseConfig) { c.sentinels = vals }
+}
+
+// WithDedup selects how rows repeating a timestamp are handled.
+func WithDedup(mode DedupMode) ParseOption {
+	return func(c *parseConfig) { c.dedup = mode }
+}
+
+// WithKeepLast is WithDedup(DedupLast) when keepLast is set and
+// WithDedup(DedupFirst) otherwise.
+func WithKeepLast(keepLast bool) ParseOption {
+	if keepLast {
+		return WithDedup(DedupLast)
+	}
+	return WithDedup(DedupFirst)
+}
+
+// WithDirection selects how out-of-range WDIR and MWD values are handled.
+func WithDirection(mode DirectionMode) ParseOption {
+	return func(c *parseConfig) { c.direction = mode }
+}
+
+// WithTimeISO also sets MetRow.TimeISO, the observation time as an
+// RFC 3339 UTC string.
+func WithTimeISO(on bool) ParseOption {
+	return func(c *parseConfig) { c.timeISO = on }
+}
+
+// WithTruncateToHour sets each row's time to the start of its hour. Rows
+// that then share an hour are deduplicated by keeping the latest reading,
+// whatever the dedup mode (unless DedupOff).
+func WithTruncateToHour(on bool) ParseOption {
+	return func(c *parseConfig) { c.truncHour = on }
+}
+
+// WithFormat selects how data lines are split into fields.
+func WithFormat(f Format) ParseOption {
+	return func(c *parseConfig) { c.format = f }
+}
+
+// WithUnits stores the file's units line in *dst, keyed by column name
+// (e.g. "WSPD" → "m/s"); *dst is nil when the file has none.
+func WithUnits(dst *map[string]string) ParseOption {
+	return func(c *parseConfig) { c.units = dst }
+}
+
+// WithWarnings stores in *dst the problems the parser worked around, such
+// as headerless rows whose width does not match the fallback header; *dst
+// is nil when there were none.
+func WithWarnings(dst *[]string) ParseOption {
+	return func(c *parseConfig) { c.warnings = dst }
+}
+
+// WithSentinelCounts stores in *dst how many values of each NDBC column
+// (e.g. "WTMP") were stored as null because they matched a sentinel; *dst
+// is nil when none did.
+func WithSentinelCounts(dst *map[string]int) ParseOption {
+	return func(c *parseConfig) { c.sentinelsDst = dst }
+}
+
+// normalizeDirection applies the DirectionMode to a parsed compass bearing.
+func (c *parseConfig) normalizeDirection(p *int32) *int32 {
+	if p == nil {
+		return nil
+	}
+	switch c.direction {
+	case DirectionWrap:
+		d := (*p%360 + 360) % 360
+		return &d
+	case DirectionReject:
+		if *p < 0 || *p > 360 {
+			return nil
+		}
+	}
+	return p
+}
+
+func (c *parseConfig) isSentinel(v float64) bool {
+	for _, s := range c.sentinels {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sentinel reports whether v, read from column key, is a sentinel, and
+// counts it if so.
+func (c *parseConfig) sentinel(key string, v float64) bool {
+	if !c.isSentinel(v) {
+		return false
+	}
+	if c.sentinelsDst != nil {
+		if c.sentinelCounts == nil {
+			c.sentinelCounts = make(map[string]int)
+		}
+		c.sentinelCounts[key]++
+	}
+	return true
+}
+
+// atoiP parses column key's integer value s, returning nil for sentinel
+// values.
+func (c *parseConfig) atoiP(key, s string) *int32 {
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || c.sentinel(key, float64(v)) {
+		return nil
+	}
+	x := int32(v)
+	return &x
+}
+
+// atofP parses column key's float value s, returning nil for sentinel
+// values.
+func (c *parseConfig) atofP(key, s string) *float64 {
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || c.sentinel(key, v) {
+		return nil
+	}
+	return &v
+}
+
+// WindChill computes the wind chill (°C) from air temperature (°C) and wind
+// speed (m/s) using the NWS/Environment Canada formula. It returns nil when
+// either input is missing or outside the formula's valid domain
+// (T ≤ 10 °C, V ≥ 4.8 km/h).
+func WindChill(atmp, wspd *float64) *float64 {
+	if atmp == nil || wspd == nil {
+		return nil
+	}
+	t := *atmp
+	v := *wspd * 3.6 // m/s → km/h
+	if t > 10 || v < 4.8 {
+		return nil
+	}
+	p := math.Pow(v, 0.16)
+	wc := 13.12 + 0.6215*t - 11.37*p + 0.3965*t*p
+	return &wc
+}
+
+func get(cols []string, idx map[string]int, key string) string {
+	if i, ok := idx[strings.ToUpper(key)]; ok && i >= 0 && i < len(cols) {
+		return cols[i]
+	}
+	return ""
+}
+
+// expandYear turns a two-digit year into a four-digit one around pivot;
+// four-digit years are returned unchanged.
+func expandYear(yy string, pivot int) int {
+	year, _ := strconv.Atoi(yy)
+	if len(yy) != 2 {
+		return year
+	}
+	if year < pivot {
+		return 2000 + year
+	}
+	return 1900 + year
+}
+
+// fallbackHeader is used for data rows that appear before any header line.
+var fallbackHeader = []string{
+	"YYYY", "MM", "DD", "hh", "mm",
+	"WDIR", "WSPD", "GST", "WVHT", "DPD",
+	"APD", "MWD", "PRES", "PTDY", "ATMP",
+	"WTMP", "DEWP", "VIS", "TIDE",
+}
+
+// columnAliases maps header tokens used by some NDBC feeds to the
+// canonical names looked up by ParseStdMet.
+var columnAliases = map[string]string{
+	"WD":  "WDIR",
+	"WSP": "WSPD",
+	"GSP": "GST",
+	"BAR": "PRES",
+}
+
+// minuteKey is the index key of the minute column. NDBC names month "MM"
+// and minute "mm", which would collide once keys are upper-cased.
+const minuteKey = "MIN"
+
+// headerIndex maps header names to column positions. Aliases are indexed
+// under their canonical name too, unless the header also carries the
+// canonical column.
+func headerIndex(header []string) map[string]int {
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		if h == "mm" {
+			idx[minuteKey] = i
+			continue
+		}
+		idx[strings.ToUpper(h)] = i
+	}
+	for alias, canon := range columnAliases {
+		if i, ok := idx[alias]; ok {
+			if _, dup := idx[canon]; !dup {
+				idx[canon] = i
+			}
+		}
+	}
+	return idx
+}
+
+// headerFields reports whether line is a column header and returns its
+// column names. NDBC writes "#YY  MM DD ...", but "# YYYY", "#YYYY", "##YY"
+// and headers without the '#' occur too. A line naming both WDIR and PRES
+// (or their aliases) is taken as a header even when it does not start with
+// the year.
+func headerFields(line string) ([]string, bool) {
+	fields := strings.Fields(blankHashes(line))
+	if len(fields) == 0 {
+		return nil, false
+	}
+	if strings.HasPrefix(strings.ToUpper(fields[0]), "YY") {
+		return fields, true
+	}
+	var wdir, pres bool
+	for _, f := range fields {
+		name := strings.ToUpper(f)
+		if canon, ok := columnAliases[name]; ok {
+			name = canon
+		}
+		wdir = wdir || name == "WDIR"
+		pres = pres || name == "PRES"
+	}
+	return fields, wdir && pres
+}
+
+// blankHashes replaces the '#' marks leading line with spaces, keeping
+// the positions of the fields after them.
+func blankHashes(line string) string {
+	b := []byte(line)
+	for i, c := range b {
+		if c == '#' {
+			b[i] = ' '
+		} else if c != ' ' {
+			break
+		}
+	}
+	return string(b)
+}
+
+// span is the [start, end) byte range of one field on a line.
+type span struct{ start, end int }
+
+// fieldSpans returns the ranges of the space-separated fields of line.
+func fieldSpans(line string) []span {
+	var out []span
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		blank := i == len(line) || line[i] == ' '
+		switch {
+		case !blank && start < 0:
+			start = i
+		case blank && start >= 0:
+			out = append(out, span{start, i})
+			start = -1
+		}
+	}
+	return out
+}
+
+// splitFixed maps the fields of line to the header columns at header by
+// position: each field goes to the column it overlaps most (or, with no
+// overlap, the nearest one), so numbers aligned either way under their
+// header land in the right place and blank columns read as "". It reports
+// false when two fields land in the same column.
+func splitFixed(line string, header []span) ([]string, bool) {
+	cols := make([]string, len(header))
+	for _, f := range fieldSpans(line) {
+		best, score := 0, math.MinInt
+		for i, h := range header {
+			// Overlap length, or minus the gap when they do not overlap.
+			if s := min(f.end, h.end) - max(f.start, h.start); s > score {
+				best, score = i, s
+			}
+		}
+		if cols[best] != "" {
+			return nil, false
+		}
+		cols[best] = line[f.start:f.end]
+	}
+	return cols, true
+}
+
+// dataLine is one whitespace-split data row together with the column index
+// of the header block it belongs to.
+type dataLine struct {
+	cols []string
+	idx  map[string]int
+}
+
+// normalizeLine cleans one raw line before it is split into fields: a
+// byte-order mark, CRs, tabs and other control whitespace, and non-breaking
+// spaces (UTF-8, or a Latin-1 0xA0 byte in non-UTF-8 input) all become plain
+// spaces, and trailing space is dropped, so stray bytes never end up inside
+// a value.
+func normalizeLine(b []byte) string {
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+	latin1 := !utf8.Valid(b)
+	b = bytes.ReplaceAll(b, []byte("\u00a0"), []byte(" "))
+	out := make([]byte, len(b))
+	for i, c := range b {
+		switch {
+		case c == '\r', c == '\t', c == '\v', c == '\f':
+			c = ' '
+		case c == 0xA0 && latin1:
+			c = ' '
+		}
+		out[i] = c
+	}
+	return strings.TrimRight(string(out), " ")
+}
+
+// scan splits body into data lines and returns the units comment line that
+// follows the first header, keyed by column name (nil when absent), plus
+// warnings about the layout.
+//
+// Rows before any header are mapped positionally with fallbackHeader. If
+// their width differs from it, that mapping may be misaligned, so only the
+// columns every such row has are kept (the rest read as missing) and a
+// warning is returned.
+func scan(body []byte, format Format) ([]dataLine, map[string]string, []string, error) {
+	r := bufio.NewReader(bytes.NewReader(body))
+	var header, unitsLine []string
+	var data []dataLine
+	idx := headerIndex(fallbackHeader)
+	var spans []span // column positions of the current header
+	afterHeader := false
+	var headerless []int // indexes into data of rows mapped with fallbackHeader
+	fixed, misaligned := 0, 0
+
+	for {
+		lineBytes, _, err := r.ReadLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		line := normalizeLine(lineBytes)
+
+		if fields, ok := headerFields(line); ok {
+			if header == nil {
+				header = fields
+			}
+			idx = headerIndex(fields)
+			spans = fieldSpans(blankHashes(line))
+			afterHeader = true
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			// The comment line directly after the first header carries the units.
+			if afterHeader && unitsLine == nil {
+				unitsLine = strings.Fields(blankHashes(line))
+			}
+			afterHeader = false
+			continue
+		}
+		afterHeader = false
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cols := strings.Fields(line)
+		if spans != nil && (format == FormatFixed || format == FormatAuto && len(cols) != len(spans)) {
+			if c, ok := splitFixed(line, spans); ok {
+				if len(cols) != len(spans) {
+					fixed++
+				}
+				cols = c
+			} else {
+				misaligned++
+			}
+		}
+		if len(cols) >= 5 {
+			if header == nil {
+				headerless = append(headerless, len(data))
+			}
+			data = append(data, dataLine{cols: cols, idx: idx})
+		}
+	}
+	warnings := reconcileHeaderless(data, headerless)
+	if fixed > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d rows with blank fields were mapped to the header by column position", fixed))
+	}
+	if misaligned > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d rows do not line up with their header and were split on whitespace; they may be misaligned", misaligned))
+	}
+
+	var units map[string]string
+	if header != nil && unitsLine != nil {
+		units = make(map[string]string, len(header))
+		for i, h := range header {
+			if i < len(unitsLine) {
+				units[h] = unitsLine[i]
+			}
+		}
+	}
+	return data, units, warnings, nil
+}
+
+// reconcileHeaderless limits the rows at indexes rows of data, which were
+// mapped with fallbackHeader, to the columns all of them have.
+func reconcileHeaderless(data []dataLine, rows []int) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	lo, hi := len(data[rows[0]].cols), len(data[rows[0]].cols)
+	for _, i := range rows[1:] {
+		lo = min(lo, len(data[i].cols))
+		hi = max(hi, len(data[i].cols))
+	}
+	if lo == len(fallbackHeader) && hi == lo {
+		return nil
+	}
+	width := min(lo, len(fallbackHeader))
+	idx := headerIndex(fallbackHeader[:width])
+	for _, i := range rows {
+		data[i].idx = idx
+	}
+	return []string{fmt.Sprintf(
+		"%d rows without a header have %d-%d fields, the fallback header has %d; only the first %d columns (%s..%s) are used and may be misaligned",
+		len(rows), lo, hi, len(fallbackHeader), width, fallbackHeader[0], fallbackHeader[width-1])}
+}
+
+// Units returns the units comment line that follows the first header of an
+// NDBC text file, keyed by column name (e.g. "WSPD" → "m/s"). It is nil
+// when the file has no units line.
+func Units(body []byte) map[string]string {
+	_, units, _, _ := scan(body, FormatAuto)
+	return units
+}
+
+// ParseStdMet parses NDBC standard meteorological text data.
+// It dynamically finds the header line and maps columns by name. A header
+// appearing mid-stream (concatenated files) starts a new block whose rows
+// are mapped with that header. Rows repeating an earlier timestamp are
+// dropped (see WithDedup).
+func ParseStdMet(body []byte, opts ...ParseOption) ([]MetRow, error) {
+	cfg := parseConfig{yearPivot: DefaultYearPivot, sentinels: DefaultSentinels}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	data, units, warnings, err := scan(body, cfg.format)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.units != nil {
+		*cfg.units = units
+	}
+	if cfg.warnings != nil {
+		*cfg.warnings = warnings
+	}
+	if cfg.maxRows > 0 && len(data) > cfg.maxRows {
+		data = data[:cfg.maxRows]
+	}
+
+	out := make([]MetRow, 0, len(data))
+	var observed []int64 // untruncated times, with WithTruncateToHour
+	for _, d := range data {
+		cols, idx := d.cols, d.idx
+		// Determine year column name (YYYY or YY).
+		yy := get(cols, idx, "YYYY")
+		if yy == "" {
+			yy = get(cols, idx, "YY")
+		}
+		mm := get(cols, idx, "MM")
+		dd := get(cols, idx, "DD")
+		hh := get(cols, idx, "HH")
+		mn := get(cols, idx, minuteKey)
+
+		year := expandYear(yy, cfg.yearPivot)
+		month, _ := strconv.Atoi(mm)
+		day, _ := strconv.Atoi(dd)
+		hour, _ := strconv.Atoi(hh)
+		minute, _ := strconv.Atoi(mn)
+
+		t := time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC)
+		if cfg.truncHour {
+			observed = append(observed, t.Unix())
+			t = t.Truncate(time.Hour)
+		}
+
+		row := MetRow{
+			StationID: cfg.station,
+			Time:      t.Unix(),
+			WDIRDeg:   cfg.normalizeDirection(cfg.atoiP("WDIR", get(cols, idx, "WDIR"))),
+			WSPDmS:    cfg.atofP("WSPD", get(cols, idx, "WSPD")),
+			GUSTmS:    cfg.atofP("GST", get(cols, idx, "GST")),
+			PREShPa:   cfg.atofP("PRES", get(cols, idx, "PRES")),
+			ATMPC:     cfg.atofP("ATMP", get(cols, idx, "ATMP")),
+			WTMPC:     cfg.atofP("WTMP", get(cols, idx, "WTMP")),
+			DEWPC:     cfg.atofP("DEWP", get(cols, idx, "DEWP")),
+			MWDDeg:    cfg.normalizeDirection(cfg.atoiP("MWD", get(cols, idx, "MWD"))),
+		}
+		if cfg.qcChecks {
+			row.QCFlags = QCFlags(row)
+		}
+		if cfg.timeISO {
+			iso := t.Format(time.RFC3339)
+			row.TimeISO = &iso
+		}
+		row.WindChillC = WindChill(row.ATMPC, row.WSPDmS)
+		if row.WDIRDeg != nil {
+			c := Cardinal(float64(*row.WDIRDeg))
+			row.WDIRCardinal = &c
+		}
+		out = append(out, row)
+	}
+
+	if cfg.sentinelsDst != nil {
+		*cfg.sentinelsDst = cfg.sentinelCounts
+	}
+
+	switch {
+	case cfg.dedup == DedupOff:
+		return out, nil
+	case cfg.truncHour:
+		return dedupLatest(out, observed), nil
+	case cfg.dedup == DedupLast:
+		return dedupByTime(out, true), nil
+	default:
+		return dedupByTime(out, false), nil
+	}
+}
+
+// dedupByTime collapses rows sharing a timestamp (NDBC occasionally repeats
+// reprocessed observations) in place, keeping the first occurrence or, with
+// keepLast, the last one. Surviving rows stay at the position of the first.
+func dedupByTime(rows []MetRow, keepLast bool) []MetRow {
+	pos := make(map[int64]int, len(rows))
+	out := rows[:0]
+	for _, r := range rows {
+		if i, ok := pos[r.Time]; ok {
+			if keepLast {
+				out[i] = r
+			}
+			continue
+		}
+		pos[r.Time] = len(out)
+		out = append(out, r)
+	}
+	return out
+}
+
+// dedupLatest collapses rows sharing a (truncated) timestamp in place,
+// keeping the one whose observed time is latest. Surviving rows stay at the
+// position of the first.
+func dedupLatest(rows []MetRow, observed []int64) []MetRow {
+	pos := make(map[int64]int, len(rows))
+	latest := make([]int64, 0, len(rows))
+	out := rows[:0]
+	for i, r := range rows {
+		if j, ok := pos[r.Time]; ok {
+			if observed[i] > latest[j] {
+				out[j], latest[j] = r, observed[i]
+			}
+			continue
+		}
+		pos[r.Time] = len(out)
+		out = append(out, r)
+		latest = append(latest, observed[i])
+	}
+	return out
+}
+
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// Cardinal returns the 16-point compass label for a bearing in degrees.
+// Each sector spans 22.5° centred on its point, so N covers
+// [348.75, 11.25) and NNE starts at 11.25; bearings outside [0,360) wrap.
+func Cardinal(deg float64) string {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return compassPoints[int(math.Floor(deg/22.5+0.5))%16]
+}
+
+// SolarOffset is the local mean solar time offset from UTC at longitude
+// lon (degrees east; west is negative): four minutes per degree.
+func SolarOffset(lon float64) time.Duration {
+	return time.Duration(math.Round(lon*240)) * time.Second
+}
+
+// SolarTime converts a UTC epoch time t to the wall-clock reading of local
+// mean solar time at lon, expressed as epoch seconds.
+func SolarTime(t int64, lon float64) int64 {
+	return t + int64(SolarOffset(lon)/time.Second)
+}
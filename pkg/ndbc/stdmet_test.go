@@ -0,0 +1,540 @@
+package ndbc
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stdmetHeader is the two header lines of a realtime2 .txt file.
+const stdmetHeader = `#YY  MM DD hh mm WDIR WSPD GST  WVHT   DPD   APD MWD   PRES  ATMP  WTMP  DEWP  VIS PTDY  TIDE
+#yr  mo dy hr mn degT m/s  m/s     m   sec   sec degT   hPa  degC  degC  degC  nmi  hPa    ft
+`
+
+func f64(v float64) *float64 { return &v }
+
+func i32(v int32) *int32 { return &v }
+
+func eqI32(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func fmtI32(p *int32) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func f64p(p *float64) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func unix(y int, mo time.Month, d, h, mi int) int64 {
+	return time.Date(y, mo, d, h, mi, 0, 0, time.UTC).Unix()
+}
+
+func parse(t *testing.T, body string, opts ...Option) []MetRow {
+	t.Helper()
+	rows, err := ParseStdMet([]byte(body), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestTwoDigitYearPivot(t *testing.T) {
+	// Pre-1999 files have no minute column.
+	body := "#YY MM DD hh WDIR WSPD PRES\n" +
+		"98 05 01 12 120 5.0 1013.2\n" +
+		"05 05 01 12 120 5.0 1013.2\n" +
+		"69 05 01 12 120 5.0 1013.2\n" +
+		"70 05 01 12 120 5.0 1013.2\n"
+	want := []int{1998, 2005, 2069, 1970}
+	rows := parse(t, body)
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d", len(rows), len(want))
+	}
+	for i, r := range rows {
+		if y := time.Unix(r.Time, 0).UTC().Year(); y != want[i] {
+			t.Errorf("row %d: year %d, want %d", i, y, want[i])
+		}
+	}
+
+	if rows := parse(t, body, WithYearPivot(0)); time.Unix(rows[1].Time, 0).UTC().Year() != 1905 {
+		t.Errorf("pivot 0: 05 → %d, want 1905", time.Unix(rows[1].Time, 0).UTC().Year())
+	}
+}
+
+func TestHeaderBlocks(t *testing.T) {
+	body := "#YY  MM DD hh mm WDIR WSPD PRES\n" +
+		"#yr  mo dy hr mn degT m/s  hPa\n" +
+		"2024 05 01 12 00 120  5.0 1013.2\n" +
+		"#YY  MM DD hh mm PRES WSPD WDIR\n" +
+		"#yr  mo dy hr mn hPa  m/s  degT\n" +
+		"2024 05 01 11 00 1009.8 7.5 240\n"
+	rows := parse(t, body)
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	for i, want := range []struct {
+		wdir       int32
+		wspd, pres float64
+	}{{120, 5.0, 1013.2}, {240, 7.5, 1009.8}} {
+		r := rows[i]
+		if r.WDIRDeg == nil || *r.WDIRDeg != want.wdir || r.WSPDmS == nil || *r.WSPDmS != want.wspd ||
+			r.PREShPa == nil || *r.PREShPa != want.pres {
+			t.Errorf("block %d: %+v, want WDIR %v WSPD %v PRES %v", i, r, want.wdir, want.wspd, want.pres)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	body := "#YY  MM DD hh WSPD\n" +
+		"2024 05 01 12 5.0\n" +
+		"2024 05 01 12 6.0\n" +
+		"2024 05 01 11 4.0\n"
+	tests := []struct {
+		mode DedupMode
+		want []float64
+	}{
+		{DedupFirst, []float64{5, 4}},
+		{DedupLast, []float64{6, 4}},
+		{DedupOff, []float64{5, 6, 4}},
+	}
+	for _, tt := range tests {
+		rows := parse(t, body, WithDedup(tt.mode))
+		var got []float64
+		for _, r := range rows {
+			got = append(got, *r.WSPDmS)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("mode %d: WSPD %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsCombine(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 08 00 120 99.0  6.0    MM    MM    MM  MM  750.0  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 06 10 08 00 130  7.0  8.0    MM    MM    MM  MM 1013.0  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 06 10 07 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8  26.0   999   MM   MM    MM\n" +
+		"2024 06 10 06 00 100  3.0  4.0    MM    MM    MM  MM 1013.0  24.6  26.0  20.2   MM   MM    MM\n"
+	var units map[string]string
+	rows := parse(t, body,
+		WithStation("sanf1"),
+		WithMaxRows(3),
+		WithDedup(DedupLast),
+		WithSentinels(999),
+		WithQCChecks(true),
+		WithUnits(&units))
+
+	// WithMaxRows(3) drops the 06:00 row before DedupLast folds 08:00.
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	r0, r1 := rows[0], rows[1]
+	if r0.StationID != "SANF1" || r0.WDIRDeg == nil || *r0.WDIRDeg != 130 {
+		t.Errorf("row 0 = %+v, want the last SANF1 08:00 row", r0)
+	}
+	if r0.QCFlags != 0 {
+		t.Errorf("row 0 QC flags = %b, want 0 (the 750 hPa row was replaced)", r0.QCFlags)
+	}
+	// 999 is still a sentinel under WithSentinels(999).
+	if r1.DEWPC != nil {
+		t.Errorf("row 1 DEWP = %v, want nil (sentinel 999)", *r1.DEWPC)
+	}
+	if units["PRES"] != "hPa" {
+		t.Errorf("units = %v", units)
+	}
+
+	// With no sentinels 99.0 m/s is a value, and QC still flags 750 hPa.
+	rows = parse(t, body, WithMaxRows(1), WithSentinels(), WithQCChecks(true))
+	if len(rows) != 1 || rows[0].WSPDmS == nil || *rows[0].WSPDmS != 99 || rows[0].QCFlags != QCPRES {
+		t.Errorf("no sentinels + QC: %+v", rows)
+	}
+}
+
+func TestQCFlagsOutOfRangePressure(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM  750.0  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 06 10 06 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8  26.0  20.1   MM   MM    MM\n"
+	rows := parse(t, body, WithQCChecks(true))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].QCFlags != QCPRES {
+		t.Errorf("out-of-range row flags = %b, want %b", rows[0].QCFlags, QCPRES)
+	}
+	if rows[0].PREShPa == nil || *rows[0].PREShPa != 750 {
+		t.Errorf("suspect pressure not kept: %v", rows[0].PREShPa)
+	}
+	if rows[1].QCFlags != 0 {
+		t.Errorf("in-range row flags = %b, want 0", rows[1].QCFlags)
+	}
+}
+
+func TestQCFlagsEachBit(t *testing.T) {
+	tests := []struct {
+		name string
+		row  MetRow
+		want uint32
+	}{
+		{"wdir", MetRow{WDIRDeg: i32(400)}, 1},
+		{"wspd", MetRow{WSPDmS: f64(130)}, 2},
+		{"gust", MetRow{GUSTmS: f64(-1)}, 4},
+		{"pres", MetRow{PREShPa: f64(1200)}, 8},
+		{"atmp", MetRow{ATMPC: f64(70)}, 16},
+		{"wtmp", MetRow{WTMPC: f64(-10)}, 32},
+		{"dewp", MetRow{DEWPC: f64(45)}, 64},
+		{"mwd", MetRow{MWDDeg: i32(-5)}, 128},
+		{"all nil", MetRow{}, 0},
+		{"in range", MetRow{WDIRDeg: i32(360), WSPDmS: f64(0), PREShPa: f64(800)}, 0},
+	}
+	for _, tt := range tests {
+		if got := QCFlags(tt.row); got != tt.want {
+			t.Errorf("%s: QCFlags = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQCFlagsOffLeavesZero(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 07 00 120  5.0  6.0    MM    MM    MM  MM  750.0  25.0  26.0  20.0   MM   MM    MM\n"
+	rows := parse(t, body)
+	if rows[0].QCFlags != 0 {
+		t.Errorf("flags = %b with QC off, want 0", rows[0].QCFlags)
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	// -10 °C at 10 m/s (36 km/h): 13.12 + 0.6215T - 11.37V^0.16 + 0.3965TV^0.16.
+	if got := WindChill(f64(-10), f64(10)); got == nil {
+		t.Error("WindChill(-10 °C, 10 m/s) = nil")
+	} else if math.Abs(*got-(-20.30)) > 0.01 {
+		t.Errorf("WindChill(-10 °C, 10 m/s) = %.2f, want ≈ -20.30", *got)
+	}
+	tests := []struct {
+		name       string
+		atmp, wspd *float64
+	}{
+		{"too warm", f64(10.5), f64(10)},
+		{"too calm", f64(-10), f64(1.3)}, // 4.68 km/h
+		{"missing temperature", nil, f64(10)},
+		{"missing wind", f64(-10), nil},
+	}
+	for _, tt := range tests {
+		if got := WindChill(tt.atmp, tt.wspd); got != nil {
+			t.Errorf("%s: WindChill = %v, want nil", tt.name, *got)
+		}
+	}
+	if WindChill(f64(10), f64(4.8/3.6)) == nil {
+		t.Error("WindChill is nil at the domain edge (10 °C, 4.8 km/h)")
+	}
+}
+
+func TestSolarTime(t *testing.T) {
+	noon := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC).Unix()
+	tests := []struct {
+		lon  float64
+		want time.Duration
+	}{
+		{0, 0},
+		{-81.877, -(5*time.Hour + 27*time.Minute + 30*time.Second)}, // SANF1: -19650.48 s
+		{15, time.Hour},
+		{180, 12 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := SolarOffset(tt.lon); got != tt.want {
+			t.Errorf("SolarOffset(%g) = %s, want %s", tt.lon, got, tt.want)
+		}
+		if got := SolarTime(noon, tt.lon); got != noon+int64(tt.want/time.Second) {
+			t.Errorf("SolarTime(noon, %g) = %d, want %d", tt.lon, got, noon+int64(tt.want/time.Second))
+		}
+	}
+}
+
+func TestColumnAliases(t *testing.T) {
+	rows := parse(t, "#YY  MM DD hh mm WD  WSP GSP BAR\n"+
+		"2024 05 01 12 00 120 5.0 6.5 1013.2\n")
+	if len(rows) != 1 {
+		t.Fatalf("%d rows, want 1", len(rows))
+	}
+	r := rows[0]
+	if !eqI32(r.WDIRDeg, i32(120)) || f64p(r.WSPDmS) != 5.0 || f64p(r.GUSTmS) != 6.5 || f64p(r.PREShPa) != 1013.2 {
+		t.Errorf("WDIR %v WSPD %v GST %v PRES %v", fmtI32(r.WDIRDeg), f64p(r.WSPDmS), f64p(r.GUSTmS), f64p(r.PREShPa))
+	}
+
+	// The canonical column wins over an alias in the same header.
+	rows = parse(t, "#YY  MM DD hh mm PRES BAR\n2024 05 01 12 00 1013.2 999.0\n")
+	if f64p(rows[0].PREShPa) != 1013.2 {
+		t.Errorf("PRES %v, want 1013.2", f64p(rows[0].PREShPa))
+	}
+}
+
+func TestDirectionModes(t *testing.T) {
+	body := stdmetHeader +
+		"2024 05 01 12 50 360  5.0  6.0    MM    MM    MM 360 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 05 01 12 40 -10  5.0  6.0    MM    MM    MM -10 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 05 01 12 30 370  5.0  6.0    MM    MM    MM 370 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 05 01 12 20 720  5.0  6.0    MM    MM    MM 720 1013.2  25.0  26.0  20.0   MM   MM    MM\n" +
+		"2024 05 01 12 10  MM  5.0  6.0    MM    MM    MM  MM 1013.2  25.0  26.0  20.0   MM   MM    MM\n"
+	tests := []struct {
+		mode DirectionMode
+		want []*int32 // per row, for both WDIR and MWD
+	}{
+		{DirectionKeep, []*int32{i32(360), i32(-10), i32(370), i32(720), nil}},
+		{DirectionWrap, []*int32{i32(0), i32(350), i32(10), i32(0), nil}},
+		{DirectionReject, []*int32{i32(360), nil, nil, nil, nil}},
+	}
+	for _, tt := range tests {
+		rows := parse(t, body, WithDirection(tt.mode), WithQCChecks(true))
+		if len(rows) != len(tt.want) {
+			t.Fatalf("mode %d: %d rows, want %d", tt.mode, len(rows), len(tt.want))
+		}
+		for i, r := range rows {
+			if !eqI32(r.WDIRDeg, tt.want[i]) {
+				t.Errorf("mode %d row %d: WDIR = %v, want %v", tt.mode, i, fmtI32(r.WDIRDeg), fmtI32(tt.want[i]))
+			}
+			if !eqI32(r.MWDDeg, tt.want[i]) {
+				t.Errorf("mode %d row %d: MWD = %v, want %v", tt.mode, i, fmtI32(r.MWDDeg), fmtI32(tt.want[i]))
+			}
+			// QC runs on the stored value, so only kept out-of-range
+			// directions are flagged.
+			var want uint32
+			if p := tt.want[i]; p != nil && (*p < 0 || *p > 360) {
+				want = QCWDIR | QCMWD
+			}
+			if got := r.QCFlags & (QCWDIR | QCMWD); got != want {
+				t.Errorf("mode %d row %d: direction QC flags = %b, want %b", tt.mode, i, got, want)
+			}
+		}
+	}
+}
+
+func TestTimeISO(t *testing.T) {
+	body := stdmetHeader + "2024 05 01 12 30 120  5.0  6.5 99.00 99.00 99.00 999 1013.2  20.1  21.3  15.0 99.0 99.0 99.00\n"
+	if r := parse(t, body)[0]; r.TimeISO != nil {
+		t.Errorf("TimeISO = %q without WithTimeISO", *r.TimeISO)
+	}
+	r := parse(t, body, WithTimeISO(true))[0]
+	if r.TimeISO == nil || *r.TimeISO != "2024-05-01T12:30:00Z" {
+		t.Errorf("TimeISO = %v, want 2024-05-01T12:30:00Z", r.TimeISO)
+	}
+}
+
+func TestLineNormalization(t *testing.T) {
+	body := "#YY  MM DD hh mm WDIR WSPD PRES  \r\n" +
+		"#yr  mo dy hr mn degT m/s  hPa\r\n" +
+		"2024 05 01 12 00 120  5.0  1013.2  \t \r\n" +
+		"2024 05 01 11 00\t110\t4.0\t1012.9 \r\n"
+	var units map[string]string
+	rows := parse(t, body, WithUnits(&units))
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	if f64p(rows[0].PREShPa) != 1013.2 || f64p(rows[1].PREShPa) != 1012.9 || !eqI32(rows[1].WDIRDeg, i32(110)) {
+		t.Errorf("PRES %v, %v; WDIR %v", f64p(rows[0].PREShPa), f64p(rows[1].PREShPa), fmtI32(rows[1].WDIRDeg))
+	}
+	if units["PRES"] != "hPa" {
+		t.Errorf("units = %v", units)
+	}
+}
+
+func TestCardinal(t *testing.T) {
+	tests := []struct {
+		deg  float64
+		want string
+	}{
+		{0, "N"}, {11.24, "N"}, {11.25, "NNE"}, {45, "NE"}, {90, "E"}, {180, "S"},
+		{191.25, "SSW"}, {348.74, "NNW"}, {348.75, "N"}, {360, "N"}, {370, "N"}, {-22.5, "NNW"},
+	}
+	for _, tt := range tests {
+		if got := Cardinal(tt.deg); got != tt.want {
+			t.Errorf("Cardinal(%g) = %q, want %q", tt.deg, got, tt.want)
+		}
+	}
+
+	rows := parse(t, stdmetHeader+
+		"2024 05 01 12 00 225  5.0  6.5 99.00 99.00 99.00 999 1013.2  20.1  21.3  15.0 99.0 99.0 99.00\n"+
+		"2024 05 01 11 00  MM  5.0  6.5 99.00 99.00 99.00 999 1013.2  20.1  21.3  15.0 99.0 99.0 99.00\n")
+	if c := rows[0].WDIRCardinal; c == nil || *c != "SW" {
+		t.Errorf("WDIRCardinal = %v, want SW", c)
+	}
+	if c := rows[1].WDIRCardinal; c != nil {
+		t.Errorf("WDIRCardinal = %q with no WDIR, want nil", *c)
+	}
+}
+
+func TestSubHourlyTimes(t *testing.T) {
+	body := "#YY  MM DD hh mm WSPD\n" +
+		"2024 05 01 12 36 5.3\n" +
+		"2024 05 01 12 12 5.2\n" +
+		"2024 05 01 12 06 5.1\n" +
+		"2024 05 01 12 00 5.0\n"
+	want := []int64{
+		unix(2024, 5, 1, 12, 36),
+		unix(2024, 5, 1, 12, 12),
+		unix(2024, 5, 1, 12, 6),
+		unix(2024, 5, 1, 12, 0),
+	}
+	rows := parse(t, body)
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d (sub-hourly rows must not be deduplicated)", len(rows), len(want))
+	}
+	for i, r := range rows {
+		if r.Time != want[i] {
+			t.Errorf("row %d: %s, want %s", i, time.Unix(r.Time, 0).UTC(), time.Unix(want[i], 0).UTC())
+		}
+	}
+}
+
+func TestHeaderlessWidthMismatch(t *testing.T) {
+	// 15 fields: the fallback header's first 15 columns end at ATMP.
+	narrow := "2024 05 01 12 00 120 5.0 6.0 MM MM MM MM 1013.2 MM 25.0\n"
+	var warnings []string
+	rows := parse(t, narrow, WithWarnings(&warnings))
+	if len(rows) != 1 {
+		t.Fatalf("%d rows, want 1", len(rows))
+	}
+	r := rows[0]
+	if f64p(r.PREShPa) != 1013.2 || f64p(r.ATMPC) != 25.0 || r.WTMPC != nil || r.DEWPC != nil {
+		t.Errorf("PRES %v ATMP %v WTMP %v DEWP %v", f64p(r.PREShPa), f64p(r.ATMPC), f64p(r.WTMPC), f64p(r.DEWPC))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "fallback header") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	// Extra fields past the fallback header are ignored, not shifted in.
+	wide := "2024 05 01 12 00 120 5.0 6.0 MM MM MM MM 1013.2 MM 25.0 26.0 20.0 MM MM 7 8 9\n"
+	warnings = nil
+	rows = parse(t, wide, WithWarnings(&warnings))
+	if len(rows) != 1 || f64p(rows[0].WTMPC) != 26.0 || f64p(rows[0].DEWPC) != 20.0 || len(warnings) != 1 {
+		t.Errorf("wide row: %+v, warnings %q", rows, warnings)
+	}
+
+	// Rows matching the fallback width parse without a warning.
+	warnings = nil
+	full := "2024 05 01 12 00 120 5.0 6.0 MM MM MM MM 1013.2 MM 25.0 26.0 20.0 MM MM\n"
+	if rows = parse(t, full, WithWarnings(&warnings)); len(rows) != 1 || warnings != nil {
+		t.Errorf("full-width row: %d rows, warnings %q", len(rows), warnings)
+	}
+}
+
+func TestFixedWidth(t *testing.T) {
+	// GST is blank, so splitting on whitespace would shift PRES into GST.
+	body := "#YY  MM DD hh mm WDIR WSPD GST  PRES  ATMP\n" +
+		"2024 05 01 12 00  120  5.0     1013.2 25.0\n"
+	for _, f := range []Format{FormatAuto, FormatFixed} {
+		var warnings []string
+		rows := parse(t, body, WithFormat(f), WithWarnings(&warnings))
+		if len(rows) != 1 {
+			t.Fatalf("format %d: %d rows, want 1", f, len(rows))
+		}
+		r := rows[0]
+		if r.GUSTmS != nil || f64p(r.PREShPa) != 1013.2 || f64p(r.ATMPC) != 25.0 || !eqI32(r.WDIRDeg, i32(120)) {
+			t.Errorf("format %d: WDIR %v GST %v PRES %v ATMP %v", f,
+				fmtI32(r.WDIRDeg), f64p(r.GUSTmS), f64p(r.PREShPa), f64p(r.ATMPC))
+		}
+		if f == FormatAuto && len(warnings) != 1 {
+			t.Errorf("format auto: warnings = %q", warnings)
+		}
+	}
+
+	rows := parse(t, body, WithFormat(FormatWhitespace))
+	if f64p(rows[0].GUSTmS) != 1013.2 {
+		t.Errorf("whitespace: GST %v, want the misaligned 1013.2", f64p(rows[0].GUSTmS))
+	}
+}
+
+func TestSentinelCounts(t *testing.T) {
+	body := stdmetHeader +
+		"2024 06 10 08 00 120  5.0  6.0    MM    MM    MM 999 1013.2 999.0 999.0  20.0   MM   MM    MM\n" +
+		"2024 06 10 07 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8 999.0  20.0   MM   MM    MM\n"
+	var counts map[string]int
+	rows := parse(t, body, WithSentinelCounts(&counts))
+	if rows[0].WTMPC != nil || rows[0].ATMPC != nil || rows[1].WTMPC != nil {
+		t.Errorf("sentinels not nulled: %+v", rows)
+	}
+	want := map[string]int{"MWD": 1, "ATMP": 1, "WTMP": 2}
+	if !maps.Equal(counts, want) {
+		t.Errorf("counts = %v, want %v (MM is missing, not a sentinel)", counts, want)
+	}
+
+	counts = map[string]int{"stale": 1}
+	parse(t, stdmetHeader+"2024 06 10 07 00 110  4.0  5.0    MM    MM    MM  MM 1013.0  24.8  26.0  20.0   MM   MM    MM\n",
+		WithSentinelCounts(&counts))
+	if counts != nil {
+		t.Errorf("counts = %v without sentinels, want nil", counts)
+	}
+}
+
+func TestTruncateToHour(t *testing.T) {
+	body := "#YY  MM DD hh mm WSPD\n" +
+		"2024 05 01 12 06 5.1\n" +
+		"2024 05 01 12 54 5.9\n" +
+		"2024 05 01 11 30 4.0\n"
+	rows := parse(t, body, WithTruncateToHour(true), WithTimeISO(true))
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	if rows[0].Time != unix(2024, 5, 1, 12, 0) || f64p(rows[0].WSPDmS) != 5.9 {
+		t.Errorf("12:xx row: %s WSPD %v, want 12:00 with the :54 reading 5.9",
+			time.Unix(rows[0].Time, 0).UTC(), f64p(rows[0].WSPDmS))
+	}
+	if rows[0].TimeISO == nil || *rows[0].TimeISO != "2024-05-01T12:00:00Z" {
+		t.Errorf("TimeISO = %v", rows[0].TimeISO)
+	}
+	if rows[1].Time != unix(2024, 5, 1, 11, 0) {
+		t.Errorf("11:xx row: %s", time.Unix(rows[1].Time, 0).UTC())
+	}
+
+	// Off by default.
+	if rows := parse(t, body); len(rows) != 3 || rows[0].Time != unix(2024, 5, 1, 12, 6) {
+		t.Errorf("without truncation: %d rows, first at %s", len(rows), time.Unix(rows[0].Time, 0).UTC())
+	}
+}
+
+func TestHeaderVariants(t *testing.T) {
+	const row = "2024 05 01 12 00 120 5.0 1013.2\n"
+	want := unix(2024, 5, 1, 12, 0)
+	tests := []struct {
+		name, header string
+	}{
+		{"standard", "#YY  MM DD hh mm WDIR WSPD PRES\n"},
+		{"space after hash", "# YY MM DD hh mm WDIR WSPD PRES\n"},
+		{"four-digit year", "#YYYY MM DD hh mm WDIR WSPD PRES\n"},
+		{"space and four-digit year", "# YYYY MM DD hh mm WDIR WSPD PRES\n"},
+		{"double hash", "##YY MM DD hh mm WDIR WSPD PRES\n"},
+		{"tabs", "#YY\tMM\tDD\thh\tmm\tWDIR\tWSPD\tPRES\n"},
+		{"mixed whitespace", "#YY \t MM  DD\thh mm   WDIR\t WSPD PRES\n"},
+		{"no hash", "YYYY MM DD hh mm WDIR WSPD PRES\n"},
+	}
+	for _, tt := range tests {
+		rows := parse(t, tt.header+row)
+		if len(rows) != 1 {
+			t.Errorf("%s: %d rows, want 1", tt.name, len(rows))
+			continue
+		}
+		r := rows[0]
+		if r.Time != want || !eqI32(r.WDIRDeg, i32(120)) || f64p(r.PREShPa) != 1013.2 {
+			t.Errorf("%s: time %s WDIR %v PRES %v", tt.name, time.Unix(r.Time, 0).UTC(), fmtI32(r.WDIRDeg), f64p(r.PREShPa))
+		}
+	}
+
+	// A header that does not lead with the year is still found by its
+	// WDIR and PRES columns, and its column order is honored.
+	rows := parse(t, "#WDIR WSPD PRES YY MM DD hh mm\n120 5.0 1013.2 24 05 01 12 00\n")
+	if len(rows) != 1 || rows[0].Time != want || f64p(rows[0].PREShPa) != 1013.2 {
+		t.Errorf("WDIR/PRES header: %+v", rows)
+	}
+}
@@ -18,14 +18,16 @@ import requests
 
 SOURCE_URL: str = os.environ.get("SOURCE_URL", "http://go-source:8080/stream")
 OUT_DIR: str = os.environ.get("OUT_DIR", "/app/out")
+AUTH_TOKEN: str = os.environ.get("AUTH_TOKEN", "")
 
 
 def fetch_arrow_table(url: str, retries: int = 30, delay: float = 2.0) -> pa.Table:
     """Fetch Arrow IPC stream with retry logic (waits for go-source to be ready)."""
     last_err: Exception | None = None
+    headers = {"Authorization": f"Bearer {AUTH_TOKEN}"} if AUTH_TOKEN else {}
     for attempt in range(1, retries + 1):
         try:
-            resp = requests.get(url, timeout=15)
+            resp = requests.get(url, headers=headers, timeout=15)
             resp.raise_for_status()
             reader = pa.ipc.open_stream(io.BytesIO(resp.content))
             return reader.read_all()